@@ -1,94 +1,96 @@
+// Package streamz decodes FILEVUEP extracts: whitespace separated rows of
+// Table View Method Path Pos, one view per line. Delimited and fixed width
+// variants are read with a Schema.
+//
+//	f, err := os.Open("FILES/FILEVUEP")
+//	...
+//	for v, err := range streamz.NewDecoder(f, streamz.Lenient(nil)).All() {
+//		...
+//	}
 package streamz
 
 import (
-	"bufio"
 	"context"
-	"fmt"
 	"io"
-	"os"
-	"strconv"
-	"strings"
-	"time"
 )
 
+// Filevuep is one row of a FILEVUEP file.
 type Filevuep struct {
 	Table  string
 	View   string
 	Method string
 	Path   string
 	Pos    int
-	err    error
 }
 
-func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	f, err := os.Open("FILES/FILEVUEP")
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
-	// start decoding the file one line at a time
-	ch := DecodeFilevuep(ctx, f)
-
-	// read each line of output and write record to screen
-	for fileview := range ch {
-		if fileview.err != nil {
-			continue
-		}
-	}
+// Result is what DecodeFilevuep sends for every row: a record, or the
+// error that stopped the decoding.
+type Result struct {
+	Filevuep
+	Err error
 }
 
-func DecodeFilevuep(ctx context.Context, r io.Reader) chan Filevuep {
-	ch := make(chan Filevuep, 1)
+// DecodeFilevuep decodes r in a goroutine and sends every record on the
+// returned channel, which is closed at the end of the input. A bad row in
+// strict mode or a read error is sent as a last Result with Err set, and
+// so is the error of ctx once it is done, a record the caller has not read
+// yet being dropped to make room for it. The caller must drain the channel
+// or cancel ctx. Pipeline does the same on several
+// goroutines for large files.
+func DecodeFilevuep(ctx context.Context, r io.Reader, opts ...Option) <-chan Result {
+	ch := make(chan Result, 1)
 	go func() {
 		defer close(ch)
-		scanner := bufio.NewScanner(r)
-		for scanner.Scan() {
-			if ctx.Err() != nil {
-				ch <- Filevuep{err: ctx.Err()}
-				fmt.Println("ctx.err")
+		d := NewDecoder(r, opts...)
+		for {
+			if err := ctx.Err(); err != nil {
+				sendLast(ctx, ch, Result{Err: err})
 				return
 			}
-			v, err := GetViewpRec(scanner.Text())
+			v, err := d.Next()
+			if err == io.EOF {
+				return
+			}
+			select {
+			case ch <- Result{Filevuep: v, Err: err}:
+			case <-ctx.Done():
+				// the error of ctx is sent instead
+				continue
+			}
 			if err != nil {
-				v.err = err
-				fmt.Println("get view err")
-				ch <- v
 				return
 			}
-			ch <- v
 		}
 	}()
-	fmt.Println("ok decoding...")
-
 	return ch
 }
 
-func GetViewpRec(line string) (Filevuep, error) {
-	splited := strings.Fields(line)
-	if len(splited) != 5 {
-		return Filevuep{err: fmt.Errorf("error: %s", line)}, nil
-	} else {
-		pos, err := strconv.Atoi(splited[4])
-		if err != nil {
-			return Filevuep{err: fmt.Errorf("error: %s", line)}, nil
-
-		} else {
-			v := Filevuep{
-				Table:  strings.TrimSpace(splited[0]),
-				View:   strings.TrimSpace(splited[1]),
-				Method: strings.TrimSpace(splited[2]),
-				Path:   strings.TrimSpace(splited[3]),
-				Pos:    pos,
-				err:    nil,
-			}
-			res := strings.TrimSpace(v.Table) == "F100"
-			if res {
-				fmt.Println(v.View, " -- ", v.Method, " -- ", v.Path, " -- ", v.Table)
-			}
-			return v, nil
+// sendLast sends last, the final value, on ch, of which the caller is the
+// only sender. Once ctx is done the caller may have stopped reading, so a
+// value it left in the buffer is dropped to make room instead of blocking.
+func sendLast[T any](ctx context.Context, ch chan T, last T) {
+	select {
+	case ch <- last:
+		return
+	case <-ctx.Done():
+	}
+	select {
+	case ch <- last:
+	default:
+		select {
+		case <-ch:
+		default:
 		}
+		ch <- last
 	}
 }
+
+// whitespace parses GetViewpRec lines. It has no header so it is never
+// modified and can be shared.
+var whitespace = newLineParser(Whitespace)
+
+// GetViewpRec parses one line with the Whitespace schema. Errors are
+// *ParseError without a line number, the Decoder fills it in.
+func GetViewpRec(line string) (Filevuep, error) {
+	return whitespace.parse(line)
+}
@@ -0,0 +1,188 @@
+package streamz
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+)
+
+var (
+	// ErrFieldCount is the cause of a ParseError for a row that does not
+	// have the columns of the schema, Table View Method Path Pos by default.
+	ErrFieldCount = errors.New("wrong number of fields")
+	// ErrPos is the cause of a ParseError for a row whose Pos is not an integer.
+	ErrPos = errors.New("the Pos column is not an integer")
+)
+
+// ParseError is a row that could not be decoded.
+type ParseError struct {
+	// Line is the 1-based line number, 0 when parsing a lone line.
+	Line int
+	// Text is the raw line.
+	Text string
+	// Err is the cause, such as ErrFieldCount or ErrPos.
+	Err error
+}
+
+// Error implements error.Error().
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %v: %q", e.Line, e.Err, e.Text)
+	}
+	return fmt.Sprintf("%v: %q", e.Err, e.Text)
+}
+
+// Unwrap returns the cause.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Option configures a Decoder.
+type Option func(*Decoder)
+
+// Strict makes the decoder stop at the first bad row. This is the default.
+func Strict() Option {
+	return func(d *Decoder) { d.lenient = false }
+}
+
+// Lenient makes the decoder skip bad rows. onSkip, if not nil, is called
+// with every skipped row.
+func Lenient(onSkip func(*ParseError)) Option {
+	return func(d *Decoder) {
+		d.lenient = true
+		d.onSkip = onSkip
+	}
+}
+
+// WithSchema makes the decoder parse lines with s instead of Whitespace.
+func WithSchema(s Schema) Option {
+	return func(d *Decoder) { d.schema = s }
+}
+
+// Decoder reads Filevuep records from a FILEVUEP stream. Blank lines are
+// ignored.
+type Decoder struct {
+	s       *bufio.Scanner
+	lenient bool
+	onSkip  func(*ParseError)
+	schema  Schema
+	parser  *lineParser
+	// header is true until the header line of the schema has been read.
+	header bool
+
+	line    int
+	skipped int
+	// err is sticky: once set Next keeps returning it.
+	err error
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	d := &Decoder{s: bufio.NewScanner(r), schema: Whitespace}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if err := d.schema.Validate(); err != nil {
+		d.err = err
+	}
+	d.parser = newLineParser(d.schema)
+	d.header = d.schema.Header
+	return d
+}
+
+// Next returns the next record. It returns io.EOF at the end of the input
+// and a *ParseError for a bad row in strict mode or a bad header, after
+// which the decoder is done. An invalid schema is returned by the first
+// call.
+func (d *Decoder) Next() (Filevuep, error) {
+	for {
+		text, err := d.scan()
+		if err != nil {
+			return Filevuep{}, err
+		}
+		v, err := d.parser.parse(text)
+		if err == nil {
+			return v, nil
+		}
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.Line = d.line
+			err = d.skip(perr)
+		}
+		if err != nil {
+			d.err = err
+			return Filevuep{}, err
+		}
+	}
+}
+
+// scan returns the next row to parse, skipping blank lines and reading the
+// header. It returns io.EOF at the end of the input.
+func (d *Decoder) scan() (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	for d.s.Scan() {
+		d.line++
+		text := d.s.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if !d.header {
+			return text, nil
+		}
+		if err := d.parser.header(text); err != nil {
+			d.err = &ParseError{Line: d.line, Text: text, Err: err}
+			return "", d.err
+		}
+		d.header = false
+	}
+	d.err = d.s.Err()
+	if d.err == nil {
+		d.err = io.EOF
+	}
+	return "", d.err
+}
+
+// skip returns perr in strict mode. In lenient mode it counts the row and
+// hands it to onSkip.
+func (d *Decoder) skip(perr *ParseError) error {
+	if !d.lenient {
+		return perr
+	}
+	d.skipped++
+	if d.onSkip != nil {
+		d.onSkip(perr)
+	}
+	return nil
+}
+
+// All returns an iterator over the remaining records. A decoding error is
+// yielded once, with a zero Filevuep, and ends the iteration; io.EOF is
+// not yielded.
+func (d *Decoder) All() iter.Seq2[Filevuep, error] {
+	return func(yield func(Filevuep, error) bool) {
+		for {
+			v, err := d.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Line returns the number of the last line read.
+func (d *Decoder) Line() int {
+	return d.line
+}
+
+// Skipped returns how many bad rows were skipped in lenient mode.
+func (d *Decoder) Skipped() int {
+	return d.skipped
+}
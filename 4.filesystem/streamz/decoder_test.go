@@ -0,0 +1,117 @@
+package streamz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mixed = `F100 V1 LINKPATH F100LKS0 1
+
+F100 V2 LINKPATH
+F100 V3 INDEX F100IX01 x
+F100 V4 DIRECT F100DIRECTPATH 4
+`
+
+func TestDecoderStrict(t *testing.T) {
+	d := NewDecoder(strings.NewReader(mixed))
+	v, err := d.Next()
+	if err != nil || v != (Filevuep{Table: "F100", View: "V1", Method: "LINKPATH", Path: "F100LKS0", Pos: 1}) {
+		t.Fatalf("first row: got %+v, %v", v, err)
+	}
+	_, err = d.Next()
+	var perr *ParseError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrFieldCount) {
+		t.Fatalf("got %v, want a ParseError for ErrFieldCount", err)
+	}
+	// the blank line is counted
+	if perr.Line != 3 || perr.Text != "F100 V2 LINKPATH" || d.Line() != 3 {
+		t.Errorf("got line %d %q, decoder at %d, want line 3", perr.Line, perr.Text, d.Line())
+	}
+	if want := `line 3: wrong number of fields: "F100 V2 LINKPATH"`; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+	// the error is sticky
+	if _, again := d.Next(); again != err {
+		t.Errorf("after the error: got %v", again)
+	}
+}
+
+func TestDecoderLenient(t *testing.T) {
+	var skipped []*ParseError
+	d := NewDecoder(strings.NewReader(mixed), Lenient(func(e *ParseError) { skipped = append(skipped, e) }))
+	var views []string
+	for v, err := range d.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		views = append(views, v.View)
+	}
+	if strings.Join(views, " ") != "V1 V4" || d.Skipped() != 2 || len(skipped) != 2 {
+		t.Fatalf("got %q and %d skipped, want V1 V4 and 2", views, d.Skipped())
+	}
+	if skipped[0].Line != 3 || !errors.Is(skipped[0], ErrFieldCount) {
+		t.Errorf("first skipped: %v", skipped[0])
+	}
+	if skipped[1].Line != 4 || !errors.Is(skipped[1], ErrPos) {
+		t.Errorf("second skipped: %v", skipped[1])
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("at the end: got %v, want io.EOF", err)
+	}
+}
+
+func TestDecoderColumn(t *testing.T) {
+	in := "Table;View;Method;Path;Pos\nF100;V1;LINKPATH;;1\n"
+	_, err := NewDecoder(strings.NewReader(in), WithSchema(Semicolon)).Next()
+	var perr *ParseError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrMissing) || perr.Line != 2 {
+		t.Fatalf("got %v, want ErrMissing on line 2", err)
+	}
+	if !strings.HasPrefix(perr.Err.Error(), "Path: ") {
+		t.Errorf("got %v, want the Path column named", perr.Err)
+	}
+
+	if _, err := NewDecoder(strings.NewReader(in), WithSchema(Schema{})).Next(); !errors.Is(err, ErrSchema) {
+		t.Errorf("empty schema: got %v, want ErrSchema", err)
+	}
+	if _, err := GetViewpRec("F100 V1 LINKPATH F100LKS0 one"); !errors.Is(err, ErrPos) || err.Error() != `the Pos column is not an integer: "F100 V1 LINKPATH F100LKS0 one"` {
+		t.Errorf("GetViewpRec: got %v", err)
+	}
+}
+
+func TestDecodeFilevuepCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := DecodeFilevuep(ctx, strings.NewReader(strings.Repeat("F100 V1 LINKPATH F100LKS0 1\n", 1000)))
+	if r := <-ch; r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	// the caller stops reading, the goroutine must not stay blocked
+	cancel()
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatal("decoding goroutine still running after cancel")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a caller still reading gets the error of ctx last
+	for range 20 {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := DecodeFilevuep(ctx, strings.NewReader(strings.Repeat("F100 V1 LINKPATH F100LKS0 1\n", 1000)))
+		<-ch
+		cancel()
+		var last error
+		for r := range ch {
+			last = r.Err
+		}
+		if !errors.Is(last, context.Canceled) {
+			t.Fatalf("last result: got %v, want context.Canceled", last)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package streamz
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrInvalidField is the cause of an encoding error for a field that the
+	// decoder could not read back: empty or containing whitespace, or in
+	// fixed width mode starting or ending with whitespace or holding a line
+	// break.
+	ErrInvalidField = errors.New("field is empty or contains whitespace")
+	// ErrTooWide is the cause of an encoding error for a value wider than
+	// its column in fixed width mode.
+	ErrTooWide = errors.New("value is wider than its column")
+)
+
+// Layout is the width of each column in the canonical FILEVUEP layout.
+type Layout struct {
+	Table  int
+	View   int
+	Method int
+	Path   int
+	Pos    int
+}
+
+// DefaultLayout is the layout of the mainframe extracts.
+var DefaultLayout = Layout{Table: 8, View: 32, Method: 8, Path: 8, Pos: 5}
+
+// Encoder writes Filevuep records in the canonical layout: Table, View,
+// Method and Path left aligned and padded to their width, each followed by
+// a space, then Pos right aligned in its width, with no trailing space and
+// "\n" line endings. A value wider than its column is written as is
+// followed by a single space, unless the Encoder is in fixed width mode.
+//
+// Decoding canonical input and encoding the records again with the same
+// Layout gives back the input byte for byte.
+type Encoder struct {
+	w      io.Writer
+	layout Layout
+	fixed  bool
+	buf    []byte
+}
+
+// NewEncoder returns an Encoder writing to w with DefaultLayout. Each
+// record is written with a single Write call.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, layout: DefaultLayout}
+}
+
+// SetLayout changes the column widths of the following records.
+func (e *Encoder) SetLayout(l Layout) {
+	e.layout = l
+}
+
+// SetFixed makes Encode return an error wrapping ErrTooWide for a Table,
+// View, Method or Path wider than its column, so the output can be read
+// with the FixedWidth schema of the layout. The columns being cut by
+// position, values may then hold spaces, but not start or end with one.
+func (e *Encoder) SetFixed(fixed bool) {
+	e.fixed = fixed
+}
+
+// Encode writes one record.
+func (e *Encoder) Encode(v Filevuep) error {
+	b, err := appendFilevuep(e.buf[:0], v, e.layout, e.fixed)
+	if err != nil {
+		return err
+	}
+	e.buf = b
+	_, err = e.w.Write(b)
+	return err
+}
+
+// AppendFilevuep appends the canonical line for v, "\n" included, to b.
+func AppendFilevuep(b []byte, v Filevuep, l Layout) ([]byte, error) {
+	return appendFilevuep(b, v, l, false)
+}
+
+func appendFilevuep(b []byte, v Filevuep, l Layout, fixed bool) ([]byte, error) {
+	cols := []struct {
+		name  string
+		value string
+		width int
+	}{
+		{"Table", v.Table, l.Table},
+		{"View", v.View, l.View},
+		{"Method", v.Method, l.Method},
+		{"Path", v.Path, l.Path},
+	}
+	for _, c := range cols {
+		if !readable(c.value, fixed) {
+			return b, fmt.Errorf("%s %q: %w", c.name, c.value, ErrInvalidField)
+		}
+		if fixed && len(c.value) > c.width {
+			return b, fmt.Errorf("%s %q is %d bytes, the column %d: %w", c.name, c.value, len(c.value), c.width, ErrTooWide)
+		}
+		b = append(b, c.value...)
+		for n := len(c.value); n < c.width; n++ {
+			b = append(b, ' ')
+		}
+		b = append(b, ' ')
+	}
+	pos := strconv.Itoa(v.Pos)
+	for n := len(pos); n < l.Pos; n++ {
+		b = append(b, ' ')
+	}
+	b = append(b, pos...)
+	return append(b, '\n'), nil
+}
+
+// readable reports whether the decoder reads value back: a value without
+// whitespace, or in fixed width mode one that is not trimmed and stays on
+// its line.
+func readable(value string, fixed bool) bool {
+	if value == "" {
+		return false
+	}
+	if !fixed {
+		return strings.IndexFunc(value, unicode.IsSpace) < 0
+	}
+	return strings.TrimSpace(value) == value && !strings.ContainsAny(value, "\r\n")
+}
@@ -0,0 +1,175 @@
+package streamz
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestRoundTrip(t *testing.T) {
+	in, err := os.ReadFile("testdata/FILEVUEP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	for v, err := range NewDecoder(bytes.NewReader(in)).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(in, out.Bytes()) {
+		t.Errorf("round trip changed the file:\n got:\n%s\nwant:\n%s", out.Bytes(), in)
+	}
+}
+
+func TestEncodeInvalidField(t *testing.T) {
+	for _, v := range []Filevuep{
+		{Table: "", View: "V", Method: "M", Path: "P"},
+		{Table: "T", View: "TWO WORDS", Method: "M", Path: "P"},
+		{Table: "T", View: "V", Method: "M", Path: "P\t"},
+	} {
+		err := NewEncoder(&bytes.Buffer{}).Encode(v)
+		if !errors.Is(err, ErrInvalidField) {
+			t.Errorf("Encode(%+v) = %v, want ErrInvalidField", v, err)
+		}
+	}
+}
+
+func TestEncodeFixed(t *testing.T) {
+	in, err := os.ReadFile("testdata/FILEVUEP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	enc.SetFixed(true)
+	var wide []string
+	for v, err := range NewDecoder(bytes.NewReader(in)).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(v); errors.Is(err, ErrTooWide) {
+			wide = append(wide, err.Error())
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the 39 character view does not fit the 32 of DefaultLayout
+	want := []string{
+		`View "CUST-ACCOUNT-HISTORY-BY-BRANCH-AND-DATE" is 39 bytes, the column 32: value is wider than its column`,
+		`Path "F300DIRECTPATH" is 14 bytes, the column 8: value is wider than its column`,
+	}
+	if strings.Join(wide, "\n") != strings.Join(want, "\n") {
+		t.Errorf("too wide: got %q", wide)
+	}
+
+	// what was written reads back with the fixed width schema
+	var n int
+	for _, err := range NewDecoder(&out, WithSchema(FixedWidth(DefaultLayout))).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if want := bytes.Count(in, []byte("\n")) - len(wide); n != want {
+		t.Errorf("read back %d records, want %d", n, want)
+	}
+
+	enc.SetLayout(Layout{Table: 4, View: 2, Method: 8, Path: 8, Pos: 1})
+	err = enc.Encode(Filevuep{Table: "F100", View: "V1", Method: "LINKPATH", Path: "F100LKS0X", Pos: 1})
+	if !errors.Is(err, ErrTooWide) || !strings.HasPrefix(err.Error(), "Path ") {
+		t.Errorf("wide path: got %v, want ErrTooWide", err)
+	}
+}
+
+func FuzzGetViewpRec(f *testing.F) {
+	for _, seed := range []string{
+		"F100     LBRA-CON-FSB-001                 LINKPATH F100LKS0     1",
+		"F100 V LINKPATH P 2",
+		"F100 V LINKPATH P -3",
+		"F100 V LINKPATH P x",
+		"F100 V LINKPATH P",
+		"a b c d e f",
+		"",
+		"\t\x00   1",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		v, err := GetViewpRec(line)
+		if err != nil {
+			var perr *ParseError
+			if !errors.As(err, &perr) || perr.Text != line {
+				t.Fatalf("GetViewpRec(%q) error %v is not a ParseError for the line", line, err)
+			}
+			return
+		}
+		for _, field := range []string{v.Table, v.View, v.Method, v.Path} {
+			if field == "" || strings.IndexFunc(field, unicode.IsSpace) >= 0 {
+				t.Fatalf("GetViewpRec(%q) gave field %q", line, field)
+			}
+		}
+
+		// whatever was parsed encodes canonically and reads back the same
+		b, err := AppendFilevuep(nil, v, DefaultLayout)
+		if err != nil {
+			t.Fatalf("AppendFilevuep(%+v): %v", v, err)
+		}
+		again, err := GetViewpRec(strings.TrimSuffix(string(b), "\n"))
+		if err != nil {
+			t.Fatalf("GetViewpRec(%q): %v", b, err)
+		}
+		if again != v {
+			t.Fatalf("decoded %+v, encoded %q, decoded again %+v", v, b, again)
+		}
+		b2, _ := AppendFilevuep(nil, again, DefaultLayout)
+		if !bytes.Equal(b, b2) {
+			t.Fatalf("canonical encoding is not stable: %q then %q", b, b2)
+		}
+	})
+}
+
+func TestEncodeFixedRoundTrip(t *testing.T) {
+	recs := []Filevuep{
+		{Table: "VUEP01", View: "CLIENTS BY NAME", Method: "SEQ", Path: "/d c", Pos: 1},
+		{Table: "VUEP01", View: "ORDERS", Method: "IDX", Path: "/data", Pos: 42},
+	}
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	enc.SetFixed(true)
+	for _, v := range recs {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []Filevuep
+	for v, err := range NewDecoder(&out, WithSchema(FixedWidth(DefaultLayout))).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, recs) {
+		t.Errorf("got %+v, want %+v", got, recs)
+	}
+
+	for _, v := range []Filevuep{
+		{Table: "", View: "V", Method: "M", Path: "P"},
+		{Table: "T", View: " V", Method: "M", Path: "P"},
+		{Table: "T", View: "V", Method: "M", Path: "P "},
+		{Table: "T", View: "TWO\nLINES", Method: "M", Path: "P"},
+	} {
+		if err := enc.Encode(v); !errors.Is(err, ErrInvalidField) {
+			t.Errorf("Encode(%+v) = %v, want ErrInvalidField", v, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package streamz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Pipeline decodes large FILEVUEP files on several goroutines: a reader
+// cuts the input into batches of lines, Workers goroutines parse the
+// batches and a re-sequencer sends the records in input order, a batch at
+// a time. It pays off with several CPUs, on a single one a Decoder is
+// faster. The zero Pipeline uses the defaults below.
+type Pipeline struct {
+	// Workers is the number of parsing goroutines, GOMAXPROCS by default.
+	Workers int
+	// BatchSize is the number of lines handed to a worker at once, 1024 by
+	// default.
+	BatchSize int
+	// Buffer is the number of batches read ahead of the one being sent,
+	// twice Workers by default. It bounds the memory used by the pipeline.
+	Buffer int
+	// OutBuffer is the capacity of the returned channel in batches, 1 by
+	// default.
+	OutBuffer int
+}
+
+// batch is a run of lines going through the pipeline. results carries the
+// parsed batch, or the read error that ended the input, to the
+// re-sequencer.
+type batch struct {
+	lines   []string
+	nums    []int
+	parsed  []Result
+	err     error
+	results chan *batch
+}
+
+// Decode works as DecodeFilevuep and takes the same options, but sends
+// the records in slices of up to BatchSize, which saves a channel
+// operation per record. Only the last Result of the last slice may have
+// Err set. In lenient mode onSkip is called from a single goroutine in
+// input order.
+func (p Pipeline) Decode(ctx context.Context, r io.Reader, opts ...Option) <-chan []Result {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	size := p.BatchSize
+	if size <= 0 {
+		size = 1024
+	}
+	buffer := p.Buffer
+	if buffer <= 0 {
+		buffer = 2 * workers
+	}
+
+	out := make(chan []Result, max(p.OutBuffer, 1))
+	d := NewDecoder(r, opts...)
+	ctx, cancel := context.WithCancel(ctx)
+
+	// order holds the batches in input order, jobs the same batches for
+	// the workers. order's capacity is the read ahead.
+	order := make(chan *batch, buffer)
+	jobs := make(chan *batch, buffer)
+	go p.read(ctx, d, size, order, jobs)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				if ctx.Err() == nil {
+					b.parse(d.parser)
+				}
+				b.results <- b
+			}
+		}()
+	}
+
+	go func() {
+		defer close(out)
+		defer wg.Wait()
+		defer cancel()
+		if err := d.resequence(ctx, order, out); err != nil {
+			sendLast(ctx, out, []Result{{Err: err}})
+		}
+	}()
+	return out
+}
+
+// read sends the lines of d's input in batches to order and jobs. The
+// header, if any, is read here so the parser is set up before any batch
+// is parsed.
+func (p Pipeline) read(ctx context.Context, d *Decoder, size int, order, jobs chan<- *batch) {
+	defer close(order)
+	defer close(jobs)
+
+	send := func(b *batch, parse bool) bool {
+		b.results = make(chan *batch, 1)
+		select {
+		case order <- b:
+		case <-ctx.Done():
+			return false
+		}
+		if !parse {
+			b.results <- b
+			return true
+		}
+		select {
+		case jobs <- b:
+			return true
+		case <-ctx.Done():
+			// the re-sequencer may be waiting on it
+			b.results <- b
+			return false
+		}
+	}
+
+	b := &batch{}
+	for {
+		text, err := d.scan()
+		if err != nil {
+			if len(b.lines) > 0 && !send(b, true) {
+				return
+			}
+			if err != io.EOF {
+				send(&batch{err: err}, false)
+			}
+			return
+		}
+		b.lines = append(b.lines, text)
+		b.nums = append(b.nums, d.line)
+		if len(b.lines) == size {
+			if !send(b, true) {
+				return
+			}
+			b = &batch{}
+		}
+	}
+}
+
+// parse fills b.parsed, stamping parse errors with their line number.
+func (b *batch) parse(p *lineParser) {
+	b.parsed = make([]Result, len(b.lines))
+	for i, line := range b.lines {
+		v, err := p.parse(line)
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.Line = b.nums[i]
+		}
+		b.parsed[i] = Result{Filevuep: v, Err: err}
+	}
+	b.lines = nil
+}
+
+// resequence sends the records of the batches to out in input order and
+// returns the error that ends the decoding, nil at the end of the input.
+func (d *Decoder) resequence(ctx context.Context, order <-chan *batch, out chan<- []Result) error {
+	for next := range order {
+		var b *batch
+		select {
+		case b = <-next.results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if b.err != nil {
+			return b.err
+		}
+		// the records are moved down over the skipped rows
+		recs := b.parsed[:0]
+		var err error
+		for _, res := range b.parsed {
+			if res.Err == nil {
+				recs = append(recs, res)
+				continue
+			}
+			var perr *ParseError
+			if err = res.Err; errors.As(err, &perr) {
+				err = d.skip(perr)
+			}
+			if err != nil {
+				break
+			}
+		}
+		if len(recs) > 0 {
+			select {
+			case out <- recs:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
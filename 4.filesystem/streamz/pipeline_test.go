@@ -0,0 +1,171 @@
+package streamz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// extract returns n canonical lines, every bad-th of them invalid when
+// bad is not 0.
+func extract(n, bad int) []byte {
+	var b []byte
+	for i := range n {
+		if bad > 0 && i%bad == bad-1 {
+			b = append(b, "F100 BROKEN\n"...)
+			continue
+		}
+		v := Filevuep{Table: "F100", View: fmt.Sprintf("LBRA-CON-FSB-%06d", i), Method: "LINKPATH", Path: "F100LKS0", Pos: i}
+		b, _ = AppendFilevuep(b, v, DefaultLayout)
+	}
+	return b
+}
+
+func collect(ch <-chan Result) ([]Filevuep, error) {
+	var vs []Filevuep
+	for r := range ch {
+		if r.Err != nil {
+			return vs, r.Err
+		}
+		vs = append(vs, r.Filevuep)
+	}
+	return vs, nil
+}
+
+// collectBatches is collect for the batches of a Pipeline, checking that
+// they hold at most size records and that only the last Result has Err.
+func collectBatches(t *testing.T, ch <-chan []Result, size int) ([]Filevuep, error) {
+	var vs []Filevuep
+	var err error
+	for batch := range ch {
+		if err != nil {
+			t.Fatalf("batch after the error %v", err)
+		}
+		if len(batch) == 0 || len(batch) > size {
+			t.Fatalf("batch of %d records, want 1 to %d", len(batch), size)
+		}
+		for i, r := range batch {
+			if r.Err != nil {
+				if i != len(batch)-1 {
+					t.Fatalf("error %v is not the last of its batch", r.Err)
+				}
+				err = r.Err
+				break
+			}
+			vs = append(vs, r.Filevuep)
+		}
+	}
+	return vs, err
+}
+
+func TestPipelineOrder(t *testing.T) {
+	in := extract(10000, 7)
+	for _, lenient := range []bool{false, true} {
+		var want, got []int
+		opts := func(skipped *[]int) []Option {
+			if !lenient {
+				return nil
+			}
+			return []Option{Lenient(func(e *ParseError) { *skipped = append(*skipped, e.Line) })}
+		}
+		wantVs, wantErr := collect(DecodeFilevuep(context.Background(), bytes.NewReader(in), opts(&want)...))
+		p := Pipeline{Workers: 4, BatchSize: 10, Buffer: 3}
+		gotVs, gotErr := collectBatches(t, p.Decode(context.Background(), bytes.NewReader(in), opts(&got)...), p.BatchSize)
+		if !reflect.DeepEqual(gotVs, wantVs) || !reflect.DeepEqual(got, want) {
+			t.Errorf("lenient %v: pipeline gave %d records and skipped %v, want %d and %v", lenient, len(gotVs), got, len(wantVs), want)
+		}
+		if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+			t.Errorf("lenient %v: pipeline error %v, want %v", lenient, gotErr, wantErr)
+		}
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := Pipeline{Workers: 2, BatchSize: 16}.Decode(ctx, bytes.NewReader(extract(100000, 0)))
+	<-ch
+	// the caller stops reading, every goroutine of the pipeline must end
+	cancel()
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatal("pipeline goroutines still running after cancel")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := collectBatches(t, ch, 16); !errors.Is(err, context.Canceled) {
+		t.Errorf("after cancel got %v, want context.Canceled", err)
+	}
+}
+
+func TestPipelineHeader(t *testing.T) {
+	in := "Table;View;Method;Path;Pos\n\nF100;V1;LINKPATH;P;1\nF100;V2;LINKPATH;P;x\nF100;V3;LINKPATH;P;3\n"
+	p := Pipeline{Workers: 2, BatchSize: 2}
+	vs, err := collectBatches(t, p.Decode(context.Background(), strings.NewReader(in), WithSchema(Semicolon)), 2)
+	var perr *ParseError
+	if len(vs) != 1 || !errors.As(err, &perr) || perr.Line != 4 {
+		t.Errorf("got %v and %v, want V1 and an error on line 4", vs, err)
+	}
+	_, err = collectBatches(t, p.Decode(context.Background(), strings.NewReader("Table;View\nF100;V1\n"), WithSchema(Semicolon)), 2)
+	if !errors.Is(err, ErrHeader) {
+		t.Errorf("bad header: got %v, want ErrHeader", err)
+	}
+}
+
+func benchmarkInput(b *testing.B) []byte {
+	in := extract(200000, 0)
+	b.SetBytes(int64(len(in)))
+	b.ResetTimer()
+	return in
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	in := benchmarkInput(b)
+	for range b.N {
+		d := NewDecoder(bytes.NewReader(in))
+		for {
+			if _, err := d.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// the benchmarks only look at the records, as a caller streaming them
+// would, so that the slice of collect is not measured.
+
+func BenchmarkDecodeFilevuep(b *testing.B) {
+	in := benchmarkInput(b)
+	for range b.N {
+		for r := range DecodeFilevuep(context.Background(), bytes.NewReader(in)) {
+			if r.Err != nil {
+				b.Fatal(r.Err)
+			}
+		}
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			in := benchmarkInput(b)
+			p := Pipeline{Workers: workers}
+			for range b.N {
+				for batch := range p.Decode(context.Background(), bytes.NewReader(in)) {
+					if err := batch[len(batch)-1].Err; err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package streamz
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrMissing is the cause of a ParseError for a required column left empty.
+	ErrMissing = errors.New("required column is empty")
+	// ErrNotInt is the cause of a ParseError for an integer column, other
+	// than Pos, that does not hold an integer.
+	ErrNotInt = errors.New("column is not an integer")
+	// ErrHeader is the cause of a ParseError for a header line that does
+	// not match the schema. It stops the decoder even in lenient mode.
+	ErrHeader = errors.New("header does not match the schema")
+	// ErrSchema is returned for a Schema that cannot be used.
+	ErrSchema = errors.New("invalid schema")
+)
+
+// ColumnType is what a column's value must parse as.
+type ColumnType int
+
+const (
+	TypeString ColumnType = iota
+	TypeInt
+)
+
+// Column describes one column of a FILEVUEP variant.
+type Column struct {
+	// Name is the Filevuep field the column fills: Table, View, Method,
+	// Path or Pos, case insensitive. Columns with another name are checked
+	// and then ignored. With a header, Name is matched against it.
+	Name string
+	// Width is the column width in bytes for fixed width schemas. The last
+	// column may have a zero Width to take the rest of the line.
+	Width int
+	Type  ColumnType
+	// Optional columns may be empty or missing at the end of the row.
+	Optional bool
+}
+
+// Schema describes how to split a line into columns. Without Delim and
+// Fixed, columns are separated by runs of whitespace.
+type Schema struct {
+	Columns []Column
+	// Delim separates the columns, e.g. ';' or '\t'. Values are trimmed.
+	Delim rune
+	// Fixed slices columns by their Width, which lets values hold spaces.
+	// Values are trimmed.
+	Fixed bool
+	// Header means the first non blank line names the columns, in any
+	// order. Columns of the header that are not in the schema are ignored.
+	Header bool
+}
+
+func filevuepColumns() []Column {
+	return []Column{
+		{Name: "Table"},
+		{Name: "View"},
+		{Name: "Method"},
+		{Name: "Path"},
+		{Name: "Pos", Type: TypeInt},
+	}
+}
+
+var (
+	// Whitespace is the original FILEVUEP format, Table View Method Path
+	// Pos separated by whitespace. It is the Decoder's default.
+	Whitespace = Schema{Columns: filevuepColumns()}
+	// Semicolon is for ;-delimited exports with a header line.
+	Semicolon = Schema{Columns: filevuepColumns(), Delim: ';', Header: true}
+	// Tab is for tab-delimited exports with a header line.
+	Tab = Schema{Columns: filevuepColumns(), Delim: '\t', Header: true}
+)
+
+// FixedWidth returns the fixed width schema matching the layout the
+// Encoder writes, the space after each column being part of its width.
+func FixedWidth(l Layout) Schema {
+	cols := filevuepColumns()
+	for i, w := range []int{l.Table, l.View, l.Method, l.Path} {
+		cols[i].Width = w + 1
+	}
+	return Schema{Columns: cols, Fixed: true}
+}
+
+// Validate reports whether s can be used to parse lines.
+func (s Schema) Validate() error {
+	if len(s.Columns) == 0 {
+		return fmt.Errorf("%w: no columns", ErrSchema)
+	}
+	if s.Fixed && (s.Delim != 0 || s.Header) {
+		return fmt.Errorf("%w: fixed width columns cannot have a delimiter or a header", ErrSchema)
+	}
+	seen := map[string]bool{}
+	for i, c := range s.Columns {
+		name := strings.ToLower(c.Name)
+		if name == "" || seen[name] {
+			return fmt.Errorf("%w: column %d has an empty or duplicate name %q", ErrSchema, i+1, c.Name)
+		}
+		seen[name] = true
+		if s.Fixed && c.Width <= 0 && i != len(s.Columns)-1 {
+			return fmt.Errorf("%w: column %s needs a width", ErrSchema, c.Name)
+		}
+		if name == "pos" && c.Type != TypeInt {
+			return fmt.Errorf("%w: column Pos must be TypeInt", ErrSchema)
+		}
+	}
+	return nil
+}
+
+// lineParser parses the lines of one input. index maps the cells of a row
+// to the schema columns, -1 for cells to ignore. names are the lower case
+// column names.
+type lineParser struct {
+	schema Schema
+	index  []int
+	names  []string
+}
+
+func newLineParser(s Schema) *lineParser {
+	p := &lineParser{schema: s, index: make([]int, len(s.Columns)), names: make([]string, len(s.Columns))}
+	for i, c := range s.Columns {
+		p.index[i] = i
+		p.names[i] = strings.ToLower(c.Name)
+	}
+	return p
+}
+
+// header maps the columns named in the header line.
+func (p *lineParser) header(line string) error {
+	cells := p.split(line)
+	p.index = make([]int, len(cells))
+	found := make([]bool, len(p.schema.Columns))
+	for i, cell := range cells {
+		p.index[i] = -1
+		for j, c := range p.schema.Columns {
+			if strings.EqualFold(cell, c.Name) {
+				if found[j] {
+					return fmt.Errorf("%w: column %s appears twice", ErrHeader, c.Name)
+				}
+				p.index[i], found[j] = j, true
+			}
+		}
+	}
+	for j, c := range p.schema.Columns {
+		if !found[j] && !c.Optional {
+			return fmt.Errorf("%w: required column %s is missing", ErrHeader, c.Name)
+		}
+	}
+	return nil
+}
+
+// split cuts line into trimmed cells.
+func (p *lineParser) split(line string) []string {
+	s := p.schema
+	switch {
+	case s.Fixed:
+		var cells []string
+		for i, c := range s.Columns {
+			if line == "" {
+				break
+			}
+			w := c.Width
+			if w <= 0 || w > len(line) || i == len(s.Columns)-1 {
+				w = len(line)
+			}
+			cells = append(cells, strings.TrimSpace(line[:w]))
+			line = line[w:]
+		}
+		return cells
+	case s.Delim != 0:
+		cells := strings.Split(line, string(s.Delim))
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		return cells
+	default:
+		return strings.Fields(line)
+	}
+}
+
+// parse turns one line into a Filevuep. Errors are *ParseError without a
+// line number.
+func (p *lineParser) parse(line string) (Filevuep, error) {
+	cells := p.split(line)
+	if len(cells) > len(p.index) {
+		return Filevuep{}, &ParseError{Text: line, Err: ErrFieldCount}
+	}
+	// the usual schemas fit in the arrays, which stay on the stack
+	var valuesBuf [8]string
+	var presentBuf [8]bool
+	values, present := valuesBuf[:0], presentBuf[:0]
+	values = append(values, make([]string, len(p.schema.Columns))...)
+	present = append(present, make([]bool, len(p.schema.Columns))...)
+	for i, cell := range cells {
+		if j := p.index[i]; j >= 0 {
+			values[j], present[j] = cell, true
+		}
+	}
+
+	var v Filevuep
+	for j, c := range p.schema.Columns {
+		val := values[j]
+		if val == "" {
+			if c.Optional {
+				continue
+			}
+			if !present[j] {
+				return Filevuep{}, &ParseError{Text: line, Err: ErrFieldCount}
+			}
+			return Filevuep{}, &ParseError{Text: line, Err: fmt.Errorf("%s: %w", c.Name, ErrMissing)}
+		}
+		n := 0
+		if c.Type == TypeInt {
+			var err error
+			if n, err = strconv.Atoi(val); err != nil {
+				if p.names[j] == "pos" {
+					return Filevuep{}, &ParseError{Text: line, Err: ErrPos}
+				}
+				return Filevuep{}, &ParseError{Text: line, Err: fmt.Errorf("%s: %w", c.Name, ErrNotInt)}
+			}
+		}
+		switch p.names[j] {
+		case "table":
+			v.Table = val
+		case "view":
+			v.View = val
+		case "method":
+			v.Method = val
+		case "path":
+			v.Path = val
+		case "pos":
+			v.Pos = n
+		}
+	}
+	return v, nil
+}
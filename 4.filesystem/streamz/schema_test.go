@@ -0,0 +1,133 @@
+package streamz
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSchemas(t *testing.T) {
+	v1 := Filevuep{Table: "F100", View: "LBRA CON 1", Method: "LINKPATH", Path: "F100LKS0", Pos: 1}
+	v2 := Filevuep{Table: "F300", View: "CUST-ACCOUNT-002", Method: "DIRECT", Path: "F300DP", Pos: 12}
+	withRegion := Schema{
+		Columns: []Column{{Name: "Table"}, {Name: "Region"}, {Name: "View"}, {Name: "Method"}, {Name: "Path"}, {Name: "Pos", Type: TypeInt}, {Name: "Note", Optional: true}},
+		Delim:   ',',
+	}
+	for _, tc := range []struct {
+		name   string
+		schema Schema
+		in     string
+		want   []Filevuep
+		bad    string
+		err    error
+	}{
+		{
+			name:   "fixed width",
+			schema: FixedWidth(Layout{Table: 4, View: 16, Method: 8, Path: 8, Pos: 3}),
+			in:     "F100 LBRA CON 1       LINKPATH F100LKS0   1\nF300 CUST-ACCOUNT-002 DIRECT   F300DP    12\n",
+			want:   []Filevuep{v1, v2},
+			bad:    "F300 CUST-ACCOUNT-002 DIRECT   F300DP    1x",
+			err:    ErrPos,
+		},
+		{
+			name:   "fixed width short row",
+			schema: FixedWidth(Layout{Table: 4, View: 16, Method: 8, Path: 8, Pos: 3}),
+			bad:    "F300 CUST-ACCOUNT-002 DIRECT",
+			err:    ErrFieldCount,
+		},
+		{
+			name:   "semicolon",
+			schema: Semicolon,
+			in:     "Table;View;Method;Path;Pos\nF100 ; LBRA CON 1;LINKPATH;F100LKS0;1\nF300;CUST-ACCOUNT-002;DIRECT;F300DP;12\n",
+			want:   []Filevuep{v1, v2},
+			bad:    "F300;CUST-ACCOUNT-002;DIRECT;F300DP;12;extra",
+			err:    ErrFieldCount,
+		},
+		{
+			name:   "tab",
+			schema: Tab,
+			in:     "Table\tView\tMethod\tPath\tPos\nF100\tLBRA CON 1\tLINKPATH\tF100LKS0\t1\n",
+			want:   []Filevuep{v1},
+			bad:    "F100\t\tLINKPATH\tF100LKS0\t1",
+			err:    ErrMissing,
+		},
+		{
+			name:   "header in any order",
+			schema: Semicolon,
+			in:     "pos;PATH;Owner;view;method;table\n12;F300DP;ops;CUST-ACCOUNT-002;DIRECT;F300\n",
+			want:   []Filevuep{v2},
+			bad:    "x;F300DP;ops;CUST-ACCOUNT-002;DIRECT;F300",
+			err:    ErrPos,
+		},
+		{
+			name:   "extra columns",
+			schema: withRegion,
+			in:     "F100,EU,LBRA CON 1,LINKPATH,F100LKS0,1,first\nF300,US,CUST-ACCOUNT-002,DIRECT,F300DP,12\n",
+			want:   []Filevuep{v1, v2},
+			bad:    "F300,,CUST-ACCOUNT-002,DIRECT,F300DP,12",
+			err:    ErrMissing,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			header := ""
+			if tc.schema.Header {
+				header, _, _ = strings.Cut(tc.in, "\n")
+				header += "\n"
+			}
+			var got []Filevuep
+			for v, err := range NewDecoder(strings.NewReader(tc.in), WithSchema(tc.schema)).All() {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, v)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("record %d: got %+v, want %+v", i+1, got[i], tc.want[i])
+				}
+			}
+
+			// the bad row follows the header, on line 2 when there is one
+			_, err := NewDecoder(strings.NewReader(header+tc.bad+"\n"), WithSchema(tc.schema)).Next()
+			var perr *ParseError
+			if !errors.As(err, &perr) || !errors.Is(err, tc.err) || perr.Text != tc.bad {
+				t.Fatalf("bad row: got %v, want %v", err, tc.err)
+			}
+			if want := strings.Count(header, "\n") + 1; perr.Line != want {
+				t.Errorf("bad row: line %d, want %d", perr.Line, want)
+			}
+		})
+	}
+}
+
+func TestSchemaHeader(t *testing.T) {
+	for _, header := range []string{
+		"Table;View;Method;Path",
+		"Table;View;View;Method;Path;Pos",
+	} {
+		_, err := NewDecoder(strings.NewReader(header+"\nF100;V1;LINKPATH;P;1\n"), WithSchema(Semicolon), Lenient(nil)).Next()
+		if !errors.Is(err, ErrHeader) {
+			t.Errorf("%s: got %v, want ErrHeader", header, err)
+		}
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	for _, s := range []Schema{
+		{},
+		{Columns: []Column{{Name: "Table"}, {Name: "table"}}},
+		{Columns: []Column{{Name: "Table"}, {Name: "Pos"}}},
+		{Columns: []Column{{Name: "Table"}, {Name: "View", Width: 4}}, Fixed: true},
+		{Columns: []Column{{Name: "Table", Width: 4}}, Fixed: true, Delim: ';'},
+	} {
+		if err := s.Validate(); !errors.Is(err, ErrSchema) {
+			t.Errorf("%+v: got %v, want ErrSchema", s, err)
+		}
+	}
+	if err := FixedWidth(DefaultLayout).Validate(); err != nil {
+		t.Errorf("FixedWidth: %v", err)
+	}
+}
@@ -1,16 +1,19 @@
+// Package dataformats reads and writes records as CSV, the columns of a
+// record being the typed fields of a struct, see Reader, and validates
+// them, see Rules.
 package dataformats
 
-import (
-	"errors"
-)
+// Name is a name record, the columns of the name files.
+type Name struct {
+	First string `csv:"first"`
+	Last  string `csv:"last"`
+}
 
 type record []string
 
+// Validate checks that r holds the columns of Name, in order.
 func (r record) Validate() error {
-	if len(r) != 2 {
-		return errors.New("data format incorrect")
-	}
-	return nil
+	return validateFields[Name](r)
 }
 func (r record) first() string {
 	return r[0]
@@ -0,0 +1,221 @@
+package dataformats
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrType is returned, wrapped, by the first Read or Write of a Reader or
+// Writer whose record type cannot be mapped to columns.
+var ErrType = errors.New("unsupported record type")
+
+// FieldError is a field of a row that does not parse as the type of its
+// column.
+type FieldError struct {
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("column %s: %q: %v", e.Column, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// column is a field of a record struct mapped to a CSV column.
+type column struct {
+	name  string
+	field int
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// columnsOf returns the columns of the record struct t, one per exported
+// field in order, named by the csv tag of the field or else by its name.
+// Fields tagged csv:"-" are not mapped.
+func columnsOf(t reflect.Type) ([]column, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %v is not a struct", ErrType, t)
+	}
+	var cols []column
+	seen := map[string]bool{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("csv"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !supported(f.Type) {
+			return nil, fmt.Errorf("%w: field %s of %v is a %v", ErrType, f.Name, t, f.Type)
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%w: two columns of %v are named %s", ErrType, t, name)
+		}
+		seen[strings.ToLower(name)] = true
+		cols = append(cols, column{name: name, field: i})
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("%w: %v has no exported field", ErrType, t)
+	}
+	return cols, nil
+}
+
+// supported reports whether a field of type t can hold a column: strings,
+// booleans, numbers, time.Duration, and the types implementing
+// encoding.TextUnmarshaler, such as time.Time.
+func supported(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parse sets v, an addressable field, to the value of s. An empty s is the
+// zero value. Other than strings, values are trimmed.
+func (c column) parse(s string, v reflect.Value) error {
+	if v.Kind() != reflect.String {
+		s = strings.TrimSpace(s)
+	}
+	if s == "" {
+		v.SetZero()
+		return nil
+	}
+	err := parseValue(s, v)
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		// the value is in the FieldError already
+		err = numErr.Err
+	}
+	if err != nil {
+		return &FieldError{Column: c.name, Value: s, Err: err}
+	}
+	return nil
+}
+
+func parseValue(s string, v reflect.Value) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		v.SetInt(int64(d))
+		return err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		v.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		v.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		v.SetUint(n)
+		return err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		v.SetFloat(f)
+		return err
+	}
+	return nil
+}
+
+// format returns the text of the field v, addressable.
+func (c column) format(v reflect.Value) (string, error) {
+	if m, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", c.name, err)
+		}
+		return string(b), nil
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return v.String(), nil
+}
+
+// decodeFields returns the record of fields, whose i-th field is the
+// column pos[i] of cols, or is ignored when pos[i] is -1, and checks the
+// rules of valid, which may be nil, for the columns that parsed, an empty
+// field being a value not set. The errors of all the fields are returned,
+// joined.
+func decodeFields[T any](cols []column, pos []int, fields []string, valid *validator) (T, error) {
+	var rec T
+	v := reflect.ValueOf(&rec).Elem()
+	var errs []error
+	bad, set := make([]bool, len(cols)), make([]bool, len(cols))
+	for i, f := range fields {
+		if pos[i] < 0 {
+			continue
+		}
+		c := cols[pos[i]]
+		set[pos[i]] = f != ""
+		if err := c.parse(f, v.Field(c.field)); err != nil {
+			errs = append(errs, err)
+			bad[pos[i]] = true
+		}
+	}
+	errs = append(errs, valid.check(v, bad, set)...)
+	return rec, errors.Join(errs...)
+}
+
+// validateFields checks that fields are the columns of T, in order, that
+// break none of the rules of T, and returns the errors of all the fields,
+// joined.
+func validateFields[T any](fields []string) error {
+	t := reflect.TypeFor[T]()
+	cols, err := columnsOf(t)
+	if err != nil {
+		return err
+	}
+	valid, err := newValidator(t, cols, nil)
+	if err != nil {
+		return err
+	}
+	if len(fields) != len(cols) {
+		return fmt.Errorf("%w: %d, want %d", ErrFieldCount, len(fields), len(cols))
+	}
+	pos := make([]int, len(cols))
+	for i := range pos {
+		pos[i] = i
+	}
+	_, err = decodeFields[T](cols, pos, fields, valid)
+	return err
+}
@@ -0,0 +1,240 @@
+// Package convert converts records between CSV, JSON, JSON Lines, YAML,
+// TOML and XML.
+//
+// A document is either a list of records, such as the rows of a CSV, the
+// elements of a JSON array or the lines of JSON Lines, or a single record,
+// such as a JSON object or a TOML config file. Records are converted one
+// at a time, a list staying a list.
+//
+// Records are trees of nil, bool, int64, float64, string, time.Time,
+// []any and *Object values. CSV and XML only hold text: CSV columns are
+// typed by inference, see Options.RawCSV, and XML values are strings.
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Format is a data format.
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSON  Format = "json"
+	JSONL Format = "jsonl"
+	YAML  Format = "yaml"
+	TOML  Format = "toml"
+	XML   Format = "xml"
+)
+
+// Formats are the formats Convert reads and writes.
+var Formats = []Format{CSV, JSON, JSONL, YAML, TOML, XML}
+
+var (
+	// ErrFormat is returned, wrapped, for an unknown format.
+	ErrFormat = errors.New("unknown format")
+	// ErrFlatten is returned, wrapped, for a record that cannot be written
+	// as a CSV row: one holding an array, or a key with a dot.
+	ErrFlatten = errors.New("cannot be flattened to CSV")
+	// ErrSchema is returned, wrapped, in streaming mode for a record with
+	// a CSV column that was not in the sample the columns were inferred
+	// from.
+	ErrSchema = errors.New("record does not match the inferred schema")
+	// ErrRange is returned, wrapped, for a JSON or YAML integer that does
+	// not fit in an int64, rather than rounding it to a float.
+	ErrRange = errors.New("integer does not fit in 64 bits")
+)
+
+// FormatOf returns the format of a file by its extension.
+func FormatOf(path string) (Format, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch ext {
+	case "yml":
+		return YAML, nil
+	case "ndjson":
+		return JSONL, nil
+	}
+	if f := Format(ext); slices.Contains(Formats, f) {
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: extension of %s", ErrFormat, path)
+}
+
+// DefaultSample is the number of records the schema is inferred from in
+// streaming mode, unless told otherwise by Options.Sample.
+const DefaultSample = 100
+
+// Options tunes a conversion.
+type Options struct {
+	// Stream converts the records as they are read instead of reading
+	// them all first, for inputs too large for memory. The CSV columns and
+	// types are then inferred from the first Sample records only. JSON
+	// arrays, JSON Lines, CSV, XML and multi-document YAML are streamed;
+	// a single YAML document is read whole, and TOML is read and written
+	// whole.
+	Stream bool
+	// Sample is the number of records of the inference in streaming mode,
+	// DefaultSample when 0.
+	Sample int
+	// Comma separates the CSV fields, ',' when 0.
+	Comma rune
+	// RawCSV keeps the fields of a CSV input as strings. Otherwise a
+	// column whose values are all integers, numbers or booleans is of that
+	// type, its empty fields being null.
+	RawCSV bool
+}
+
+func (o Options) sample() int {
+	if !o.Stream {
+		return -1
+	}
+	if o.Sample <= 0 {
+		return DefaultSample
+	}
+	return o.Sample
+}
+
+// decoder reads the records of a document.
+type decoder interface {
+	// next returns the next record, io.EOF after the last.
+	next() (any, error)
+	// list reports whether the document is a list of records, once next
+	// has been called.
+	list() bool
+}
+
+// encoder writes records.
+type encoder interface {
+	write(rec any) error
+	// close ends the document.
+	close() error
+}
+
+func newDecoder(r io.Reader, f Format, opts Options) (decoder, error) {
+	switch f {
+	case CSV:
+		return newCSVDecoder(r, opts), nil
+	case JSON:
+		return newJSONDecoder(r, false), nil
+	case JSONL:
+		return newJSONDecoder(r, true), nil
+	case YAML:
+		return newYAMLDecoder(r), nil
+	case TOML:
+		return newTOMLDecoder(r), nil
+	case XML:
+		return newXMLDecoder(r), nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrFormat, f)
+}
+
+// newEncoder returns the encoder of a document, a list or a single
+// record, with the fields of s.
+func newEncoder(w io.Writer, f Format, list bool, s Schema, opts Options) (encoder, error) {
+	switch f {
+	case CSV:
+		return newCSVEncoder(w, s, opts), nil
+	case JSON:
+		return newJSONEncoder(w, list, false), nil
+	case JSONL:
+		return newJSONEncoder(w, list, true), nil
+	case YAML:
+		return newYAMLEncoder(w, list), nil
+	case TOML:
+		return newTOMLEncoder(w, list), nil
+	case XML:
+		return newXMLEncoder(w, list), nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrFormat, f)
+}
+
+// Convert reads the document of format from in r and writes it to w in
+// format to. It returns how many records were written.
+func Convert(w io.Writer, to Format, r io.Reader, from Format, opts Options) (int, error) {
+	if !slices.Contains(Formats, to) {
+		return 0, fmt.Errorf("%w %q", ErrFormat, to)
+	}
+	d, sample, err := read(r, from, opts)
+	if err != nil {
+		return 0, err
+	}
+	var s Schema
+	if to == CSV {
+		s = Infer(sample)
+	}
+	e, err := newEncoder(w, to, d.list(), s, opts)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, rec := range sample {
+		if err := e.write(rec); err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		n++
+	}
+	for {
+		rec, err := d.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := e.write(rec); err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		n++
+	}
+	return n, e.close()
+}
+
+// InferSchema returns the schema of the document of format from in r, see
+// Infer. In streaming mode only the sample is read.
+func InferSchema(r io.Reader, from Format, opts Options) (Schema, error) {
+	_, sample, err := read(r, from, opts)
+	if err != nil {
+		return Schema{}, err
+	}
+	return Infer(sample), nil
+}
+
+// read returns the decoder of r and the records of the sample, all of
+// them when not streaming.
+func read(r io.Reader, from Format, opts Options) (decoder, []any, error) {
+	d, err := newDecoder(r, from, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit := opts.sample()
+	var sample []any
+	for limit < 0 || len(sample) < limit {
+		rec, err := d.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		sample = append(sample, rec)
+	}
+	if cd, ok := d.(*csvDecoder); ok {
+		// the rows are typed as the sample says, the ones of the sample
+		// now and the others as they are read
+		if !opts.RawCSV {
+			cd.types = csvTypes(cd.header, sample)
+		}
+		cd.ready = true
+		for i, rec := range sample {
+			if sample[i], err = cd.typed(rec.(*Object)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return d, sample, nil
+}
@@ -0,0 +1,207 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const people = `[
+  {
+    "name": "Ada",
+    "age": 36,
+    "score": 9.5,
+    "admin": true,
+    "address": {
+      "city": "London",
+      "zip": "N1"
+    },
+    "since": "1843-01-01"
+  },
+  {
+    "name": "Alan <Turing>",
+    "age": 41,
+    "score": 7.25,
+    "admin": false,
+    "address": {
+      "city": "Wilmslow",
+      "zip": "SK9"
+    },
+    "since": ""
+  }
+]
+`
+
+func convert(t *testing.T, in string, from, to Format, opts Options) string {
+	t.Helper()
+	var b bytes.Buffer
+	if _, err := Convert(&b, to, strings.NewReader(in), from, opts); err != nil {
+		t.Fatalf("%s to %s: %v", from, to, err)
+	}
+	return b.String()
+}
+
+func TestRoundtrip(t *testing.T) {
+	// TOML sorts the keys
+	for _, f := range []Format{JSON, JSONL, YAML, CSV} {
+		out := convert(t, people, JSON, f, Options{})
+		if back := convert(t, out, f, JSON, Options{}); back != people {
+			t.Errorf("JSON to %s and back:\n%s\nwant\n%s", f, back, people)
+		}
+	}
+	toml := convert(t, people, JSON, TOML, Options{})
+	if back := convert(t, convert(t, toml, TOML, YAML, Options{}), YAML, TOML, Options{}); back != toml {
+		t.Errorf("TOML to YAML and back:\n%s\nwant\n%s", back, toml)
+	}
+	// XML only holds text
+	xml := convert(t, people, JSON, XML, Options{})
+	if !strings.Contains(xml, "<name>Alan &lt;Turing&gt;</name>") {
+		t.Errorf("XML:\n%s", xml)
+	}
+	if back := convert(t, convert(t, xml, XML, JSONL, Options{}), JSONL, XML, Options{}); back != xml {
+		t.Errorf("XML to JSON Lines and back:\n%s\nwant\n%s", back, xml)
+	}
+}
+
+func TestSingle(t *testing.T) {
+	in := `{"book": {"@id": "7", "title": "Go", "author": ["Alan", "Brian"]}}`
+	xml := convert(t, in, JSON, XML, Options{})
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<book id="7">
+  <title>Go</title>
+  <author>Alan</author>
+  <author>Brian</author>
+</book>
+`
+	if xml != want {
+		t.Errorf("XML:\n%s\nwant\n%s", xml, want)
+	}
+	yaml := convert(t, xml, XML, YAML, Options{})
+	want = `book:
+  '@id': "7"
+  title: Go
+  author:
+    - Alan
+    - Brian
+`
+	if yaml != want {
+		t.Errorf("YAML:\n%s\nwant\n%s", yaml, want)
+	}
+	toml := convert(t, "title = 'x'\n[owner]\nname = 'Tom'\nborn = 1979-05-27\n", TOML, JSONL, Options{})
+	if want := `{"title":"x","owner":{"name":"Tom","born":"1979-05-27"}}` + "\n"; toml != want {
+		t.Errorf("TOML to JSON Lines: %s, want %s", toml, want)
+	}
+}
+
+func TestCSVTypes(t *testing.T) {
+	in := "id,ratio,ok,zip,note\n1,0.5,true,007,\n2,3,false,12,x\n3,,,,\n"
+	got := convert(t, in, CSV, JSONL, Options{})
+	want := `{"id":1,"ratio":0.5,"ok":true,"zip":"007","note":""}
+{"id":2,"ratio":3,"ok":false,"zip":"12","note":"x"}
+{"id":3,"ratio":null,"ok":null,"zip":"","note":""}
+`
+	if got != want {
+		t.Errorf("typed:\n%s\nwant\n%s", got, want)
+	}
+	raw := convert(t, in, CSV, JSONL, Options{RawCSV: true})
+	if !strings.HasPrefix(raw, `{"id":"1","ratio":"0.5","ok":"true"`) {
+		t.Errorf("raw:\n%s", raw)
+	}
+
+	s, err := InferSchema(strings.NewReader(in), CSV, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSchema := `FIELD  TYPE        RECORDS
+id     int         3/3
+ratio  float|null  3/3
+ok     bool|null   3/3
+zip    string      3/3
+note   string      3/3
+`
+	if s.String() != wantSchema {
+		t.Errorf("schema:\n%s\nwant\n%s", s, wantSchema)
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	in := "id,ratio\n12345678901234567890,0.5\n1,2\n"
+	want := `{"id":"12345678901234567890","ratio":0.5}
+{"id":"1","ratio":2}
+`
+	if got := convert(t, in, CSV, JSONL, Options{}); got != want {
+		t.Errorf("got:\n%s\nwant\n%s", got, want)
+	}
+	// past the sample a big integer stays a string too
+	in = "ratio\n0.5\n12345678901234567890\n"
+	if got := convert(t, in, CSV, JSONL, Options{Stream: true, Sample: 1}); got != "{\"ratio\":0.5}\n{\"ratio\":\"12345678901234567890\"}\n" {
+		t.Errorf("stream: %q", got)
+	}
+	if got := convert(t, `{"f": 1.5e300}`, JSON, JSONL, Options{}); got != "{\"f\":1.5e+300}\n" {
+		t.Errorf("float: %q", got)
+	}
+}
+
+func TestTOMLNull(t *testing.T) {
+	in := "name,n\nada,\nbob,2\n"
+	if got := convert(t, in, CSV, TOML, Options{}); got != "[[records]]\nname = \"ada\"\n\n[[records]]\nn = 2\nname = \"bob\"\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestStream(t *testing.T) {
+	in := "{\"a\":1}\n{\"a\":2}\n{\"a\":\"x\"}\n"
+	got := convert(t, in, JSONL, CSV, Options{Stream: true, Sample: 2})
+	if want := "a\n1\n2\nx\n"; got != want {
+		t.Errorf("streamed: %q, want %q", got, want)
+	}
+	var b bytes.Buffer
+	n, err := Convert(&b, CSV, strings.NewReader(in+"{\"a\":3,\"b\":4}\n"), JSONL, Options{Stream: true, Sample: 2})
+	if !errors.Is(err, ErrSchema) || n != 3 {
+		t.Errorf("new column: %d records, %v, want 3, ErrSchema", n, err)
+	}
+	// without streaming the schema is of all the records
+	if got := convert(t, in+"{\"b\":4}\n", JSONL, CSV, Options{Sample: 2}); got != "a,b\n1,\n2,\nx,\n,4\n" {
+		t.Errorf("whole: %q", got)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		from, to Format
+		want     error
+	}{
+		{`[{"tags": ["a"]}]`, JSON, CSV, ErrFlatten},
+		{`[{"a.b": 1}]`, JSON, CSV, ErrFlatten},
+		{`{}`, JSON, "ini", ErrFormat},
+		{`{}`, "ini", JSON, ErrFormat},
+		{`{"zip": [null]}`, JSON, TOML, errNull},
+		{`{"id": 12345678901234567890}`, JSON, CSV, ErrRange},
+		{`[12345678901234567890]`, JSONL, CSV, ErrRange},
+		{"id: 12345678901234567890\n", YAML, JSON, ErrRange},
+	} {
+		var b bytes.Buffer
+		if _, err := Convert(&b, tc.to, strings.NewReader(tc.in), tc.from, Options{}); !errors.Is(err, tc.want) {
+			t.Errorf("%s from %s to %s: got %v, want %v", tc.in, tc.from, tc.to, err, tc.want)
+		}
+	}
+	for _, in := range []string{"a,a\n1,2\n", "a,a.b\n1,2\n", "a..b\n1\n", "c.\n1\n", ".a\n1\n"} {
+		var b bytes.Buffer
+		if _, err := Convert(&b, JSON, strings.NewReader(in), CSV, Options{}); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for path, want := range map[string]Format{"a.CSV": CSV, "b.yml": YAML, "c.ndjson": JSONL, "d/e.toml": TOML} {
+		if got, err := FormatOf(path); err != nil || got != want {
+			t.Errorf("%s: %q, %v, want %q", path, got, err, want)
+		}
+	}
+	if _, err := FormatOf("notes.txt"); !errors.Is(err, ErrFormat) {
+		t.Errorf("txt: %v, want ErrFormat", err)
+	}
+}
@@ -0,0 +1,124 @@
+package convert
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// csvDecoder reads the rows of a CSV with a header as records, the
+// columns with dots making nested objects.
+type csvDecoder struct {
+	cr     *csv.Reader
+	header []string
+	// types are the types of the columns, all strings when nil
+	types []Type
+	// ready is false while the sample is read, the rows being returned
+	// flat and untyped until then.
+	ready bool
+}
+
+func newCSVDecoder(r io.Reader, opts Options) *csvDecoder {
+	cr := csv.NewReader(r)
+	cr.Comma = cmp.Or(opts.Comma, ',')
+	return &csvDecoder{cr: cr}
+}
+
+func (d *csvDecoder) next() (any, error) {
+	if d.header == nil {
+		header, err := d.cr.Read()
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		if err := checkColumns(header); err != nil {
+			return nil, fmt.Errorf("csv header: %w", err)
+		}
+		d.header = header
+	}
+	row, err := d.cr.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv: %w", err)
+	}
+	o := &Object{}
+	for i, c := range d.header {
+		o.Set(c, row[i])
+	}
+	if !d.ready {
+		return o, nil
+	}
+	return d.typed(o)
+}
+
+func (d *csvDecoder) list() bool { return true }
+
+// typed returns the record of the flat row o.
+func (d *csvDecoder) typed(o *Object) (any, error) {
+	for i, t := range d.types {
+		c := d.header[i]
+		o.vals[c] = csvValue(o.vals[c].(string), t)
+	}
+	return unflatten(o), nil
+}
+
+// csvEncoder writes records as CSV rows, with a header naming the fields
+// of the schema.
+type csvEncoder struct {
+	cw     *csv.Writer
+	cols   []string
+	known  map[string]bool
+	header bool
+	row    []string
+}
+
+func newCSVEncoder(w io.Writer, s Schema, opts Options) *csvEncoder {
+	e := &csvEncoder{cw: csv.NewWriter(w), known: map[string]bool{}}
+	e.cw.Comma = cmp.Or(opts.Comma, ',')
+	for _, f := range s.Fields {
+		e.cols = append(e.cols, f.Name)
+		e.known[f.Name] = true
+	}
+	e.row = make([]string, len(e.cols))
+	return e
+}
+
+func (e *csvEncoder) write(rec any) error {
+	flat, err := flatten(rec)
+	if err != nil {
+		return err
+	}
+	for _, k := range flat.keys {
+		if !e.known[k] {
+			return fmt.Errorf("column %s: %w, convert without streaming or with a larger sample", k, ErrSchema)
+		}
+	}
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	for i, c := range e.cols {
+		e.row[i] = text(flat.vals[c])
+	}
+	return e.cw.Write(e.row)
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header || len(e.cols) == 0 {
+		return nil
+	}
+	e.header = true
+	return e.cw.Write(e.cols)
+}
+
+func (e *csvEncoder) close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.cw.Flush()
+	return e.cw.Error()
+}
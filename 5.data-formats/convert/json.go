@@ -0,0 +1,217 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// jsonDecoder reads a JSON document, the elements of a top level array
+// being the records, or the values of JSON Lines.
+type jsonDecoder struct {
+	dec   *json.Decoder
+	lines bool
+	// started is true once the first token has been read, isList once it
+	// was the [ of a top level array.
+	started bool
+	isList  bool
+	done    bool
+}
+
+func newJSONDecoder(r io.Reader, lines bool) *jsonDecoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &jsonDecoder{dec: dec, lines: lines, isList: lines}
+}
+
+func (d *jsonDecoder) list() bool { return d.isList }
+
+func (d *jsonDecoder) next() (any, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	if d.lines {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			d.done = true
+			return nil, err
+		}
+		if err != nil {
+			return nil, d.error(err)
+		}
+		v, err := d.value(tok)
+		if err != nil {
+			return nil, d.error(err)
+		}
+		return v, nil
+	}
+	if !d.started {
+		d.started = true
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			d.done, d.isList = true, true
+			return nil, err
+		}
+		if err != nil {
+			return nil, d.error(err)
+		}
+		if tok != json.Delim('[') {
+			v, err := d.value(tok)
+			if err == nil {
+				err = d.end()
+			}
+			if err != nil {
+				return nil, d.error(err)
+			}
+			d.done = true
+			return v, nil
+		}
+		d.isList = true
+	}
+	if !d.dec.More() {
+		d.done = true
+		// the ]
+		if _, err := d.dec.Token(); err != nil {
+			return nil, d.error(err)
+		}
+		if err := d.end(); err != nil {
+			return nil, d.error(err)
+		}
+		return nil, io.EOF
+	}
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, d.error(err)
+	}
+	v, err := d.value(tok)
+	if err != nil {
+		return nil, d.error(err)
+	}
+	return v, nil
+}
+
+// end checks that nothing follows the document.
+func (d *jsonDecoder) end() error {
+	if _, err := d.dec.Token(); err != io.EOF {
+		return errors.New("data after the document")
+	}
+	return nil
+}
+
+func (d *jsonDecoder) error(err error) error {
+	d.done = true
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("json: offset %d: %w", d.dec.InputOffset(), err)
+}
+
+// value returns the value starting with tok.
+func (d *jsonDecoder) value(tok json.Token) (any, error) {
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			o := &Object{}
+			for d.dec.More() {
+				key, err := d.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				tok, err := d.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := d.value(tok)
+				if err != nil {
+					return nil, err
+				}
+				o.Set(key.(string), v)
+			}
+			_, err := d.dec.Token()
+			return o, err
+		case '[':
+			a := []any{}
+			for d.dec.More() {
+				tok, err := d.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := d.value(tok)
+				if err != nil {
+					return nil, err
+				}
+				a = append(a, v)
+			}
+			_, err := d.dec.Token()
+			return a, err
+		}
+		return nil, fmt.Errorf("unexpected %v", tok)
+	case json.Number:
+		if n, err := tok.Int64(); err == nil {
+			return n, nil
+		}
+		if !strings.ContainsAny(tok.String(), ".eE") {
+			return nil, fmt.Errorf("%s: %w", tok, ErrRange)
+		}
+		return tok.Float64()
+	}
+	return tok, nil
+}
+
+// jsonEncoder writes a list of records as an indented JSON array, or as
+// JSON Lines, and a single record as an indented JSON value.
+type jsonEncoder struct {
+	w     io.Writer
+	list  bool
+	lines bool
+	n     int
+	buf   bytes.Buffer
+}
+
+func newJSONEncoder(w io.Writer, list, lines bool) *jsonEncoder {
+	return &jsonEncoder{w: w, list: list, lines: lines}
+}
+
+func (e *jsonEncoder) write(rec any) error {
+	e.buf.Reset()
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	switch {
+	case e.lines:
+	case e.list:
+		if e.n == 0 {
+			e.buf.WriteString("[\n  ")
+		} else {
+			e.buf.WriteString(",\n  ")
+		}
+		enc.SetIndent("  ", "  ")
+	default:
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(rec); err != nil {
+		return err
+	}
+	if e.list && !e.lines {
+		// the newline goes before the next record or the ]
+		e.buf.Truncate(e.buf.Len() - 1)
+	}
+	e.n++
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+func (e *jsonEncoder) close() error {
+	if !e.list || e.lines {
+		return nil
+	}
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
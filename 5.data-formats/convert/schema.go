@@ -0,0 +1,210 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Type is the type of a field, the set of the types of its values.
+type Type uint8
+
+const (
+	TypeNull Type = 1 << iota
+	TypeBool
+	TypeInt
+	TypeFloat
+	TypeString
+	TypeTime
+	TypeArray
+	// TypeObject is only the type of an empty object, the fields of the
+	// others being inferred.
+	TypeObject
+)
+
+var typeNames = []string{"null", "bool", "int", "float", "string", "time", "array", "object"}
+
+// String returns the names of the types of t joined by "|", such as
+// "int|null".
+func (t Type) String() string {
+	var names []string
+	for i, name := range typeNames {
+		if t&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	// the null last reads better
+	if len(names) > 1 && names[0] == "null" {
+		names = append(names[1:], "null")
+	}
+	return strings.Join(names, "|")
+}
+
+func typeOf(v any) Type {
+	switch v := v.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBool
+	case int64:
+		return TypeInt
+	case float64:
+		return TypeFloat
+	case time.Time:
+		return TypeTime
+	case []any:
+		return TypeArray
+	case *Object:
+		if v.Len() == 0 {
+			return TypeObject
+		}
+	}
+	return TypeString
+}
+
+// Field is a field of the records, a column of their CSV.
+type Field struct {
+	// Name is the path of the field, the keys of the nested objects joined
+	// by dots.
+	Name string
+	Type Type
+	// Count is the number of records with the field.
+	Count int
+}
+
+// Schema is the fields of records.
+type Schema struct {
+	Fields []Field
+	// Records is the number of records the schema was inferred from.
+	Records int
+}
+
+// Infer returns the schema of recs: the fields of the nested objects, in
+// the order they first appear, with the types of their values. The
+// elements of arrays are not looked into, and a record that is not an
+// object is the field "value".
+func Infer(recs []any) Schema {
+	s := Schema{Records: len(recs)}
+	index := map[string]int{}
+	var walk func(prefix string, o *Object)
+	add := func(name string, v any) {
+		i, ok := index[name]
+		if !ok {
+			i = len(s.Fields)
+			index[name] = i
+			s.Fields = append(s.Fields, Field{Name: name})
+		}
+		s.Fields[i].Type |= typeOf(v)
+		s.Fields[i].Count++
+	}
+	walk = func(prefix string, o *Object) {
+		for _, k := range o.keys {
+			v := o.vals[k]
+			if child, ok := v.(*Object); ok && child.Len() > 0 {
+				walk(prefix+k+".", child)
+				continue
+			}
+			add(prefix+k, v)
+		}
+	}
+	for _, rec := range recs {
+		if o, ok := rec.(*Object); ok {
+			walk("", o)
+		} else {
+			add("value", rec)
+		}
+	}
+	return s
+}
+
+// String returns s as a table of the fields, their types and how many
+// records have them.
+func (s Schema) String() string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tTYPE\tRECORDS")
+	for _, f := range s.Fields {
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\n", f.Name, f.Type, f.Count, s.Records)
+	}
+	tw.Flush()
+	return b.String()
+}
+
+// jsonNumber is the syntax of a JSON number, the floats of a CSV.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// csvTypes returns the types of the columns of the CSV rows of sample:
+// TypeInt when all the non empty fields of a column are integers written
+// as Go does, TypeFloat when they are JSON numbers but not integers too
+// large for an int64, TypeBool when they are true or false, and TypeString
+// otherwise.
+func csvTypes(cols []string, sample []any) []Type {
+	types := make([]Type, len(cols))
+	for i, c := range cols {
+		t := TypeInt | TypeFloat | TypeBool
+		seen := false
+		for _, rec := range sample {
+			s := rec.(*Object).vals[c].(string)
+			if s == "" {
+				continue
+			}
+			seen = true
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || strconv.FormatInt(n, 10) != s {
+				t &^= TypeInt
+			}
+			// an integer too large for an int64 would be rounded as a float
+			if !jsonNumber.MatchString(s) || errors.Is(err, strconv.ErrRange) {
+				t &^= TypeFloat
+			}
+			if s != "true" && s != "false" {
+				t &^= TypeBool
+			}
+		}
+		switch {
+		case !seen:
+			types[i] = TypeString
+		case t&TypeInt != 0:
+			types[i] = TypeInt
+		case t&TypeFloat != 0:
+			types[i] = TypeFloat
+		case t&TypeBool != 0:
+			types[i] = TypeBool
+		default:
+			types[i] = TypeString
+		}
+	}
+	return types
+}
+
+// csvValue returns the value of the field s of a column of type t, an
+// empty field being null. A field that is not of the type, past the
+// sample of a stream, is kept as a string.
+func csvValue(s string, t Type) any {
+	if t == TypeString {
+		return s
+	}
+	if s == "" {
+		return nil
+	}
+	switch t {
+	case TypeInt:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case TypeFloat:
+		_, ierr := strconv.ParseInt(s, 10, 64)
+		if f, err := strconv.ParseFloat(s, 64); err == nil && jsonNumber.MatchString(s) && !errors.Is(ierr, strconv.ErrRange) {
+			return f
+		}
+	case TypeBool:
+		if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+			return b
+		}
+	}
+	return s
+}
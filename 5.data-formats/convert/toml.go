@@ -0,0 +1,218 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// listKey is the key of the array of tables a list of records is written
+// under in TOML, which has no top level arrays.
+const listKey = "records"
+
+// tomlDecoder reads a TOML document as a single record, or as the list of
+// the tables of its array when it holds nothing else. The document is read
+// whole.
+type tomlDecoder struct {
+	r       io.Reader
+	recs    []any
+	started bool
+	isList  bool
+}
+
+func newTOMLDecoder(r io.Reader) *tomlDecoder {
+	return &tomlDecoder{r: r}
+}
+
+func (d *tomlDecoder) list() bool { return d.isList }
+
+func (d *tomlDecoder) next() (any, error) {
+	if !d.started {
+		d.started = true
+		if err := d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	if len(d.recs) == 0 {
+		return nil, io.EOF
+	}
+	rec := d.recs[0]
+	d.recs = d.recs[1:]
+	return rec, nil
+}
+
+func (d *tomlDecoder) decode() error {
+	var m map[string]any
+	md, err := toml.NewDecoder(d.r).Decode(&m)
+	if err != nil {
+		return fmt.Errorf("toml: %w", err)
+	}
+	// the keys are ordered as they were defined
+	pos := map[string]int{}
+	for i, k := range md.Keys() {
+		if _, ok := pos[tomlPath(k)]; !ok {
+			pos[tomlPath(k)] = i
+		}
+	}
+	doc := tomlValue(m, nil, pos).(*Object)
+	if doc.Len() == 1 {
+		key := doc.keys[0]
+		if tables, ok := doc.vals[key].([]any); ok && (md.Type(key) == "ArrayHash" || len(tables) == 0) {
+			d.isList = true
+			d.recs = tables
+			return nil
+		}
+	}
+	d.recs = []any{doc}
+	return nil
+}
+
+func tomlPath(k toml.Key) string {
+	return strings.Join(k, "\x00")
+}
+
+// tomlValue returns the value of v at path, with the keys of its tables
+// in the order of pos.
+func tomlValue(v any, path []string, pos map[string]int) any {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		at := func(k string) int {
+			if i, ok := pos[tomlPath(append(slices.Clip(path), k))]; ok {
+				return i
+			}
+			return len(pos)
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			if i, j := at(a), at(b); i != j {
+				return i - j
+			}
+			return strings.Compare(a, b)
+		})
+		o := &Object{}
+		for _, k := range keys {
+			o.Set(k, tomlValue(v[k], append(slices.Clip(path), k), pos))
+		}
+		return o
+	case []map[string]any:
+		a := make([]any, len(v))
+		for i, e := range v {
+			a[i] = tomlValue(e, path, pos)
+		}
+		return a
+	case []any:
+		a := make([]any, len(v))
+		for i, e := range v {
+			a[i] = tomlValue(e, path, pos)
+		}
+		return a
+	case time.Time:
+		// the local dates and times, which the decoder puts in zones of
+		// these names, have no offset and are kept as their text
+		switch v.Location().String() {
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		case "date-local":
+			return v.Format(time.DateOnly)
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		}
+	}
+	return v
+}
+
+// tomlEncoder writes a single record as a TOML document, and a list of
+// records as the array of tables "records". The records are written on
+// close, TOML being encoded whole, with the keys sorted.
+type tomlEncoder struct {
+	w    io.Writer
+	list bool
+	recs []map[string]any
+}
+
+func newTOMLEncoder(w io.Writer, list bool) *tomlEncoder {
+	return &tomlEncoder{w: w, list: list}
+}
+
+func (e *tomlEncoder) write(rec any) error {
+	v, err := tomlMap(rec)
+	if err != nil {
+		return err
+	}
+	e.recs = append(e.recs, v)
+	return nil
+}
+
+func (e *tomlEncoder) close() error {
+	var doc any
+	switch {
+	case e.list && len(e.recs) == 0:
+		_, err := io.WriteString(e.w, listKey+" = []\n")
+		return err
+	case e.list:
+		doc = map[string]any{listKey: e.recs}
+	case len(e.recs) == 1:
+		doc = e.recs[0]
+	default:
+		return nil
+	}
+	enc := toml.NewEncoder(e.w)
+	enc.Indent = ""
+	return enc.Encode(doc)
+}
+
+// tomlMap returns rec as a TOML table, a record that is not an object
+// being the key "value".
+func tomlMap(rec any) (map[string]any, error) {
+	o, ok := rec.(*Object)
+	if !ok {
+		o = &Object{}
+		o.Set("value", rec)
+	}
+	v, err := tomlEncodable(o)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]any), nil
+}
+
+var errNull = errors.New("TOML has no null")
+
+func tomlEncodable(v any) (any, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, errNull
+	case *Object:
+		m := make(map[string]any, v.Len())
+		for _, k := range v.keys {
+			// a null, such as an empty CSV cell, is a key left out
+			if v.vals[k] == nil {
+				continue
+			}
+			e, err := tomlEncodable(v.vals[k])
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", k, err)
+			}
+			m[k] = e
+		}
+		return m, nil
+	case []any:
+		a := make([]any, len(v))
+		for i, e := range v {
+			var err error
+			if a[i], err = tomlEncodable(e); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return v, nil
+}
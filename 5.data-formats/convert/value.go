@@ -0,0 +1,186 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object is a JSON object, a YAML mapping, a TOML table or an XML element:
+// values by key, the keys in the order they were set. The zero value is an
+// empty Object.
+type Object struct {
+	keys []string
+	vals map[string]any
+}
+
+// Set sets the value of key, which keeps its place if it was already set.
+func (o *Object) Set(key string, v any) {
+	if o.vals == nil {
+		o.vals = map[string]any{}
+	}
+	if _, ok := o.vals[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.vals[key] = v
+}
+
+// Get returns the value of key.
+func (o *Object) Get(key string) (any, bool) {
+	v, ok := o.vals[key]
+	return v, ok
+}
+
+// Keys returns the keys in order.
+func (o *Object) Keys() []string {
+	return o.keys
+}
+
+// Len returns the number of keys.
+func (o *Object) Len() int {
+	return len(o.keys)
+}
+
+// MarshalJSON implements json.Marshaler, keeping the order of the keys.
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		kb, err := marshalJSON(k)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(kb)
+		b.WriteByte(':')
+		vb, err := marshalJSON(o.vals[k])
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k, err)
+		}
+		b.Write(vb)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// marshalJSON is json.Marshal without the escaping of <, > and &.
+func marshalJSON(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// flatten returns rec as a CSV row: the keys of nested objects are joined
+// by dots, "address.city", and a record that is not an object is the
+// column "value". Arrays and keys with a dot are ErrFlatten.
+func flatten(rec any) (*Object, error) {
+	o, ok := rec.(*Object)
+	if !ok {
+		o = &Object{}
+		o.Set("value", rec)
+	}
+	flat := &Object{}
+	return flat, flattenInto(flat, "", o)
+}
+
+func flattenInto(flat *Object, prefix string, o *Object) error {
+	for _, k := range o.keys {
+		if strings.Contains(k, ".") {
+			return fmt.Errorf("key %q has a dot: %w", prefix+k, ErrFlatten)
+		}
+		switch v := o.vals[k].(type) {
+		case *Object:
+			if err := flattenInto(flat, prefix+k+".", v); err != nil {
+				return err
+			}
+		case []any:
+			return fmt.Errorf("key %s is an array: %w", prefix+k, ErrFlatten)
+		default:
+			flat.Set(prefix+k, v)
+		}
+	}
+	return nil
+}
+
+// unflatten nests the keys of a CSV row split on their dots, the reverse
+// of flatten. The columns must have been checked by checkColumns.
+func unflatten(flat *Object) *Object {
+	o := &Object{}
+	for _, k := range flat.keys {
+		path := strings.Split(k, ".")
+		parent := o
+		for _, p := range path[:len(path)-1] {
+			child, ok := parent.vals[p].(*Object)
+			if !ok {
+				child = &Object{}
+				parent.Set(p, child)
+			}
+			parent = child
+		}
+		parent.Set(path[len(path)-1], flat.vals[k])
+	}
+	return o
+}
+
+// checkColumns checks that no column of a CSV header is repeated, has an
+// empty key in its path, such as "a..b" or "c.", or is nested under
+// another one, such as "address" and "address.city".
+func checkColumns(cols []string) error {
+	seen := map[string]bool{}
+	for _, c := range cols {
+		if strings.Contains(c, ".") && slices.Contains(strings.Split(c, "."), "") {
+			return fmt.Errorf("column %s has an empty key", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("column %s is repeated", c)
+		}
+		seen[c] = true
+	}
+	for _, c := range cols {
+		for i := range len(c) {
+			if c[i] == '.' && seen[c[:i]] {
+				return fmt.Errorf("column %s is nested under column %s", c, c[:i])
+			}
+		}
+	}
+	return nil
+}
+
+// text returns a scalar as CSV or XML text.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatFloat(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// formatFloat formats f as encoding/json does, in decimal unless it is
+// very large or very small.
+func formatFloat(f float64) string {
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) || math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
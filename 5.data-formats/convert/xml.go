@@ -0,0 +1,316 @@
+package convert
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// the root elements of a list of records and of a single record
+const (
+	xmlList   = "records"
+	xmlRecord = "record"
+)
+
+// xmlDecoder reads the children of a <records> root as records, the
+// content of a <record> root as a single record, and any other root r as
+// the single record {r: content}.
+//
+// An element is an object of its attributes, as "@name" keys, and of its
+// children, the repeated ones making arrays, its text being the key
+// "#text". An element with neither is its text.
+type xmlDecoder struct {
+	dec     *xml.Decoder
+	started bool
+	isList  bool
+	done    bool
+}
+
+func newXMLDecoder(r io.Reader) *xmlDecoder {
+	return &xmlDecoder{dec: xml.NewDecoder(r)}
+}
+
+func (d *xmlDecoder) list() bool { return d.isList }
+
+func (d *xmlDecoder) next() (any, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	if !d.started {
+		d.started = true
+		root, err := d.start()
+		if err != nil {
+			return nil, d.error(err)
+		}
+		switch root.Name.Local {
+		case xmlList:
+			d.isList = true
+		case xmlRecord:
+			v, err := d.element(root)
+			if err != nil {
+				return nil, d.error(err)
+			}
+			return v, d.end()
+		default:
+			v, err := d.element(root)
+			if err != nil {
+				return nil, d.error(err)
+			}
+			o := &Object{}
+			o.Set(root.Name.Local, v)
+			return o, d.end()
+		}
+	}
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, d.error(err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			v, err := d.element(tok)
+			if err != nil {
+				return nil, d.error(err)
+			}
+			return v, nil
+		case xml.EndElement:
+			if err := d.end(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		case xml.CharData:
+			if len(strings.TrimSpace(string(tok))) > 0 {
+				return nil, d.error(errors.New("text between the records"))
+			}
+		}
+	}
+}
+
+// start returns the root element.
+func (d *xmlDecoder) start() (xml.StartElement, error) {
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return tok, nil
+		case xml.CharData:
+			if len(strings.TrimSpace(string(tok))) > 0 {
+				return xml.StartElement{}, errors.New("text before the root element")
+			}
+		}
+	}
+}
+
+// end checks that nothing follows the root element.
+func (d *xmlDecoder) end() error {
+	d.done = true
+	for {
+		tok, err := d.dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return d.error(err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return d.error(errors.New("data after the root element"))
+		case xml.CharData:
+			if len(strings.TrimSpace(string(tok))) > 0 {
+				return d.error(errors.New("data after the root element"))
+			}
+		}
+	}
+}
+
+func (d *xmlDecoder) error(err error) error {
+	d.done = true
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	line, _ := d.dec.InputPos()
+	return fmt.Errorf("xml: line %d: %w", line, err)
+}
+
+// element returns the value of the element started by start.
+func (d *xmlDecoder) element(start xml.StartElement) (any, error) {
+	o := &Object{}
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		o.Set("@"+a.Name.Local, a.Value)
+	}
+	var text strings.Builder
+	children := false
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			children = true
+			v, err := d.element(tok)
+			if err != nil {
+				return nil, err
+			}
+			k := tok.Name.Local
+			switch prev, ok := o.vals[k].([]any); {
+			case ok:
+				o.vals[k] = append(prev, v)
+			case o.vals[k] != nil:
+				o.vals[k] = []any{o.vals[k], v}
+			default:
+				o.Set(k, v)
+			}
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			if o.Len() == 0 && !children {
+				return text.String(), nil
+			}
+			if t := strings.TrimSpace(text.String()); t != "" {
+				o.Set("#text", t)
+			}
+			return o, nil
+		}
+	}
+}
+
+// xmlEncoder writes a list of records as the <record> children of a
+// <records> root, and a single record as a <record> root, or as the root
+// named by its key when it has only one.
+type xmlEncoder struct {
+	w    io.Writer
+	enc  *xml.Encoder
+	list bool
+	n    int
+}
+
+func newXMLEncoder(w io.Writer, list bool) *xmlEncoder {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &xmlEncoder{w: w, enc: enc, list: list}
+}
+
+func (e *xmlEncoder) write(rec any) error {
+	if e.n == 0 {
+		if _, err := io.WriteString(e.w, xml.Header); err != nil {
+			return err
+		}
+		if e.list {
+			if err := e.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: xmlList}}); err != nil {
+				return err
+			}
+		}
+	}
+	e.n++
+	name := xmlRecord
+	if o, ok := rec.(*Object); ok && !e.list && o.Len() == 1 {
+		k := o.keys[0]
+		if _, isArray := o.vals[k].([]any); !isArray && !strings.HasPrefix(k, "@") && k != "#text" {
+			name, rec = k, o.vals[k]
+		}
+	}
+	if err := e.element(name, rec); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+func (e *xmlEncoder) close() error {
+	if !e.list {
+		if e.n == 0 {
+			return nil
+		}
+		_, err := io.WriteString(e.w, "\n")
+		return err
+	}
+	if e.n == 0 {
+		_, err := io.WriteString(e.w, xml.Header+"<"+xmlList+"></"+xmlList+">\n")
+		return err
+	}
+	if err := e.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: xmlList}}); err != nil {
+		return err
+	}
+	if err := e.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "\n")
+	return err
+}
+
+// xmlName is the names this encoder writes, XML names without colons.
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// element writes the element name of value v.
+func (e *xmlEncoder) element(name string, v any) error {
+	if !xmlName.MatchString(name) {
+		return fmt.Errorf("key %q is not an XML name", name)
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	o, ok := v.(*Object)
+	if a, isArray := v.([]any); isArray {
+		// the elements of an array record
+		o = &Object{}
+		o.Set("value", a)
+		ok = true
+	}
+	if ok {
+		for _, k := range o.keys {
+			if attr, isAttr := strings.CutPrefix(k, "@"); isAttr {
+				if !xmlName.MatchString(attr) {
+					return fmt.Errorf("key %q is not an XML attribute name", k)
+				}
+				switch o.vals[k].(type) {
+				case *Object, []any:
+					return fmt.Errorf("attribute %s is not a scalar", k)
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: text(o.vals[k])})
+			}
+		}
+	}
+	if err := e.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if !ok {
+		if err := e.enc.EncodeToken(xml.CharData(text(v))); err != nil {
+			return err
+		}
+		return e.enc.EncodeToken(start.End())
+	}
+	for _, k := range o.keys {
+		if strings.HasPrefix(k, "@") {
+			continue
+		}
+		if k == "#text" {
+			if err := e.enc.EncodeToken(xml.CharData(text(o.vals[k]))); err != nil {
+				return err
+			}
+			continue
+		}
+		a, isArray := o.vals[k].([]any)
+		if !isArray {
+			if err := e.element(k, o.vals[k]); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, elem := range a {
+			if _, nested := elem.([]any); nested {
+				return fmt.Errorf("key %s: arrays of arrays cannot be written as XML", k)
+			}
+			if err := e.element(k, elem); err != nil {
+				return err
+			}
+		}
+	}
+	return e.enc.EncodeToken(start.End())
+}
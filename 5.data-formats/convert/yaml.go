@@ -0,0 +1,270 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlDecoder reads the documents of a YAML stream as records, or the
+// elements of its single document when it is a sequence.
+type yamlDecoder struct {
+	dec *yaml.Decoder
+	// ahead is the document read ahead to tell a stream from a single
+	// document, elems the elements of a single sequence document.
+	ahead   *yaml.Node
+	elems   []*yaml.Node
+	started bool
+	isList  bool
+	done    bool
+}
+
+func newYAMLDecoder(r io.Reader) *yamlDecoder {
+	return &yamlDecoder{dec: yaml.NewDecoder(r)}
+}
+
+func (d *yamlDecoder) list() bool { return d.isList }
+
+func (d *yamlDecoder) next() (any, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	if !d.started {
+		d.started = true
+		first, err := d.document()
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			d.isList = true
+			return d.next()
+		}
+		second, err := d.document()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case second != nil:
+			d.isList = true
+			d.ahead = second
+			return nodeValue(first)
+		case first.Kind == yaml.SequenceNode:
+			d.isList = true
+			d.elems = first.Content
+		default:
+			d.done = true
+			return nodeValue(first)
+		}
+	}
+	if d.elems != nil {
+		if len(d.elems) == 0 {
+			d.done = true
+			return nil, io.EOF
+		}
+		n := d.elems[0]
+		d.elems = d.elems[1:]
+		return nodeValue(n)
+	}
+	n := d.ahead
+	if n == nil {
+		d.done = true
+		return nil, io.EOF
+	}
+	var err error
+	if d.ahead, err = d.document(); err != nil {
+		return nil, err
+	}
+	return nodeValue(n)
+}
+
+// document returns the content of the next document, nil after the last.
+func (d *yamlDecoder) document() (*yaml.Node, error) {
+	var doc yaml.Node
+	err := d.dec.Decode(&doc)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		d.done = true
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// nodeValue returns the value of n.
+func nodeValue(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return nodeValue(n.Alias)
+	case yaml.MappingNode:
+		o := &Object{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("yaml: line %d: key is not a scalar", k.Line)
+			}
+			if k.ShortTag() == "!!merge" {
+				return nil, fmt.Errorf("yaml: line %d: merge keys are not supported", k.Line)
+			}
+			val, err := nodeValue(v)
+			if err != nil {
+				return nil, err
+			}
+			o.Set(k.Value, val)
+		}
+		return o, nil
+	case yaml.SequenceNode:
+		a := []any{}
+		for _, e := range n.Content {
+			v, err := nodeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	}
+	var err error
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err = n.Decode(&b)
+		if err == nil {
+			return b, nil
+		}
+	case "!!int":
+		var i int64
+		if n.Decode(&i) == nil {
+			return i, nil
+		}
+		return nil, fmt.Errorf("yaml: line %d: %s: %w", n.Line, n.Value, ErrRange)
+	case "!!float":
+		var f float64
+		err = n.Decode(&f)
+		if err == nil {
+			return f, nil
+		}
+	case "!!timestamp":
+		var t time.Time
+		err = n.Decode(&t)
+		if err == nil {
+			return t, nil
+		}
+	default:
+		return n.Value, nil
+	}
+	return nil, fmt.Errorf("yaml: line %d: %w", n.Line, err)
+}
+
+// yamlEncoder writes a list of records as a sequence, one element at a
+// time, and a single record as a document.
+type yamlEncoder struct {
+	w    io.Writer
+	list bool
+	n    int
+}
+
+func newYAMLEncoder(w io.Writer, list bool) *yamlEncoder {
+	return &yamlEncoder{w: w, list: list}
+}
+
+func (e *yamlEncoder) write(rec any) error {
+	n, err := valueNode(rec)
+	if err != nil {
+		return err
+	}
+	if e.list {
+		n = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{n}}
+	}
+	// an encoder per record, as one would separate them by ---
+	enc := yaml.NewEncoder(e.w)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return err
+	}
+	e.n++
+	return enc.Close()
+}
+
+func (e *yamlEncoder) close() error {
+	if e.list && e.n == 0 {
+		_, err := io.WriteString(e.w, "[]\n")
+		return err
+	}
+	return nil
+}
+
+// valueNode returns the YAML node of v, tagged so the value reads back
+// with its type.
+func valueNode(v any) (*yaml.Node, error) {
+	scalar := func(tag, value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+	}
+	switch v := v.(type) {
+	case nil:
+		return scalar("!!null", "null"), nil
+	case string:
+		return scalar("!!str", v), nil
+	case bool:
+		return scalar("!!bool", text(v)), nil
+	case int64:
+		return scalar("!!int", text(v)), nil
+	case float64:
+		return scalar("!!float", yamlFloat(v)), nil
+	case time.Time:
+		return scalar("!!timestamp", text(v)), nil
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if len(v) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		for _, e := range v {
+			c, err := valueNode(e)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, c)
+		}
+		return n, nil
+	case *Object:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if v.Len() == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		for _, k := range v.keys {
+			c, err := valueNode(v.vals[k])
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", k, err)
+			}
+			n.Content = append(n.Content, scalar("!!str", k), c)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("unsupported value %T", v)
+}
+
+// yamlFloat formats f so that it reads back as a float, 1 being 1.0.
+func yamlFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	}
+	s := formatFloat(f)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
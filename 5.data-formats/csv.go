@@ -0,0 +1,429 @@
+package dataformats
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrFieldCount is the cause of a RowError for a row that does not have
+	// as many fields as the header, or as the record has columns.
+	ErrFieldCount = errors.New("wrong number of fields")
+	// ErrHeader is the cause of a RowError for a header line that lacks a
+	// column of the record. It stops the reader even in lenient mode.
+	ErrHeader = errors.New("header does not match the record")
+)
+
+// RowError is a row that could not be read.
+type RowError struct {
+	// Line is the 1-based line the row starts on.
+	Line int
+	// Err is the cause, such as ErrFieldCount, or the FieldErrors of the
+	// row, joined.
+	Err error
+}
+
+// Error implements error.Error(), on a single line.
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, strings.ReplaceAll(e.Err.Error(), "\n", "; "))
+}
+
+// Unwrap returns the cause.
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// HeaderMode tells whether the first row of a CSV names the columns.
+type HeaderMode int
+
+const (
+	// DetectHeader takes the first row for a header when every field of
+	// it names a column of the record, ignoring case. A Writer writes a
+	// header. This is the default.
+	DetectHeader HeaderMode = iota
+	// HasHeader means the first row is a header.
+	HasHeader
+	// NoHeader means the first row is a record, its fields being the
+	// columns of the record in order. A Writer writes no header.
+	NoHeader
+)
+
+// Option configures a Reader or a Writer.
+type Option func(*config)
+
+type config struct {
+	delim   rune
+	quote   rune
+	header  HeaderMode
+	lenient bool
+	onSkip  func(*RowError)
+	rules   []*Rules
+}
+
+// Delimiter separates the fields with delim instead of ','.
+func Delimiter(delim rune) Option {
+	return func(c *config) { c.delim = delim }
+}
+
+// Quote quotes the fields with q, an ASCII character, instead of '"'. A q
+// in a quoted field is doubled.
+func Quote(q rune) Option {
+	return func(c *config) { c.quote = q }
+}
+
+// Header sets whether the first row is a header, see HeaderMode.
+func Header(m HeaderMode) Option {
+	return func(c *config) { c.header = m }
+}
+
+// Strict makes the Reader stop at the first bad row. This is the default.
+func Strict() Option {
+	return func(c *config) { c.lenient = false }
+}
+
+// Lenient makes the Reader skip bad rows. onSkip, if not nil, is called
+// with every skipped row.
+func Lenient(onSkip func(*RowError)) Option {
+	return func(c *config) {
+		c.lenient = true
+		c.onSkip = onSkip
+	}
+}
+
+// WithRules makes the Reader check rules, on top of the validate tags of
+// the record type, see Rules. A row breaking a rule is a bad row, its
+// RowError wrapping ErrInvalid.
+func WithRules(rules ...*Rules) Option {
+	return func(c *config) { c.rules = append(c.rules, rules...) }
+}
+
+func newConfig(opts []Option) (config, error) {
+	c := config{delim: ',', quote: '"'}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	switch {
+	case c.quote >= utf8.RuneSelf || c.quote == '\r' || c.quote == '\n':
+		return c, fmt.Errorf("quote %q is not an ASCII character other than a newline", c.quote)
+	case c.delim == c.quote || c.delim == '\r' || c.delim == '\n' || !utf8.ValidRune(c.delim) || c.delim == utf8.RuneError:
+		return c, fmt.Errorf("delimiter %q is not valid with quote %q", c.delim, c.quote)
+	}
+	return c, nil
+}
+
+// swap exchanges the quote of c and '"'. encoding/csv only quotes with
+// '"': a CSV quoted with c.quote is read and written by encoding/csv once
+// swapped, and its fields swapped back.
+func (c config) swap(r rune) rune {
+	switch r {
+	case c.quote:
+		return '"'
+	case '"':
+		return c.quote
+	}
+	return r
+}
+
+func (c config) swapString(s string) string {
+	if c.quote == '"' {
+		return s
+	}
+	return strings.Map(c.swap, s)
+}
+
+// swapReader swaps the quote of its config in the bytes it reads. The
+// quote being ASCII, it is never part of a multibyte UTF-8 sequence.
+type swapReader struct {
+	r   io.Reader
+	cfg config
+}
+
+func (s swapReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	for i, b := range p[:n] {
+		p[i] = byte(s.cfg.swap(rune(b)))
+	}
+	return n, err
+}
+
+// swapWriter swaps the quote of its config in the bytes it writes.
+type swapWriter struct {
+	w   io.Writer
+	cfg config
+	buf []byte
+}
+
+func (s *swapWriter) Write(p []byte) (int, error) {
+	s.buf = s.buf[:0]
+	for _, b := range p {
+		s.buf = append(s.buf, byte(s.cfg.swap(rune(b))))
+	}
+	return s.w.Write(s.buf)
+}
+
+// Reader reads the records T of a CSV. T is a struct whose exported fields
+// are the columns, named by their csv tag or else by their name:
+//
+//	type Name struct {
+//		First string `csv:"first"`
+//		Last  string `csv:"last"`
+//		Age   int    `csv:"age"`
+//		Notes string `csv:"-"` // not a column
+//	}
+//
+// Fields are strings, booleans, numbers, time.Duration or types
+// implementing encoding.TextUnmarshaler, such as time.Time. An empty
+// field is the zero value. Blank lines are ignored. A row breaking the
+// validate tags of T, see Rules, is a bad row.
+//
+// With a header the columns may come in any order, and the columns of the
+// header that are not in T are ignored. Without a header the fields are the
+// columns of T in order.
+type Reader[T any] struct {
+	cr   *csv.Reader
+	cfg  config
+	cols []column
+	// pos maps the fields of a row to cols, -1 for an ignored field
+	pos []int
+	// valid checks the rules of the records, nil without rules
+	valid *validator
+	// first is true until the first row has been read.
+	first bool
+
+	line    int
+	skipped int
+	// err is sticky: once set Read keeps returning it.
+	err error
+}
+
+// NewReader returns a Reader of the records T in r.
+func NewReader[T any](r io.Reader, opts ...Option) *Reader[T] {
+	rd := &Reader[T]{first: true}
+	var err error
+	if rd.cfg, err = newConfig(opts); err != nil {
+		rd.err = err
+		return rd
+	}
+	t := reflect.TypeFor[T]()
+	if rd.cols, err = columnsOf(t); err != nil {
+		rd.err = err
+		return rd
+	}
+	if rd.valid, err = newValidator(t, rd.cols, rd.cfg.rules); err != nil {
+		rd.err = err
+		return rd
+	}
+	if rd.cfg.quote != '"' {
+		r = swapReader{r: r, cfg: rd.cfg}
+	}
+	rd.cr = csv.NewReader(r)
+	rd.cr.Comma = rd.cfg.swap(rd.cfg.delim)
+	rd.cr.FieldsPerRecord = -1
+	return rd
+}
+
+// Read returns the next record. It returns io.EOF at the end of the input,
+// a *RowError for a bad row in strict mode or a bad header, and a
+// *csv.ParseError for a malformed CSV, after which the reader is done. An
+// invalid option or record type is returned by the first call.
+func (r *Reader[T]) Read() (T, error) {
+	var zero T
+	if r.err != nil {
+		return zero, r.err
+	}
+	for {
+		fields, err := r.cr.Read()
+		if err != nil {
+			r.err = err
+			return zero, err
+		}
+		r.line, _ = r.cr.FieldPos(0)
+		for i, f := range fields {
+			fields[i] = r.cfg.swapString(f)
+		}
+		if r.first {
+			r.first = false
+			if r.cfg.header == HasHeader || r.cfg.header == DetectHeader && r.isHeader(fields) {
+				if err := r.readHeader(fields); err != nil {
+					r.err = &RowError{Line: r.line, Err: err}
+					return zero, r.err
+				}
+				continue
+			}
+			r.pos = make([]int, len(r.cols))
+			for i := range r.pos {
+				r.pos[i] = i
+			}
+		}
+		rec, err := r.decode(fields)
+		if err == nil {
+			return rec, nil
+		}
+		rowErr := &RowError{Line: r.line, Err: err}
+		if !r.cfg.lenient {
+			r.err = rowErr
+			return zero, rowErr
+		}
+		r.skipped++
+		if r.cfg.onSkip != nil {
+			r.cfg.onSkip(rowErr)
+		}
+	}
+}
+
+// isHeader reports whether every field names a column.
+func (r *Reader[T]) isHeader(fields []string) bool {
+	for _, f := range fields {
+		if r.column(f) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// column returns the index in r.cols of the column named name, or -1.
+func (r *Reader[T]) column(name string) int {
+	name = strings.TrimSpace(name)
+	for i, c := range r.cols {
+		if strings.EqualFold(c.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Reader[T]) readHeader(fields []string) error {
+	r.pos = make([]int, len(fields))
+	found := make([]bool, len(r.cols))
+	for i, f := range fields {
+		r.pos[i] = r.column(f)
+		if r.pos[i] < 0 {
+			continue
+		}
+		if found[r.pos[i]] {
+			return fmt.Errorf("%w: column %s is repeated", ErrHeader, r.cols[r.pos[i]].name)
+		}
+		found[r.pos[i]] = true
+	}
+	var missing []string
+	for i, ok := range found {
+		if !ok {
+			missing = append(missing, r.cols[i].name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: no column %s", ErrHeader, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (r *Reader[T]) decode(fields []string) (T, error) {
+	if len(fields) != len(r.pos) {
+		var zero T
+		return zero, fmt.Errorf("%w: %d, want %d", ErrFieldCount, len(fields), len(r.pos))
+	}
+	return decodeFields[T](r.cols, r.pos, fields, r.valid)
+}
+
+// All returns an iterator over the remaining records. A reading error is
+// yielded once, with a zero T, and ends the iteration; io.EOF is not
+// yielded.
+func (r *Reader[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				return
+			}
+			if !yield(rec, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Line returns the line the last row read starts on.
+func (r *Reader[T]) Line() int {
+	return r.line
+}
+
+// Skipped returns how many bad rows were skipped in lenient mode.
+func (r *Reader[T]) Skipped() int {
+	return r.skipped
+}
+
+// Writer writes records T, see Reader, as CSV. Unless the NoHeader option
+// is given, a header naming the columns is written before the first
+// record. Flush must be called after the last record.
+type Writer[T any] struct {
+	cw     *csv.Writer
+	cfg    config
+	cols   []column
+	header bool
+	fields []string
+	err    error
+}
+
+// NewWriter returns a Writer of records T to w.
+func NewWriter[T any](w io.Writer, opts ...Option) *Writer[T] {
+	wr := &Writer[T]{}
+	var err error
+	if wr.cfg, err = newConfig(opts); err != nil {
+		wr.err = err
+		return wr
+	}
+	if wr.cols, err = columnsOf(reflect.TypeFor[T]()); err != nil {
+		wr.err = err
+		return wr
+	}
+	if wr.cfg.quote != '"' {
+		w = &swapWriter{w: w, cfg: wr.cfg}
+	}
+	wr.cw = csv.NewWriter(w)
+	wr.cw.Comma = wr.cfg.swap(wr.cfg.delim)
+	wr.header = wr.cfg.header != NoHeader
+	wr.fields = make([]string, len(wr.cols))
+	return wr
+}
+
+// Write writes rec, after the header for the first record. An invalid
+// option or record type is returned by every call.
+func (w *Writer[T]) Write(rec T) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.header {
+		w.header = false
+		for i, c := range w.cols {
+			w.fields[i] = w.cfg.swapString(c.name)
+		}
+		if err := w.cw.Write(w.fields); err != nil {
+			return err
+		}
+	}
+	v := reflect.ValueOf(&rec).Elem()
+	for i, c := range w.cols {
+		f, err := c.format(v.Field(c.field))
+		if err != nil {
+			return err
+		}
+		w.fields[i] = w.cfg.swapString(f)
+	}
+	return w.cw.Write(w.fields)
+}
+
+// Flush writes the buffered records and returns the error of any Write or
+// Flush.
+func (w *Writer[T]) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.cw.Flush()
+	return w.cw.Error()
+}
@@ -0,0 +1,151 @@
+package dataformats
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type member struct {
+	Name  string        `csv:"name"`
+	Age   int           `csv:"age"`
+	Admin bool          `csv:"admin"`
+	Shift time.Duration `csv:"shift"`
+	Since time.Time     `csv:"since"`
+	Notes string        `csv:"-"`
+}
+
+func TestReadHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		opts []Option
+		want []Name
+	}{
+		{"detected", "First,LAST\nAda,Lovelace\n", nil, []Name{{"Ada", "Lovelace"}}},
+		{"reordered", "last,first,born\nLovelace,Ada,1815\n", []Option{Header(HasHeader)}, []Name{{"Ada", "Lovelace"}}},
+		{"none", "Ada,Lovelace\n\nAlan,Turing\n", nil, []Name{{"Ada", "Lovelace"}, {"Alan", "Turing"}}},
+		{"forced none", "first,last\n", []Option{Header(NoHeader)}, []Name{{"first", "last"}}},
+	} {
+		var got []Name
+		for n, err := range NewReader[Name](strings.NewReader(tc.in), tc.opts...).All() {
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			got = append(got, n)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: read %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	_, err := NewReader[Name](strings.NewReader("first,surname\n"), Header(HasHeader)).Read()
+	if !errors.Is(err, ErrHeader) {
+		t.Errorf("header without last: got %v, want ErrHeader", err)
+	}
+}
+
+func TestReadTyped(t *testing.T) {
+	in := `name,age,admin,shift,since
+Ada, 36 ,true,8h,2024-05-18T09:00:00Z
+"Turing,
+Alan",41,,30m,
+Bob,old,maybe,8h,2024-05-18T09:00:00Z
+Carol,30
+`
+	var skipped []*RowError
+	r := NewReader[member](strings.NewReader(in), Lenient(func(e *RowError) { skipped = append(skipped, e) }))
+	var got []member
+	for m, err := range r.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	want := []member{
+		{Name: "Ada", Age: 36, Admin: true, Shift: 8 * time.Hour, Since: time.Date(2024, 5, 18, 9, 0, 0, 0, time.UTC)},
+		{Name: "Turing,\nAlan", Age: 41, Shift: 30 * time.Minute},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read %+v, want %+v", got, want)
+	}
+	if r.Skipped() != 2 || len(skipped) != 2 || skipped[0].Line != 5 || skipped[1].Line != 6 {
+		t.Fatalf("skipped %v, want lines 5 and 6", skipped)
+	}
+	var fieldErr *FieldError
+	if !errors.As(skipped[0], &fieldErr) || fieldErr.Column != "age" {
+		t.Errorf("line 5: got %v, want a FieldError on age", skipped[0])
+	}
+	if msg := skipped[0].Error(); !strings.Contains(msg, "admin") || strings.Contains(msg, "\n") {
+		t.Errorf("line 5: got %q, want the admin error too, on one line", msg)
+	}
+	if !errors.Is(skipped[1], ErrFieldCount) {
+		t.Errorf("line 6: got %v, want ErrFieldCount", skipped[1])
+	}
+
+	r = NewReader[member](strings.NewReader(in))
+	for _, err := range r.All() {
+		if err != nil {
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) || rowErr.Line != 5 {
+				t.Errorf("strict: got %v, want line 5", err)
+			}
+		}
+	}
+	if _, err := r.Read(); err == nil || err == io.EOF {
+		t.Errorf("strict: read after the bad row: got %v, want the error again", err)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	recs := []member{
+		{Name: "O'Hara; Scarlett", Age: 28, Shift: 90 * time.Minute, Since: time.Date(2024, 5, 18, 9, 0, 0, 0, time.UTC)},
+		{Name: `say "hi"`, Admin: true},
+	}
+	var b strings.Builder
+	w := NewWriter[member](&b, Delimiter(';'), Quote('\''))
+	for _, m := range recs {
+		if err := w.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `name;age;admin;shift;since
+'O''Hara; Scarlett';28;false;1h30m0s;2024-05-18T09:00:00Z
+say "hi";0;true;0s;0001-01-01T00:00:00Z
+`
+	if b.String() != want {
+		t.Errorf("wrote\n%s\nwant\n%s", b.String(), want)
+	}
+
+	var got []member
+	for m, err := range NewReader[member](strings.NewReader(b.String()), Delimiter(';'), Quote('\'')).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	if !reflect.DeepEqual(got, recs) {
+		t.Errorf("read back %+v, want %+v", got, recs)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, err := NewReader[Name](strings.NewReader(""), Quote(';'), Delimiter(';')).Read(); err == nil || err == io.EOF {
+		t.Errorf("same quote and delimiter: got %v", err)
+	}
+	if err := NewWriter[struct{ C chan int }](io.Discard).Write(struct{ C chan int }{}); !errors.Is(err, ErrType) {
+		t.Errorf("chan column: got %v, want ErrType", err)
+	}
+	if err := (record{"Ada"}).Validate(); !errors.Is(err, ErrFieldCount) {
+		t.Errorf("record of one field: got %v, want ErrFieldCount", err)
+	}
+	if err := (record{"Ada", "Lovelace"}).Validate(); err != nil {
+		t.Errorf("name record: %v", err)
+	}
+}
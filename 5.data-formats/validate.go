@@ -0,0 +1,579 @@
+package dataformats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalid is the cause of the FieldError of a value that breaks a rule,
+// see Rules.
+var ErrInvalid = errors.New("invalid value")
+
+// ruleError is a broken rule, ErrInvalid.
+type ruleError string
+
+func (e ruleError) Error() string { return string(e) }
+
+func (e ruleError) Is(target error) bool { return target == ErrInvalid }
+
+// Rules are validation rules of the columns of records. They are given by
+// the validate tags of the fields of a record type, or by a JSON Schema
+// document, see ParseJSONSchema, and checked by a Validator, or by a
+// Reader given WithRules.
+//
+// A validate tag is a comma separated list of rules:
+//
+//	type Shift struct {
+//		Team  string `csv:"team" validate:"required,enum=ops|dev"`
+//		Start int    `csv:"start" validate:"min=0,max=23"`
+//		End   int    `csv:"end" validate:"required_with=start,gtfield=start"`
+//		Host  string `csv:"host" validate:"maxlen=63,pattern=^[a-z0-9.-]+$"`
+//	}
+//
+//	required           the value is set
+//	type=T             the text of the value is an integer, number, boolean or string
+//	min=N, max=N       the value, a number, is within [N, max] or [min, N]
+//	minlen=N, maxlen=N the text of the value has at least or at most N characters
+//	enum=a|b|c         the text of the value is one of a, b and c
+//	pattern=RE         the text of the value matches RE; the rest of the tag
+//	                   is the pattern so it comes last
+//	required_with=C    the value is set when column C is
+//	gtfield=C          the value is greater than the one of column C, and
+//	                   gtefield, ltfield, ltefield, eqfield and nefield
+//	                   likewise
+//
+// A value is set when its field is not empty, a 0 or false field being
+// set, and for a record given to Validator.Validate when its text is not
+// empty. A value that is not set only breaks required and required_with:
+// the other rules hold for the set values. Columns are named as by the
+// Reader, ignoring case. Text is the one a Writer writes.
+type Rules struct {
+	fields  []fieldRules
+	depends []dependency
+	compare []comparison
+}
+
+// fieldRules are the rules of the values of a column.
+type fieldRules struct {
+	column                     string
+	required                   bool
+	typ                        string
+	pattern                    *regexp.Regexp
+	enum                       []string
+	min, max                   *float64
+	exclusiveMin, exclusiveMax *float64
+	minLen, maxLen             *int
+}
+
+// dependency is a column that must be set when another is.
+type dependency struct {
+	column, requires string
+}
+
+// comparison is a column whose value compares as op to the one of other.
+type comparison struct {
+	column, op, other string
+}
+
+// comparisons are the ops of the cross-field rules, with the reason a
+// value breaks them.
+var comparisons = map[string]struct {
+	ok  func(c int) bool
+	not string
+}{
+	"gt":  {func(c int) bool { return c > 0 }, "not greater than"},
+	"gte": {func(c int) bool { return c >= 0 }, "less than"},
+	"lt":  {func(c int) bool { return c < 0 }, "not less than"},
+	"lte": {func(c int) bool { return c <= 0 }, "greater than"},
+	"eq":  {func(c int) bool { return c == 0 }, "not equal to"},
+	"ne":  {func(c int) bool { return c != 0 }, "equal to"},
+}
+
+var types = []string{"string", "integer", "number", "boolean"}
+
+// rulesOf returns the rules of the validate tags of the columns cols of the
+// struct t.
+func rulesOf(t reflect.Type, cols []column) (*Rules, error) {
+	rules := &Rules{}
+	for _, c := range cols {
+		f := t.Field(c.field)
+		tag, ok := f.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		if err := rules.parseTag(c.name, tag); err != nil {
+			return nil, fmt.Errorf("%w: field %s of %v: validate tag: %v", ErrType, f.Name, t, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Rules) parseTag(col, tag string) error {
+	fr := fieldRules{column: col}
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "pattern=") {
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+		name, arg, hasArg := strings.Cut(rule, "=")
+		if hasArg == (name == "required") || arg == "" && hasArg {
+			return fmt.Errorf("rule %q", rule)
+		}
+		var err error
+		switch name {
+		case "required":
+			fr.required = true
+		case "type":
+			if !slices.Contains(types, arg) {
+				return fmt.Errorf("type %q is not one of %s", arg, strings.Join(types, ", "))
+			}
+			fr.typ = arg
+		case "min", "max":
+			var n float64
+			if n, err = strconv.ParseFloat(arg, 64); err == nil {
+				if name == "min" {
+					fr.min = &n
+				} else {
+					fr.max = &n
+				}
+			}
+		case "minlen", "maxlen":
+			var n int
+			if n, err = strconv.Atoi(arg); err == nil {
+				if name == "minlen" {
+					fr.minLen = &n
+				} else {
+					fr.maxLen = &n
+				}
+			}
+		case "enum":
+			fr.enum = strings.Split(arg, "|")
+		case "pattern":
+			fr.pattern, err = regexp.Compile(arg)
+		case "required_with":
+			r.depends = append(r.depends, dependency{column: col, requires: arg})
+		default:
+			op, ok := strings.CutSuffix(name, "field")
+			if _, known := comparisons[op]; !ok || !known {
+				return fmt.Errorf("unknown rule %q", name)
+			}
+			r.compare = append(r.compare, comparison{column: col, op: op, other: arg})
+		}
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+	r.fields = append(r.fields, fr)
+	return nil
+}
+
+// jsonSchema is the subset of JSON Schema ParseJSONSchema knows.
+type jsonSchema struct {
+	Schema      string `json:"$schema"`
+	ID          string `json:"$id"`
+	Comment     string `json:"$comment"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Properties  map[string]struct {
+		Comment          string            `json:"$comment"`
+		Title            string            `json:"title"`
+		Description      string            `json:"description"`
+		Examples         []json.RawMessage `json:"examples"`
+		Type             string            `json:"type"`
+		Pattern          string            `json:"pattern"`
+		Enum             []json.RawMessage `json:"enum"`
+		Minimum          *float64          `json:"minimum"`
+		Maximum          *float64          `json:"maximum"`
+		ExclusiveMinimum *float64          `json:"exclusiveMinimum"`
+		ExclusiveMaximum *float64          `json:"exclusiveMaximum"`
+		MinLength        *int              `json:"minLength"`
+		MaxLength        *int              `json:"maxLength"`
+		GtField          string            `json:"x-gtfield"`
+		GteField         string            `json:"x-gtefield"`
+		LtField          string            `json:"x-ltfield"`
+		LteField         string            `json:"x-ltefield"`
+		EqField          string            `json:"x-eqfield"`
+		NeField          string            `json:"x-nefield"`
+	} `json:"properties"`
+	Required          []string            `json:"required"`
+	DependentRequired map[string][]string `json:"dependentRequired"`
+	// records have a fixed set of columns already
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+}
+
+// ParseJSONSchema returns the rules of a JSON Schema document describing a
+// record as an object whose properties are its columns:
+//
+//	{
+//	  "type": "object",
+//	  "required": ["team", "end"],
+//	  "properties": {
+//	    "team":  {"enum": ["ops", "dev"]},
+//	    "start": {"type": "integer", "minimum": 0, "maximum": 23},
+//	    "end":   {"type": "integer", "x-gtfield": "start"}
+//	  },
+//	  "dependentRequired": {"start": ["end"]}
+//	}
+//
+// The properties know the keywords type, pattern, enum, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, minLength and maxLength, and the
+// x-gtfield, x-gtefield, x-ltfield, x-ltefield, x-eqfield and x-nefield
+// extensions of the cross-field rules of the validate tags. Other keywords
+// are an error, but for the annotations such as title and description. The
+// type and the enum values apply to the text of the values, as in Rules.
+func ParseJSONSchema(data []byte) (*Rules, error) {
+	var s jsonSchema
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("json schema: %w", err)
+	}
+	if s.Type != "" && s.Type != "object" {
+		return nil, fmt.Errorf("json schema: type %q, want object", s.Type)
+	}
+	rules := &Rules{}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		p := s.Properties[name]
+		fr := fieldRules{
+			column:       name,
+			typ:          p.Type,
+			min:          p.Minimum,
+			max:          p.Maximum,
+			exclusiveMin: p.ExclusiveMinimum,
+			exclusiveMax: p.ExclusiveMaximum,
+			minLen:       p.MinLength,
+			maxLen:       p.MaxLength,
+		}
+		if fr.typ != "" && !slices.Contains(types, fr.typ) {
+			return nil, fmt.Errorf("json schema: property %s: type %q is not one of %s", name, fr.typ, strings.Join(types, ", "))
+		}
+		if p.Pattern != "" {
+			var err error
+			if fr.pattern, err = regexp.Compile(p.Pattern); err != nil {
+				return nil, fmt.Errorf("json schema: property %s: %w", name, err)
+			}
+		}
+		for _, e := range p.Enum {
+			// the text of a JSON string is unquoted, other values are their
+			// JSON text
+			var s string
+			if json.Unmarshal(e, &s) != nil {
+				s = string(e)
+			}
+			fr.enum = append(fr.enum, s)
+		}
+		rules.fields = append(rules.fields, fr)
+		for op, other := range map[string]string{"gt": p.GtField, "gte": p.GteField, "lt": p.LtField, "lte": p.LteField, "eq": p.EqField, "ne": p.NeField} {
+			if other != "" {
+				rules.compare = append(rules.compare, comparison{column: name, op: op, other: other})
+			}
+		}
+	}
+	// the map made the order random
+	slices.SortFunc(rules.compare, func(a, b comparison) int {
+		return strings.Compare(a.column+" "+a.op, b.column+" "+b.op)
+	})
+	for _, name := range s.Required {
+		rules.fields = append(rules.fields, fieldRules{column: name, required: true})
+	}
+	names = names[:0]
+	for name := range s.DependentRequired {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, req := range s.DependentRequired[name] {
+			rules.depends = append(rules.depends, dependency{column: req, requires: name})
+		}
+	}
+	return rules, nil
+}
+
+// validator checks the rules of the columns of a record type.
+type validator struct {
+	cols []column
+	// fields are the rules of the columns, by index in cols
+	fields  [][]fieldRules
+	depends []colDependency
+	compare []colComparison
+}
+
+type colDependency struct{ col, requires int }
+
+type colComparison struct {
+	col, other int
+	op         string
+}
+
+// newValidator returns the validator of the tags of the struct t, whose
+// columns are cols, and of rules. It returns nil when there is no rule.
+func newValidator(t reflect.Type, cols []column, rules []*Rules) (*validator, error) {
+	tags, err := rulesOf(t, cols)
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{cols: cols, fields: make([][]fieldRules, len(cols))}
+	index := func(name string) (int, error) {
+		for i, c := range cols {
+			if strings.EqualFold(c.name, name) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%w: rules for column %s, which %v does not have", ErrType, name, t)
+	}
+	empty := true
+	for _, r := range append([]*Rules{tags}, rules...) {
+		for _, fr := range r.fields {
+			i, err := index(fr.column)
+			if err != nil {
+				return nil, err
+			}
+			v.fields[i] = append(v.fields[i], fr)
+			empty = false
+		}
+		for _, d := range r.depends {
+			col, err := index(d.column)
+			if err != nil {
+				return nil, err
+			}
+			req, err := index(d.requires)
+			if err != nil {
+				return nil, err
+			}
+			v.depends = append(v.depends, colDependency{col: col, requires: req})
+			empty = false
+		}
+		for _, c := range r.compare {
+			col, err := index(c.column)
+			if err != nil {
+				return nil, err
+			}
+			other, err := index(c.other)
+			if err != nil {
+				return nil, err
+			}
+			v.compare = append(v.compare, colComparison{col: col, other: other, op: c.op})
+			empty = false
+		}
+	}
+	if empty {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// check returns the FieldErrors of the rules rec breaks, rec being an
+// addressable record. The columns of skip, which did not parse, are not
+// checked. set tells the columns whose fields were not empty, and is nil
+// for a record that was not read, whose values are set when their text is
+// not empty.
+func (v *validator) check(rec reflect.Value, skip, set []bool) []error {
+	if v == nil {
+		return nil
+	}
+	bad := make([]bool, len(v.cols))
+	copy(bad, skip)
+	skipped := func(i int) bool { return bad[i] }
+	texts := make([]string, len(v.cols))
+	fromText := set == nil
+	if fromText {
+		set = make([]bool, len(v.cols))
+	}
+	var errs []error
+	for i, c := range v.cols {
+		if skipped(i) {
+			continue
+		}
+		f := rec.Field(c.field)
+		var err error
+		if texts[i], err = c.format(f); err != nil {
+			errs = append(errs, err)
+			bad[i] = true
+			continue
+		}
+		if fromText {
+			set[i] = texts[i] != ""
+		}
+		for _, fr := range v.fields[i] {
+			for _, err := range fr.check(f, texts[i], set[i]) {
+				errs = append(errs, &FieldError{Column: c.name, Value: texts[i], Err: err})
+			}
+		}
+	}
+	for _, d := range v.depends {
+		if !skipped(d.col) && !skipped(d.requires) && set[d.requires] && !set[d.col] {
+			err := ruleError("required with " + v.cols[d.requires].name)
+			errs = append(errs, &FieldError{Column: v.cols[d.col].name, Err: err})
+		}
+	}
+	for _, c := range v.compare {
+		if skipped(c.col) || skipped(c.other) || !set[c.col] || !set[c.other] {
+			continue
+		}
+		a, b := rec.Field(v.cols[c.col].field), rec.Field(v.cols[c.other].field)
+		if op := comparisons[c.op]; !op.ok(compareValues(a, b, texts[c.col], texts[c.other])) {
+			err := ruleError(fmt.Sprintf("%s %s (%s)", op.not, v.cols[c.other].name, texts[c.other]))
+			errs = append(errs, &FieldError{Column: v.cols[c.col].name, Value: texts[c.col], Err: err})
+		}
+	}
+	return errs
+}
+
+// check returns the rules of fr that the value f, of text s, breaks.
+func (fr fieldRules) check(f reflect.Value, s string, set bool) []error {
+	if !set {
+		if fr.required {
+			return []error{ruleError("required")}
+		}
+		return nil
+	}
+	var errs []error
+	broken := func(format string, args ...any) {
+		errs = append(errs, ruleError(fmt.Sprintf(format, args...)))
+	}
+	switch fr.typ {
+	case "integer":
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			broken("not an integer")
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			broken("not a number")
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(s); err != nil {
+			broken("not a boolean")
+		}
+	}
+	if fr.min != nil || fr.max != nil || fr.exclusiveMin != nil || fr.exclusiveMax != nil {
+		n, ok := number(f, s)
+		if !ok {
+			// type reported it already
+			if fr.typ != "number" && fr.typ != "integer" {
+				broken("not a number")
+			}
+		} else {
+			if fr.min != nil && n < *fr.min {
+				broken("less than the minimum %s", formatNumber(*fr.min))
+			}
+			if fr.max != nil && n > *fr.max {
+				broken("greater than the maximum %s", formatNumber(*fr.max))
+			}
+			if fr.exclusiveMin != nil && n <= *fr.exclusiveMin {
+				broken("not greater than %s", formatNumber(*fr.exclusiveMin))
+			}
+			if fr.exclusiveMax != nil && n >= *fr.exclusiveMax {
+				broken("not less than %s", formatNumber(*fr.exclusiveMax))
+			}
+		}
+	}
+	n := utf8.RuneCountInString(s)
+	if fr.minLen != nil && n < *fr.minLen {
+		broken("shorter than %d characters", *fr.minLen)
+	}
+	if fr.maxLen != nil && n > *fr.maxLen {
+		broken("longer than %d characters", *fr.maxLen)
+	}
+	if fr.enum != nil && !slices.Contains(fr.enum, s) {
+		broken("not one of %s", strings.Join(fr.enum, ", "))
+	}
+	if fr.pattern != nil && !fr.pattern.MatchString(s) {
+		broken("does not match %s", fr.pattern)
+	}
+	return errs
+}
+
+// number returns the value f, of text s, as a number: a numeric field, or
+// the text of another one.
+func number(f reflect.Value, s string) (float64, bool) {
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.Type() != durationType {
+			return float64(f.Int()), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(f.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return f.Float(), true
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// compareValues compares the values a and b, of texts sa and sb: as
+// numbers, durations included, as times, or else as texts.
+func compareValues(a, b reflect.Value, sa, sb string) int {
+	if a.Type() == timeType && b.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+	}
+	if a.Type() == durationType && b.Type() == durationType {
+		return compare(a.Int(), b.Int())
+	}
+	na, okA := number(a, sa)
+	nb, okB := number(b, sb)
+	if okA && okB {
+		return compare(na, nb)
+	}
+	return strings.Compare(sa, sb)
+}
+
+func compare[N int64 | float64](a, b N) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Validator checks the rules of records T: the validate tags of T, see
+// Rules, and the rules it was made with.
+type Validator[T any] struct {
+	v *validator
+}
+
+// NewValidator returns the Validator of records T. It returns an ErrType
+// error for an invalid validate tag, or for rules of a column that T does
+// not have.
+func NewValidator[T any](rules ...*Rules) (*Validator[T], error) {
+	t := reflect.TypeFor[T]()
+	cols, err := columnsOf(t)
+	if err != nil {
+		return nil, err
+	}
+	v, err := newValidator(t, cols, rules)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator[T]{v: v}, nil
+}
+
+// Validate returns a FieldError, wrapping ErrInvalid, for every rule rec
+// breaks, joined, and nil when rec is valid.
+func (v *Validator[T]) Validate(rec T) error {
+	return errors.Join(v.v.check(reflect.ValueOf(&rec).Elem(), nil, nil)...)
+}
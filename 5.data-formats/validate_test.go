@@ -0,0 +1,243 @@
+package dataformats
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type shift struct {
+	Team  string        `csv:"team" validate:"required,enum=ops|dev"`
+	Start int           `csv:"start" validate:"min=0,max=23"`
+	End   int           `csv:"end" validate:"required_with=start,gtfield=start"`
+	Host  string        `csv:"host" validate:"maxlen=12,pattern=^[a-z0-9.-]+$"`
+	Pager string        `csv:"pager"`
+	Grace time.Duration `csv:"grace"`
+}
+
+// fieldErrors returns the columns and reasons of the FieldErrors of err.
+func fieldErrors(t *testing.T, err error) []string {
+	t.Helper()
+	var got []string
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("got %v, want joined errors", err)
+	}
+	for _, e := range joined.Unwrap() {
+		var fe *FieldError
+		if !errors.As(e, &fe) {
+			t.Fatalf("got %v, want a FieldError", e)
+		}
+		got = append(got, fe.Column+": "+fe.Err.Error())
+	}
+	return got
+}
+
+// newValidatorOf returns the validator of the type of rec.
+func newValidatorOf(rec any) (*validator, error) {
+	t := reflect.TypeOf(rec)
+	cols, err := columnsOf(t)
+	if err != nil {
+		return nil, err
+	}
+	return newValidator(t, cols, nil)
+}
+
+func TestValidateTags(t *testing.T) {
+	v, err := NewValidator[shift]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Validate(shift{Team: "ops", Start: 8, End: 16, Host: "db-1.lan"}); err != nil {
+		t.Errorf("valid shift: %v", err)
+	}
+	err = v.Validate(shift{Team: "sales", Start: 25, End: 3, Host: "DB_1.example.org"})
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("got %v, want ErrInvalid", err)
+	}
+	want := []string{
+		"team: not one of ops, dev",
+		"start: greater than the maximum 23",
+		"host: longer than 12 characters",
+		"host: does not match ^[a-z0-9.-]+$",
+		"end: not greater than start (25)",
+	}
+	if got := fieldErrors(t, err); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	// the numbers of a record are set, 0 included
+	got := fieldErrors(t, v.Validate(shift{Start: 8}))
+	if want := []string{"team: required", "end: not greater than start (8)"}; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("missing: got %q, want %q", got, want)
+	}
+
+	for _, bad := range []any{
+		struct {
+			A string `validate:"min"`
+		}{},
+		struct {
+			A string `validate:"type=float"`
+		}{},
+		struct {
+			A string `validate:"pattern=["`
+		}{},
+		struct {
+			A string `validate:"gtfield=B"`
+		}{},
+		struct {
+			A string `validate:"unique"`
+		}{},
+	} {
+		_, err := newValidatorOf(bad)
+		if !errors.Is(err, ErrType) {
+			t.Errorf("%T: got %v, want ErrType", bad, err)
+		}
+	}
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	rules, err := ParseJSONSchema([]byte(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "shift",
+  "type": "object",
+  "required": ["pager", "host"],
+  "properties": {
+    "Pager": {"type": "integer", "minLength": 4, "x-nefield": "host", "description": "phone extension"},
+    "grace": {"enum": ["0s", "5m0s"]},
+    "start": {"exclusiveMinimum": 5}
+  },
+  "dependentRequired": {"pager": ["grace"]}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidator[shift](rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Validate(shift{Team: "dev", Start: 6, End: 600, Host: "web", Pager: "1234", Grace: 5 * time.Minute}); err != nil {
+		t.Errorf("valid shift: %v", err)
+	}
+	got := fieldErrors(t, v.Validate(shift{Team: "dev", Start: 5, End: 9, Pager: "12x"}))
+	want := []string{
+		"start: not greater than 5",
+		"host: required",
+		"pager: not an integer",
+		"pager: shorter than 4 characters",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	got = fieldErrors(t, v.Validate(shift{Team: "dev", Start: 6, End: 9, Host: "1234", Pager: "1234", Grace: 5 * time.Minute}))
+	if want := "pager: equal to host (1234)"; len(got) != 1 || got[0] != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, doc := range []string{
+		`{"type": "array"}`,
+		`{"properties": {"team": {"format": "email"}}}`,
+		`{"properties": {"team": {"type": "object"}}}`,
+		`{"properties": {"team": {"pattern": "("}}}`,
+	} {
+		if _, err := ParseJSONSchema([]byte(doc)); err == nil {
+			t.Errorf("%s: no error", doc)
+		}
+	}
+	rules, err = ParseJSONSchema([]byte(`{"required": ["manager"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewValidator[shift](rules); !errors.Is(err, ErrType) {
+		t.Errorf("unknown column: got %v, want ErrType", err)
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	in := "team,start,end,host,pager,grace\nops,8,16,db,,\nsales,8,16,db,,\nops,9,x,db,,\n"
+	rules, err := ParseJSONSchema([]byte(`{"properties": {"host": {"enum": ["db", "web"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skipped []*RowError
+	r := NewReader[shift](strings.NewReader(in), WithRules(rules), Lenient(func(e *RowError) { skipped = append(skipped, e) }))
+	n := 0
+	for _, err := range r.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 1 || len(skipped) != 2 {
+		t.Fatalf("read %d, skipped %v, want 1 and 2", n, skipped)
+	}
+	if !errors.Is(skipped[0], ErrInvalid) || skipped[0].Line != 3 {
+		t.Errorf("line 3: got %v, want ErrInvalid", skipped[0])
+	}
+	// end did not parse, its rules are not checked
+	if msg := skipped[1].Error(); errors.Is(skipped[1], ErrInvalid) || strings.Contains(msg, "start") {
+		t.Errorf("line 4: got %v, want only the parse error of end", msg)
+	}
+
+	// Name has no rules
+	if err := (record{"", ""}).Validate(); err != nil {
+		t.Errorf("empty name: %v", err)
+	}
+	err = validateFields[shift]([]string{"", "8", "16", "db", "", ""})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Column != "team" || !errors.Is(err, ErrInvalid) {
+		t.Errorf("shift without team: got %v, want a required team", err)
+	}
+}
+
+func TestValidateSet(t *testing.T) {
+	rules, err := ParseJSONSchema([]byte(`{
+  "properties": {
+    "start": {"minimum": 1, "exclusiveMinimum": 2, "maximum": 10}
+  },
+  "dependentRequired": {"pager": ["grace"]}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type check struct {
+		On bool `csv:"on" validate:"required"`
+		N  int  `csv:"n" validate:"required,min=0"`
+	}
+	v, err := NewValidator[check]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Validate(check{}); err != nil {
+		t.Errorf("false and 0: %v", err)
+	}
+
+	in := "team,start,end,host,pager,grace\nops,0,1,db,,\nops,3,4,db,x,\nops,4,,db,,\nops,,,db,,\n"
+	var skipped []*RowError
+	r := NewReader[shift](strings.NewReader(in), WithRules(rules), Lenient(func(e *RowError) { skipped = append(skipped, e) }))
+	for _, err := range r.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, e := range skipped {
+		for _, err := range e.Err.(interface{ Unwrap() []error }).Unwrap() {
+			var fe *FieldError
+			if errors.As(err, &fe) {
+				got = append(got, fmt.Sprintf("%d %s=%q: %v", e.Line, fe.Column, fe.Value, fe.Err))
+			}
+		}
+	}
+	want := []string{
+		`2 start="0": less than the minimum 1`,
+		`2 start="0": not greater than 2`,
+		`3 grace="": required with pager`,
+		`4 end="": required with start`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,94 @@
+// Package contacts manages the contacts of the team on top of a
+// storage.ContactsRepository: names are required and phones are stored in
+// E.164 form. The contacts can be imported and exported as CSV, and served
+// over REST by NewHandler.
+package contacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+)
+
+// ErrName is returned for a contact without a name.
+var ErrName = errors.New("contact name is empty")
+
+// Service checks and normalises contacts before storing them.
+type Service struct {
+	repo storage.ContactsRepository
+	cc   string
+}
+
+// New returns a Service storing contacts in repo. cc is the country
+// calling code given to national phone numbers, "33" for France, or empty
+// to only accept international ones.
+func New(repo storage.ContactsRepository, cc string) (*Service, error) {
+	if cc != "" && !validCountryCode(cc) {
+		return nil, fmt.Errorf("country calling code %q is not 1 to 3 digits", cc)
+	}
+	return &Service{repo: repo, cc: cc}, nil
+}
+
+// Check returns c with its name trimmed and its phone normalised, see
+// NormalizePhone, or an error wrapping ErrName or ErrPhone.
+func (s *Service) Check(c storage.ContactRec) (storage.ContactRec, error) {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return c, ErrName
+	}
+	phone, err := NormalizePhone(c.Phone, s.cc)
+	if err != nil {
+		return c, fmt.Errorf("contact %s: %w", c.Name, err)
+	}
+	c.Phone = phone
+	return c, nil
+}
+
+// Get returns the contact with id.
+func (s *Service) Get(ctx context.Context, id int) (storage.ContactRec, error) {
+	return s.repo.GetContact(ctx, id)
+}
+
+// Create checks c and stores it under a new id, c.ID is ignored. It
+// returns the contact as stored.
+func (s *Service) Create(ctx context.Context, c storage.ContactRec) (storage.ContactRec, error) {
+	c, err := s.Check(c)
+	if err != nil {
+		return storage.ContactRec{}, err
+	}
+	return s.repo.InsertContact(ctx, c)
+}
+
+// Update checks c and replaces the contact with c.ID. It returns the
+// contact as stored, or an error wrapping storage.ErrNotFound when there is
+// none.
+func (s *Service) Update(ctx context.Context, c storage.ContactRec) (storage.ContactRec, error) {
+	c, err := s.Check(c)
+	if err != nil {
+		return storage.ContactRec{}, err
+	}
+	if err := s.repo.UpdateContact(ctx, c); err != nil {
+		return storage.ContactRec{}, err
+	}
+	return c, nil
+}
+
+// Delete removes the contact with id.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	return s.repo.DeleteContact(ctx, id)
+}
+
+// Search returns a page of the contacts matching f, ordered by id. The
+// phone prefix is normalised like a phone, so "06 12" finds "+33612…"
+// when the calling code is 33.
+func (s *Service) Search(ctx context.Context, f storage.ContactFilter, p storage.Page) ([]storage.ContactRec, error) {
+	prefix, err := normalizePrefix(f.PhonePrefix, s.cc)
+	if err != nil {
+		return nil, err
+	}
+	f.Name, f.PhonePrefix = strings.TrimSpace(f.Name), prefix
+	return s.repo.SearchContacts(ctx, f, p)
+}
@@ -0,0 +1,155 @@
+package contacts_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MoadHar/go_ops/6.remote-data/contacts"
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	for _, tc := range []struct {
+		phone, cc, want string
+	}{
+		{"+33612345678", "", "+33612345678"},
+		{"+33 6 12 34 56 78", "", "+33612345678"},
+		{"0033 6-12-34-56-78", "", "+33612345678"},
+		{"06.12.34.56.78", "33", "+33612345678"},
+		{"+44 (0)20 7123 4567", "", "+442071234567"},
+		{"(415) 555-0100", "1", ""},
+		{"0415 555 0100", "1", "+14155550100"},
+		{"06 12 34 56 78", "", ""},
+		{"+33 6 12 ab", "", ""},
+		{"+0 612345678", "", ""},
+		{"+33 612", "", ""},
+		{"+1234567890123456", "", ""},
+		{"", "33", ""},
+	} {
+		got, err := contacts.NormalizePhone(tc.phone, tc.cc)
+		if tc.want == "" {
+			if !errors.Is(err, contacts.ErrPhone) {
+				t.Errorf("NormalizePhone(%q, %q) = %q, %v, want ErrPhone", tc.phone, tc.cc, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("NormalizePhone(%q, %q) = %q, %v, want %q", tc.phone, tc.cc, got, err, tc.want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	ctx := context.Background()
+	svc, err := contacts.New(storage.NewMemory(), "33")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := svc.ImportCSV(ctx, strings.NewReader(`phone,name,team
+06 12 34 56 78,Ada,ops
++1 415 555 0100,"Bob, Jr."
+12,Carol,ops
++442071234567,  ,ops
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Created != 2 || len(sum.Rejected) != 2 || sum.Rejected[0].Line != 4 || sum.Rejected[1].Line != 5 {
+		t.Errorf("ImportCSV = %+v, want 2 created and lines 4 and 5 rejected", sum)
+	}
+	if !errors.Is(sum.Rejected[0], contacts.ErrPhone) || !errors.Is(sum.Rejected[1], contacts.ErrName) {
+		t.Errorf("rejected for %v and %v, want ErrPhone and ErrName", sum.Rejected[0], sum.Rejected[1])
+	}
+
+	var b strings.Builder
+	n, err := svc.ExportCSV(ctx, &b, storage.ContactFilter{})
+	want := "id,name,phone\n1,Ada,+33612345678\n2,\"Bob, Jr.\",+14155550100\n"
+	if err != nil || n != 2 || b.String() != want {
+		t.Errorf("ExportCSV = %d, %v, wrote\n%s\nwant\n%s", n, err, b.String(), want)
+	}
+
+	if _, err := svc.ImportCSV(ctx, strings.NewReader("id,phone\n1,+33612345678\n")); !errors.Is(err, contacts.ErrCSV) {
+		t.Errorf("ImportCSV without a name column: got %v, want ErrCSV", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	svc, err := contacts.New(storage.NewMemory(), "33")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(contacts.NewHandler(svc))
+	defer srv.Close()
+
+	do := func(method, path, body string, wantStatus int, resp any) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != wantStatus {
+			t.Fatalf("%s %s: status %d, want %d", method, path, res.StatusCode, wantStatus)
+		}
+		if resp != nil {
+			if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+	}
+
+	var ada storage.ContactRec
+	do("POST", "/contacts/v1/", `{"name": "Ada", "phone": "06 12 34 56 78"}`, http.StatusCreated, &ada)
+	if ada.ID == 0 || ada.Phone != "+33612345678" {
+		t.Errorf("created %+v, want an id and a normalised phone", ada)
+	}
+	var got storage.ContactRec
+	do("GET", "/contacts/v1/1", "", http.StatusOK, &got)
+	if got != ada {
+		t.Errorf("GET = %+v, want %+v", got, ada)
+	}
+	do("PUT", "/contacts/v1/1", `{"name": "Ada Lovelace", "phone": "+44 20 7123 4567"}`, http.StatusOK, &got)
+	if want := (storage.ContactRec{ID: 1, Name: "Ada Lovelace", Phone: "+442071234567"}); got != want {
+		t.Errorf("PUT = %+v, want %+v", got, want)
+	}
+
+	var found []storage.ContactRec
+	do("GET", "/contacts/v1/?name=LOVE&phone=%2B44", "", http.StatusOK, &found)
+	if len(found) != 1 || found[0].ID != 1 {
+		t.Errorf("search = %+v, want contact 1", found)
+	}
+
+	var e struct{ Error contacts.Error }
+	do("POST", "/contacts/v1/", `{"name": "Bob", "phone": "12"}`, http.StatusBadRequest, &e)
+	if e.Error.Code != contacts.Invalid {
+		t.Errorf("bad phone: error %+v, want Invalid", e.Error)
+	}
+	do("GET", "/contacts/v1/?limit=-1", "", http.StatusBadRequest, nil)
+	do("DELETE", "/contacts/v1/1", "", http.StatusNoContent, nil)
+	do("GET", "/contacts/v1/1", "", http.StatusNotFound, &e)
+	if e.Error.Code != contacts.NotFound {
+		t.Errorf("deleted contact: error %+v, want NotFound", e.Error)
+	}
+
+	var imp struct {
+		Created  int
+		Rejected []struct {
+			Line  int
+			Error string
+		}
+	}
+	do("POST", "/contacts/v1/import", "name,phone\nBob,+14155550100\nCarol,x\n", http.StatusOK, &imp)
+	if imp.Created != 1 || len(imp.Rejected) != 1 || imp.Rejected[0].Line != 3 {
+		t.Errorf("import = %+v, want 1 created and line 3 rejected", imp)
+	}
+	do("POST", "/contacts/v1/import", "name\nBob\n", http.StatusBadRequest, nil)
+}
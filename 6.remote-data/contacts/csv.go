@@ -0,0 +1,125 @@
+package contacts
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+)
+
+// ErrCSV is returned, wrapped, by ImportCSV for a malformed CSV input.
+var ErrCSV = errors.New("invalid contacts CSV")
+
+// RowError is a CSV row rejected by ImportCSV.
+type RowError struct {
+	// Line is the line of the row in the input, from 1.
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+func (e *RowError) Unwrap() error { return e.Err }
+
+// ImportSummary tells what ImportCSV did.
+type ImportSummary struct {
+	Created  int
+	Rejected []*RowError
+}
+
+// ImportCSV creates a contact for every row of the CSV in r. The first row
+// is a header naming the columns: "name" and "phone" are required, the
+// others, such as the "id" of ExportCSV, are ignored.
+//
+// Rows failing Check are rejected and the import goes on. A malformed CSV,
+// see ErrCSV, or a storage error stops it, the contacts created so far are
+// kept.
+func (s *Service) ImportCSV(ctx context.Context, r io.Reader) (ImportSummary, error) {
+	var sum ImportSummary
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return sum, fmt.Errorf("import contacts: %w: no header", ErrCSV)
+	}
+	if err != nil {
+		return sum, csvError(err)
+	}
+	name, phone := -1, -1
+	for i, col := range header {
+		switch strings.ToLower(strings.TrimSpace(col)) {
+		case "name":
+			name = i
+		case "phone":
+			phone = i
+		}
+	}
+	if name < 0 || phone < 0 {
+		return sum, fmt.Errorf("import contacts: %w: header %q lacks a name or a phone column", ErrCSV, header)
+	}
+
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return sum, nil
+		}
+		if err != nil {
+			return sum, csvError(err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) <= max(name, phone) {
+			sum.Rejected = append(sum.Rejected, &RowError{Line: line, Err: fmt.Errorf("%d fields, want %d", len(rec), len(header))})
+			continue
+		}
+		_, err = s.Create(ctx, storage.ContactRec{Name: rec[name], Phone: rec[phone]})
+		switch {
+		case errors.Is(err, ErrName) || errors.Is(err, ErrPhone):
+			sum.Rejected = append(sum.Rejected, &RowError{Line: line, Err: err})
+		case err != nil:
+			return sum, fmt.Errorf("import contacts: line %d: %w", line, err)
+		default:
+			sum.Created++
+		}
+	}
+}
+
+// csvError wraps an error of the CSV reader, with ErrCSV for a syntax
+// error.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("import contacts: %w: %w", ErrCSV, err)
+	}
+	return fmt.Errorf("import contacts: %w", err)
+}
+
+// ExportCSV writes the contacts matching f to w as CSV, with an
+// "id,name,phone" header, and returns how many were written.
+func (s *Service) ExportCSV(ctx context.Context, w io.Writer, f storage.ContactFilter) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "name", "phone"}); err != nil {
+		return 0, err
+	}
+	n := 0
+	for p := (storage.Page{Limit: storage.DefaultPageSize}); ; p.Offset += p.Limit {
+		page, err := s.Search(ctx, f, p)
+		if err != nil {
+			return n, fmt.Errorf("export contacts: %w", err)
+		}
+		for _, c := range page {
+			if err := cw.Write([]string{strconv.Itoa(c.ID), c.Name, c.Phone}); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(page) < p.Limit {
+			break
+		}
+	}
+	cw.Flush()
+	return n, cw.Error()
+}
@@ -0,0 +1,240 @@
+package contacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+)
+
+// ErrCode tells a REST client what kind of error it got.
+type ErrCode string
+
+const (
+	Internal ErrCode = "Internal"
+	Invalid  ErrCode = "Invalid"
+	NotFound ErrCode = "NotFound"
+	Conflict ErrCode = "Conflict"
+)
+
+// Error is the body of the REST responses for an error.
+type Error struct {
+	Code ErrCode `json:"code"`
+	Msg  string  `json:"msg"`
+}
+
+// maxBody caps the size of a request body, a CSV import included.
+const maxBody = 32 << 20
+
+// NewHandler returns the REST API of s:
+//
+//	GET    /contacts/v1/?name=&phone=&offset=&limit=  search, see Service.Search
+//	POST   /contacts/v1/                              create, from a JSON contact
+//	GET    /contacts/v1/{id}
+//	PUT    /contacts/v1/{id}                          update, from a JSON contact
+//	DELETE /contacts/v1/{id}
+//	GET    /contacts/v1/export?name=&phone=           the contacts as CSV
+//	POST   /contacts/v1/import                        a CSV body, see ImportCSV
+//
+// Contacts are JSON objects {"id", "name", "phone"}. Errors are a JSON
+// {"error": Error} with a 4xx or 5xx status.
+func NewHandler(s *Service) http.Handler {
+	h := &handler{s: s}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /contacts/v1/{$}", h.search)
+	mux.HandleFunc("POST /contacts/v1/{$}", h.create)
+	mux.HandleFunc("GET /contacts/v1/{id}", h.get)
+	mux.HandleFunc("PUT /contacts/v1/{id}", h.update)
+	mux.HandleFunc("DELETE /contacts/v1/{id}", h.delete)
+	mux.HandleFunc("GET /contacts/v1/export", h.exportCSV)
+	mux.HandleFunc("POST /contacts/v1/import", h.importCSV)
+	return mux
+}
+
+type handler struct {
+	s *Service
+}
+
+func (h *handler) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var p storage.Page
+	for name, n := range map[string]*int{"offset": &p.Offset, "limit": &p.Limit} {
+		if v := q.Get(name); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				writeError(w, http.StatusBadRequest, Invalid, fmt.Sprintf("%s %q is not a number >= 0", name, v))
+				return
+			}
+			*n = i
+		}
+	}
+	found, err := h.s.Search(r.Context(), filter(r), p)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+func (h *handler) create(w http.ResponseWriter, r *http.Request) {
+	c, ok := readContact(w, r)
+	if !ok {
+		return
+	}
+	c, err := h.s.Create(r.Context(), c)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/contacts/v1/%d", c.ID))
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	c, err := h.s.Get(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *handler) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	c, ok := readContact(w, r)
+	if !ok {
+		return
+	}
+	c.ID = id
+	c, err := h.s.Update(r.Context(), c)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.s.Delete(r.Context(), id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) exportCSV(w http.ResponseWriter, r *http.Request) {
+	// checked first, the status can't change once the CSV is under way
+	f := filter(r)
+	if _, err := normalizePrefix(f.PhonePrefix, h.s.cc); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="contacts.csv"`)
+	// a failure past this point can only cut the CSV short
+	h.s.ExportCSV(r.Context(), w, f)
+}
+
+// importResp is the response to a CSV import.
+type importResp struct {
+	Created  int           `json:"created"`
+	Rejected []rejectedRow `json:"rejected"`
+}
+
+type rejectedRow struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+func (h *handler) importCSV(w http.ResponseWriter, r *http.Request) {
+	sum, err := h.s.ImportCSV(r.Context(), http.MaxBytesReader(w, r.Body, maxBody))
+	var tooBig *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooBig):
+		writeError(w, http.StatusRequestEntityTooLarge, Invalid,
+			fmt.Sprintf("body over %d bytes, %d contacts created", tooBig.Limit, sum.Created))
+		return
+	case errors.Is(err, ErrCSV):
+		writeError(w, http.StatusBadRequest, Invalid, fmt.Sprintf("%v, %d contacts created", err, sum.Created))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, Internal, fmt.Sprintf("internal error, %d contacts created", sum.Created))
+		return
+	}
+	resp := importResp{Created: sum.Created, Rejected: []rejectedRow{}}
+	for _, e := range sum.Rejected {
+		resp.Rejected = append(resp.Rejected, rejectedRow{Line: e.Line, Error: e.Err.Error()})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// filter reads the name and phone parameters of a search or an export.
+func filter(r *http.Request) storage.ContactFilter {
+	q := r.URL.Query()
+	return storage.ContactFilter{Name: q.Get("name"), PhonePrefix: q.Get("phone")}
+}
+
+// pathID returns the id of the URL, or writes an error when it is not a
+// number.
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, NotFound, fmt.Sprintf("no contact %q", r.PathValue("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+// readContact decodes the JSON contact of the request body, or writes an
+// error.
+func readContact(w http.ResponseWriter, r *http.Request) (storage.ContactRec, bool) {
+	var c storage.ContactRec
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		writeError(w, http.StatusBadRequest, Invalid, "contact: "+err.Error())
+		return c, false
+	}
+	return c, true
+}
+
+// writeServiceError writes err with the status its kind calls for.
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrName) || errors.Is(err, ErrPhone):
+		writeError(w, http.StatusBadRequest, Invalid, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		writeError(w, http.StatusNotFound, NotFound, err.Error())
+	case errors.Is(err, storage.ErrConflict):
+		writeError(w, http.StatusConflict, Conflict, err.Error())
+	default:
+		// storage errors may tell too much about the database
+		writeError(w, http.StatusInternalServerError, Internal, "internal error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code ErrCode, msg string) {
+	writeJSON(w, status, struct {
+		Error Error `json:"error"`
+	}{Error{Code: code, Msg: msg}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,111 @@
+package contacts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPhone is returned, wrapped with the reason, for a phone number that
+// cannot be turned into E.164 form.
+var ErrPhone = errors.New("not a valid E.164 phone number")
+
+// minDigits and maxDigits bound the digits of an E.164 number, calling
+// code included. E.164 only sets the maximum; no country has numbers
+// shorter than 7 digits.
+const (
+	minDigits = 7
+	maxDigits = 15
+)
+
+// NormalizePhone returns phone in E.164 form: a "+" and the digits of the
+// country calling code and the national number, "+33612345678".
+//
+// Spaces, dots, dashes, slashes and parentheses are dropped, as is the
+// trunk prefix written "(0)" in "+44 (0)20 7123 4567". A leading "00"
+// stands for "+". A national number, starting with a single 0, is given
+// the calling code cc, "33" for France; it is rejected when cc is empty.
+func NormalizePhone(phone, cc string) (string, error) {
+	digits, err := phoneDigits(phone, cc)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(digits) < minDigits:
+		return "", phoneError(phone, "too short")
+	case len(digits) > maxDigits:
+		return "", phoneError(phone, "too long")
+	}
+	return "+" + digits, nil
+}
+
+// normalizePrefix is NormalizePhone for the start of a number, to search
+// by phone prefix. An empty prefix stays empty.
+func normalizePrefix(prefix, cc string) (string, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return "", nil
+	}
+	digits, err := phoneDigits(prefix, cc)
+	if err != nil {
+		return "", err
+	}
+	if len(digits) > maxDigits {
+		return "", phoneError(prefix, "too long")
+	}
+	return "+" + digits, nil
+}
+
+// phoneDigits returns the digits of phone in international form, without
+// the "+".
+func phoneDigits(phone, cc string) (string, error) {
+	s := strings.TrimSpace(phone)
+	intl := strings.HasPrefix(s, "+")
+	if intl {
+		s = strings.ReplaceAll(s[1:], "(0)", "")
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune(" .-/()", r):
+		default:
+			return "", phoneError(phone, fmt.Sprintf("unexpected %q", r))
+		}
+	}
+	digits := b.String()
+	switch {
+	case digits == "":
+		return "", phoneError(phone, "no digits")
+	case intl:
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case digits[0] == '0':
+		if cc == "" {
+			return "", phoneError(phone, "national number without a country calling code")
+		}
+		digits = cc + digits[1:]
+	default:
+		return "", phoneError(phone, `neither international, starting with "+" or "00", nor national, starting with "0"`)
+	}
+	switch {
+	case digits == "":
+		return "", phoneError(phone, "no country calling code")
+	case digits[0] == '0':
+		return "", phoneError(phone, "country calling code starts with 0")
+	}
+	return digits, nil
+}
+
+// validCountryCode reports whether cc is a country calling code: 1 to 3
+// digits, the first not 0.
+func validCountryCode(cc string) bool {
+	if len(cc) < 1 || len(cc) > 3 || cc[0] == '0' {
+		return false
+	}
+	return strings.Trim(cc, "0123456789") == ""
+}
+
+func phoneError(phone, why string) error {
+	return fmt.Errorf("phone %q: %s: %w", phone, why, ErrPhone)
+}
@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgpassfile"
+)
+
+// Defaults of DBConfig, applied by OpenDB to the zero fields.
+const (
+	DefaultMaxOpenConns    = 10
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 30 * time.Minute
+	DefaultConnMaxIdleTime = 5 * time.Minute
+	DefaultConnectTimeout  = 30 * time.Second
+)
+
+// DBConfig says how to reach the Postgres database and how to size the
+// connection pool.
+type DBConfig struct {
+	// URL is a postgres:// URL. The connection fields below, when set,
+	// replace its parts.
+	URL      string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	SSLMode  string
+	// PassFile is a .pgpass file looked up for the password when there is
+	// none, ~/.pgpass when empty. A missing file is not an error.
+	PassFile string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// ConnectTimeout is how long OpenDB keeps trying to reach the database.
+	ConnectTimeout time.Duration
+
+	// SlowQuery is the duration from which the queries of a Storage are
+	// logged with Logf, 0 for none. Trace is where their spans go, a file
+	// or "-" for stdout, none when empty. See StorageOptions.
+	SlowQuery time.Duration
+	Trace     string
+
+	// Logf, if not nil, logs the failed connection attempts of OpenDB and
+	// the slow queries.
+	Logf func(format string, args ...any)
+}
+
+// DBConfigFromEnv returns the DBConfig of the environment: GOOPS_DB_URL,
+// the PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE, PGSSLMODE and
+// PGPASSFILE variables of libpq, and for the pool GOOPS_DB_MAX_OPEN_CONNS,
+// GOOPS_DB_MAX_IDLE_CONNS, GOOPS_DB_CONN_MAX_LIFETIME,
+// GOOPS_DB_CONN_MAX_IDLE_TIME and GOOPS_DB_CONNECT_TIMEOUT, the last three
+// being durations such as "5m". The queries are traced by
+// GOOPS_DB_SLOW_QUERY, a duration, and GOOPS_DB_TRACE.
+func DBConfigFromEnv() (DBConfig, error) {
+	c := DBConfig{
+		URL:      os.Getenv("GOOPS_DB_URL"),
+		Host:     os.Getenv("PGHOST"),
+		User:     os.Getenv("PGUSER"),
+		Password: os.Getenv("PGPASSWORD"),
+		Database: os.Getenv("PGDATABASE"),
+		SSLMode:  os.Getenv("PGSSLMODE"),
+		PassFile: os.Getenv("PGPASSFILE"),
+		Trace:    os.Getenv("GOOPS_DB_TRACE"),
+	}
+	var errs []error
+	envInt := func(name string, dst *int) {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				errs = append(errs, fmt.Errorf("%s=%q is not a number >= 0", name, v))
+			}
+			*dst = n
+		}
+	}
+	envDuration := func(name string, dst *time.Duration) {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				errs = append(errs, fmt.Errorf("%s=%q is not a duration >= 0", name, v))
+			}
+			*dst = d
+		}
+	}
+	envInt("PGPORT", &c.Port)
+	envInt("GOOPS_DB_MAX_OPEN_CONNS", &c.MaxOpenConns)
+	envInt("GOOPS_DB_MAX_IDLE_CONNS", &c.MaxIdleConns)
+	envDuration("GOOPS_DB_CONN_MAX_LIFETIME", &c.ConnMaxLifetime)
+	envDuration("GOOPS_DB_CONN_MAX_IDLE_TIME", &c.ConnMaxIdleTime)
+	envDuration("GOOPS_DB_CONNECT_TIMEOUT", &c.ConnectTimeout)
+	envDuration("GOOPS_DB_SLOW_QUERY", &c.SlowQuery)
+	return c, errors.Join(errs...)
+}
+
+// Configured reports whether c says where the database is, by a URL or a
+// host.
+func (c DBConfig) Configured() bool {
+	return c.URL != "" || c.Host != ""
+}
+
+// DSN returns the postgres:// URL of c, with the password of the pass file
+// when c has none.
+func (c DBConfig) DSN() (string, error) {
+	u, err := c.url()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := u.User.Password(); !ok {
+		if password := c.passFilePassword(u); password != "" {
+			u.User = url.UserPassword(u.User.Username(), password)
+		}
+	}
+	return u.String(), nil
+}
+
+// Redacted returns the URL of c with its password, if any, replaced by
+// "xxxxx", to be logged. The pass file is not read.
+func (c DBConfig) Redacted() string {
+	u, err := c.url()
+	if err != nil {
+		return "invalid database URL"
+	}
+	return u.Redacted()
+}
+
+// url returns the URL of c, without the password of the pass file.
+func (c DBConfig) url() (*url.URL, error) {
+	u := &url.URL{Scheme: "postgres"}
+	if c.URL != "" {
+		var err error
+		if u, err = url.Parse(c.URL); err != nil {
+			// the error quotes the URL, password included
+			return nil, errors.New("database URL is not a valid URL")
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return nil, fmt.Errorf("database URL scheme %q is not postgres", u.Scheme)
+		}
+	}
+	if c.Host != "" || c.Port != 0 {
+		host, port := u.Hostname(), u.Port()
+		if c.Host != "" {
+			host = c.Host
+		}
+		if c.Port != 0 {
+			port = strconv.Itoa(c.Port)
+		}
+		u.Host = host
+		if port != "" {
+			u.Host = net.JoinHostPort(host, port)
+		}
+		if strings.HasPrefix(host, "/") {
+			// a unix socket directory goes in the query
+			q := u.Query()
+			q.Set("host", host)
+			u.RawQuery = q.Encode()
+			u.Host = ""
+			if port != "" {
+				u.Host = ":" + port
+			}
+		}
+	}
+	if c.User != "" || c.Password != "" {
+		user, password := u.User.Username(), ""
+		if p, ok := u.User.Password(); ok {
+			password = p
+		}
+		if c.User != "" {
+			user = c.User
+		}
+		if c.Password != "" {
+			password = c.Password
+		}
+		u.User = url.User(user)
+		if password != "" {
+			u.User = url.UserPassword(user, password)
+		}
+	}
+	if c.Database != "" {
+		u.Path = "/" + c.Database
+	}
+	if c.SSLMode != "" {
+		q := u.Query()
+		q.Set("sslmode", c.SSLMode)
+		u.RawQuery = q.Encode()
+	}
+	return u, nil
+}
+
+// passFilePassword returns the password of the pass file for u, or "".
+// The defaults of the fields missing from u are the ones of libpq.
+func (c DBConfig) passFilePassword(u *url.URL) string {
+	path := c.PassFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+	pf, err := pgpassfile.ReadPassfile(path)
+	if err != nil {
+		return ""
+	}
+	host, port, user := u.Hostname(), u.Port(), u.User.Username()
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "5432"
+	}
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	database := u.Path
+	if len(database) > 0 {
+		database = database[1:]
+	}
+	if database == "" {
+		database = user
+	}
+	return pf.FindPassword(host, port, database, user)
+}
+
+// OpenDB opens the database of c and sizes its pool, then pings it until
+// it answers, backing off between attempts, for up to c.ConnectTimeout.
+func OpenDB(ctx context.Context, c DBConfig) (*sql.DB, error) {
+	dsn, err := c.DSN()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", c.Redacted(), err)
+	}
+	db.SetMaxOpenConns(cmp.Or(c.MaxOpenConns, DefaultMaxOpenConns))
+	db.SetMaxIdleConns(cmp.Or(c.MaxIdleConns, DefaultMaxIdleConns))
+	db.SetConnMaxLifetime(cmp.Or(c.ConnMaxLifetime, DefaultConnMaxLifetime))
+	db.SetConnMaxIdleTime(cmp.Or(c.ConnMaxIdleTime, DefaultConnMaxIdleTime))
+
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(c.ConnectTimeout, DefaultConnectTimeout))
+	defer cancel()
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			if attempt > 1 && c.Logf != nil {
+				c.Logf("connected to %s", c.Redacted())
+			}
+			return db, nil
+		}
+		// from 250ms, doubling up to 8s, with jitter
+		wait := 250 * time.Millisecond << min(attempt-1, 5)
+		wait = wait/2 + rand.N(wait/2)
+		if c.Logf != nil {
+			c.Logf("connect to %s, attempt %d: %v, retrying in %v", c.Redacted(), attempt, err, wait.Round(time.Millisecond))
+		}
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("connect to %s: %w", c.Redacted(), err)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// StorageOptions returns the options of NewStorage tracing the queries as
+// c says, and the function flushing the spans, to call once the Storage is
+// closed.
+func (c DBConfig) StorageOptions() ([]Option, func(context.Context) error, error) {
+	var opts []Option
+	if c.SlowQuery > 0 && c.Logf != nil {
+		opts = append(opts, SlowQueries(c.SlowQuery, c.Logf))
+	}
+	if c.Trace == "" {
+		return opts, func(context.Context) error { return nil }, nil
+	}
+	tp, err := NewTracerProvider(c.Trace)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(opts, Traced(tp)), tp.Shutdown, nil
+}
+
+// dbVars holds the expvars published by ExportStats.
+var dbVars = expvar.NewMap("db")
+
+// ExportStats publishes the sql.DBStats of db as the expvar db.<name>,
+// served as JSON with the other expvars by expvar.Handler, and refreshes
+// them every interval until ctx is done.
+func ExportStats(ctx context.Context, db *sql.DB, name string, every time.Duration) {
+	vars := new(expvar.Map)
+	set := func(key string, v int64) {
+		i, ok := vars.Get(key).(*expvar.Int)
+		if !ok {
+			i = new(expvar.Int)
+			vars.Set(key, i)
+		}
+		i.Set(v)
+	}
+	update := func() {
+		st := db.Stats()
+		set("max_open_connections", int64(st.MaxOpenConnections))
+		set("open_connections", int64(st.OpenConnections))
+		set("in_use", int64(st.InUse))
+		set("idle", int64(st.Idle))
+		set("wait_count", st.WaitCount)
+		set("wait_duration_ms", st.WaitDuration.Milliseconds())
+		set("max_idle_closed", st.MaxIdleClosed)
+		set("max_idle_time_closed", st.MaxIdleTimeClosed)
+		set("max_lifetime_closed", st.MaxLifetimeClosed)
+	}
+	update()
+	dbVars.Set(name, vars)
+	go func() {
+		tick := time.NewTicker(every)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				update()
+			}
+		}
+	}()
+}
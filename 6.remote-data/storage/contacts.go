@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContactRec is one row of the contacts table.
+type ContactRec struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+}
+
+// ContactFilter selects contacts by the case insensitive substring Name of
+// their name and the prefix PhonePrefix of their phone. Empty fields match
+// every contact.
+type ContactFilter struct {
+	Name        string
+	PhonePrefix string
+}
+
+// GetContact returns the contact with user_id id.
+func (s *Storage) GetContact(ctx context.Context, id int) (ContactRec, error) {
+	const query = `SELECT "contact_name", "phone" FROM contacts WHERE "user_id" = $1`
+	ctx, q := s.startQuery(ctx, "get contact", query, id)
+	contact := ContactRec{ID: id}
+	err := s.conn.QueryRowContext(ctx, query, id).Scan(&contact.Name, &contact.Phone)
+	if err != nil {
+		return ContactRec{}, q.end(0, fmt.Errorf("contact %d: %w", id, dbError(ctx, err)))
+	}
+	return contact, q.end(1, nil)
+}
+
+// InsertContact adds c under a new user_id and returns it with its id.
+func (s *Storage) InsertContact(ctx context.Context, c ContactRec) (ContactRec, error) {
+	const query = `INSERT INTO contacts (contact_name, phone) VALUES ($1, $2) RETURNING user_id`
+	ctx, q := s.startQuery(ctx, "insert contact", query, c.Name, c.Phone)
+	if err := s.conn.QueryRowContext(ctx, query, c.Name, c.Phone).Scan(&c.ID); err != nil {
+		return ContactRec{}, q.end(0, fmt.Errorf("insert contact: %w", dbError(ctx, err)))
+	}
+	return c, q.end(1, nil)
+}
+
+// UpdateContact changes the name and phone of the contact with c.ID. It
+// returns ErrNotFound when there is none.
+func (s *Storage) UpdateContact(ctx context.Context, c ContactRec) error {
+	const query = `UPDATE contacts SET contact_name = $2, phone = $3 WHERE user_id = $1`
+	ctx, q := s.startQuery(ctx, "update contact", query, c.ID, c.Name, c.Phone)
+	res, err := s.conn.ExecContext(ctx, query, c.ID, c.Name, c.Phone)
+	return q.endExec(res, affected(ctx, res, err, "update contact %d", c.ID))
+}
+
+// DeleteContact removes the contact with user_id id. It returns ErrNotFound
+// when there is none.
+func (s *Storage) DeleteContact(ctx context.Context, id int) error {
+	const query = `DELETE FROM contacts WHERE user_id = $1`
+	ctx, q := s.startQuery(ctx, "delete contact", query, id)
+	res, err := s.conn.ExecContext(ctx, query, id)
+	return q.endExec(res, affected(ctx, res, err, "delete contact %d", id))
+}
+
+// SearchContacts returns a page of the contacts matching f ordered by id.
+func (s *Storage) SearchContacts(ctx context.Context, f ContactFilter, p Page) (contacts []ContactRec, err error) {
+	const query = `SELECT user_id, contact_name, phone FROM contacts
+		WHERE strpos(lower(contact_name), lower($1)) > 0 AND left(phone, length($2)) = $2
+		ORDER BY user_id LIMIT $3 OFFSET $4`
+	offset, limit := p.bounds()
+	ctx, q := s.startQuery(ctx, "search contacts", query, f.Name, f.PhonePrefix, limit, offset)
+	defer func() { q.end(int64(len(contacts)), err) }()
+	rows, err := s.conn.QueryContext(ctx, query, f.Name, f.PhonePrefix, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("search contacts: %w", dbError(ctx, err))
+	}
+	defer rows.Close()
+	contacts = []ContactRec{}
+	for rows.Next() {
+		var c ContactRec
+		if err := rows.Scan(&c.ID, &c.Name, &c.Phone); err != nil {
+			return nil, fmt.Errorf("search contacts: %w", err)
+		}
+		contacts = append(contacts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search contacts: %w", dbError(ctx, err))
+	}
+	return contacts, nil
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"streamz"
+)
+
+// ViewKey groups the views of one access to a file.
+type ViewKey struct {
+	File   string
+	Access string
+}
+
+// ChangeKind is what happened to a view between the database and a
+// FILEVUEP file.
+type ChangeKind int
+
+const (
+	// Added views are in the file only.
+	Added ChangeKind = iota
+	// Removed views are in the database only.
+	Removed
+	// Changed views have another path, position or pathfile in the file.
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// ViewChange is one view that differs. Old is the database row, New the
+// row from the file; the one that does not exist is zero.
+type ViewChange struct {
+	Kind ChangeKind
+	Old  ViewsFile
+	New  ViewsFile
+}
+
+// View returns the row the change is about, New unless it was removed.
+func (c ViewChange) View() ViewsFile {
+	if c.Kind == Removed {
+		return c.Old
+	}
+	return c.New
+}
+
+// KeyDiff is the changes of one file and access, ordered by position.
+type KeyDiff struct {
+	Key     ViewKey
+	Changes []ViewChange
+}
+
+// DiffOptions tunes DiffViews.
+type DiffOptions struct {
+	// AllKeys also reports the file and access pairs that are in the
+	// database but not in the FILEVUEP file, all their views as removed.
+	// Without it the file is taken as a partial extract.
+	AllKeys bool
+	// PathFile gives the pathfile column of a record, its Table when nil,
+	// as for ImportViews.
+	PathFile func(streamz.Filevuep) string
+}
+
+// DiffViews compares the records of a FILEVUEP decoder with the viewsfile
+// table and returns the differences ordered by file and access. The
+// records are held in memory, grouped by file and access, and every group
+// is compared with the rows of GetFileView. A view appearing twice in the
+// file is taken at its last occurrence, as ImportViews does.
+func (s *Storage) DiffViews(ctx context.Context, results <-chan streamz.Result, opts DiffOptions) ([]KeyDiff, error) {
+	defer func() {
+		for range results {
+		}
+	}()
+	pathFile := opts.PathFile
+	if pathFile == nil {
+		pathFile = func(v streamz.Filevuep) string { return v.Table }
+	}
+
+	extract := map[ViewKey]map[string]ViewsFile{}
+	for r := range results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("diff views: %w", r.Err)
+		}
+		key := ViewKey{File: r.Table, Access: r.Method}
+		if extract[key] == nil {
+			extract[key] = map[string]ViewsFile{}
+		}
+		extract[key][r.View] = ViewsFile{
+			File: r.Table, View: r.View, Access: r.Method, Path: r.Path, Order: r.Pos, Pathfile: pathFile(r.Filevuep),
+		}
+	}
+
+	keys := make(map[ViewKey]bool, len(extract))
+	for key := range extract {
+		keys[key] = true
+	}
+	if opts.AllKeys {
+		for v, err := range s.Views(ctx, ViewFilter{}) {
+			if err != nil {
+				return nil, fmt.Errorf("diff views: %w", err)
+			}
+			keys[ViewKey{File: v.File, Access: v.Access}] = true
+		}
+	}
+
+	var diffs []KeyDiff
+	for key := range keys {
+		rows, err := s.GetFileView(ctx, key.File, key.Access)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("diff views: %w", err)
+		}
+		if d := diffKey(key, rows, extract[key]); len(d.Changes) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	slices.SortFunc(diffs, func(a, b KeyDiff) int {
+		return cmp.Or(cmp.Compare(a.Key.File, b.Key.File), cmp.Compare(a.Key.Access, b.Key.Access))
+	})
+	return diffs, nil
+}
+
+// diffKey compares the database rows of key with the file's views.
+func diffKey(key ViewKey, rows []ViewsFile, file map[string]ViewsFile) KeyDiff {
+	d := KeyDiff{Key: key}
+	inDB := make(map[string]bool, len(rows))
+	for _, old := range rows {
+		inDB[old.View] = true
+		v, ok := file[old.View]
+		switch {
+		case !ok:
+			d.Changes = append(d.Changes, ViewChange{Kind: Removed, Old: old})
+		case v.Path != old.Path || v.Order != old.Order || v.Pathfile != old.Pathfile:
+			d.Changes = append(d.Changes, ViewChange{Kind: Changed, Old: old, New: v})
+		}
+	}
+	for name, v := range file {
+		if !inDB[name] {
+			d.Changes = append(d.Changes, ViewChange{Kind: Added, New: v})
+		}
+	}
+	slices.SortFunc(d.Changes, func(a, b ViewChange) int {
+		return cmp.Or(cmp.Compare(a.View().Order, b.View().Order), cmp.Compare(a.View().View, b.View().View))
+	})
+	return d
+}
+
+// Stmt is a SQL statement with its arguments.
+type Stmt struct {
+	Query string
+	Args  []any
+}
+
+// String returns the statement with its arguments inlined as literals,
+// for review.
+func (s Stmt) String() string {
+	var b strings.Builder
+	q := s.Query
+	for {
+		i := strings.IndexByte(q, '$')
+		if i < 0 {
+			break
+		}
+		j := i + 1
+		for j < len(q) && q[j] >= '0' && q[j] <= '9' {
+			j++
+		}
+		b.WriteString(q[:i])
+		n, _ := strconv.Atoi(q[i+1 : j])
+		if n < 1 || n > len(s.Args) {
+			// not a placeholder, or one without an argument
+			b.WriteString(q[i:j])
+			q = q[j:]
+			continue
+		}
+		switch v := s.Args[n-1].(type) {
+		case string:
+			b.WriteString("'" + strings.ReplaceAll(v, "'", "''") + "'")
+		default:
+			fmt.Fprint(&b, v)
+		}
+		q = q[j:]
+	}
+	b.WriteString(q)
+	return b.String()
+}
+
+// Statements returns the statements that make the database match the
+// file for the changes in d.
+func (d KeyDiff) Statements() []Stmt {
+	var stmts []Stmt
+	for _, c := range d.Changes {
+		v := c.View()
+		switch c.Kind {
+		case Added:
+			stmts = append(stmts, Stmt{
+				Query: `INSERT INTO viewsfile (file, view, access, path, position, pathfile) VALUES ($1, $2, $3, $4, $5, $6)`,
+				Args:  []any{v.File, v.View, v.Access, v.Path, v.Order, v.Pathfile},
+			})
+		case Removed:
+			stmts = append(stmts, Stmt{
+				Query: `DELETE FROM viewsfile WHERE file = $1 AND access = $2 AND view = $3`,
+				Args:  []any{v.File, v.Access, v.View},
+			})
+		case Changed:
+			stmts = append(stmts, Stmt{
+				Query: `UPDATE viewsfile SET path = $4, position = $5, pathfile = $6 WHERE file = $1 AND access = $2 AND view = $3`,
+				Args:  []any{v.File, v.Access, v.View, v.Path, v.Order, v.Pathfile},
+			})
+		}
+	}
+	return stmts
+}
+
+// WriteSQL writes the statements of diffs as a script run in a single
+// transaction.
+func WriteSQL(w io.Writer, diffs []KeyDiff) error {
+	if _, err := fmt.Fprintln(w, "BEGIN;"); err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		fmt.Fprintf(w, "-- %s %s\n", d.Key.File, d.Key.Access)
+		for _, st := range d.Statements() {
+			fmt.Fprintf(w, "%s;\n", st)
+		}
+	}
+	_, err := fmt.Fprintln(w, "COMMIT;")
+	return err
+}
+
+// Reconcile applies the statements of diffs in one transaction. It
+// returns ErrConflict, and applies nothing, when a view to update or
+// delete is not there anymore: the table changed since the diff.
+func (s *Storage) Reconcile(ctx context.Context, diffs []KeyDiff) error {
+	return s.WithTx(ctx, func(tx *Tx) error {
+		for _, d := range diffs {
+			// Statements has one statement per change
+			for i, st := range d.Statements() {
+				ctx, q := tx.startQuery(ctx, "reconcile", st.Query, st.Args...)
+				res, err := tx.conn.ExecContext(ctx, st.Query, st.Args...)
+				if err == nil && d.Changes[i].Kind != Added {
+					var n int64
+					if n, err = res.RowsAffected(); err == nil && n == 0 {
+						err = ErrConflict
+					}
+				}
+				if err != nil {
+					// the arguments stay out of the error, which may be logged
+					err = fmt.Errorf("reconcile %s %s: %s: %w", d.Key.File, d.Key.Access, oneLine(st.Query), dbError(ctx, err))
+				}
+				if err := q.endExec(res, err); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func view(name, path string, pos int) ViewsFile {
+	return ViewsFile{File: "F100", View: name, Access: "LINKPATH", Path: path, Order: pos, Pathfile: "F100"}
+}
+
+// newSQLite returns a Storage with opts on an in-memory SQLite database
+// holding enough of the schema for the queries that do not need Postgres.
+// The contact names are unique so inserting one twice fails.
+func newSQLite(t *testing.T, opts ...Option) *Storage {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// every connection would have its own database
+	db.SetMaxOpenConns(1)
+	for _, q := range []string{
+		`CREATE TABLE viewsfile (file TEXT, view TEXT, access TEXT, path TEXT, position INTEGER, pathfile TEXT, UNIQUE (file, access, view))`,
+		`CREATE TABLE contacts (user_id INTEGER PRIMARY KEY, contact_name TEXT UNIQUE, phone TEXT)`,
+	} {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := NewStorage(ctx, db, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestDiffKey(t *testing.T) {
+	key := ViewKey{File: "F100", Access: "LINKPATH"}
+	moved := view("V2", "P2", 5)
+	moved.Pathfile = "F100B"
+	for _, tc := range []struct {
+		name string
+		rows []ViewsFile
+		file []ViewsFile
+		want []ViewChange
+	}{
+		{
+			name: "same",
+			rows: []ViewsFile{view("V1", "P1", 1)},
+			file: []ViewsFile{view("V1", "P1", 1)},
+		},
+		{
+			name: "added to an empty key",
+			file: []ViewsFile{view("V2", "P2", 2), view("V1", "P1", 1)},
+			want: []ViewChange{{Kind: Added, New: view("V1", "P1", 1)}, {Kind: Added, New: view("V2", "P2", 2)}},
+		},
+		{
+			name: "removed from the file",
+			rows: []ViewsFile{view("V1", "P1", 1), view("V2", "P2", 2)},
+			want: []ViewChange{{Kind: Removed, Old: view("V1", "P1", 1)}, {Kind: Removed, Old: view("V2", "P2", 2)}},
+		},
+		{
+			name: "changed path, position or pathfile",
+			rows: []ViewsFile{view("V1", "P1", 1), view("V2", "P2", 2), view("V3", "P3", 3)},
+			file: []ViewsFile{view("V1", "PX", 1), moved, view("V3", "P3", 3)},
+			want: []ViewChange{{Kind: Changed, Old: view("V1", "P1", 1), New: view("V1", "PX", 1)}, {Kind: Changed, Old: view("V2", "P2", 2), New: moved}},
+		},
+		{
+			name: "ordered by position then view",
+			rows: []ViewsFile{view("VB", "P", 2), view("VC", "P", 1)},
+			file: []ViewsFile{view("VA", "P", 2), view("VC", "P", 1)},
+			want: []ViewChange{{Kind: Added, New: view("VA", "P", 2)}, {Kind: Removed, Old: view("VB", "P", 2)}},
+		},
+	} {
+		file := map[string]ViewsFile{}
+		for _, v := range tc.file {
+			file[v.View] = v
+		}
+		d := diffKey(key, tc.rows, file)
+		if d.Key != key || !slices.Equal(d.Changes, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, d.Changes, tc.want)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	d := KeyDiff{Key: ViewKey{File: "F100", Access: "LINKPATH"}, Changes: []ViewChange{
+		{Kind: Added, New: view("V1", "P1", 1)},
+		{Kind: Changed, Old: view("V2", "P2", 2), New: view("V2", "P'9", 3)},
+		{Kind: Removed, Old: view("V3", "P3", 4)},
+	}}
+	var b bytes.Buffer
+	if err := WriteSQL(&b, []KeyDiff{d}); err != nil {
+		t.Fatal(err)
+	}
+	want := `BEGIN;
+-- F100 LINKPATH
+INSERT INTO viewsfile (file, view, access, path, position, pathfile) VALUES ('F100', 'V1', 'LINKPATH', 'P1', 1, 'F100');
+UPDATE viewsfile SET path = 'P''9', position = 3, pathfile = 'F100' WHERE file = 'F100' AND access = 'LINKPATH' AND view = 'V2';
+DELETE FROM viewsfile WHERE file = 'F100' AND access = 'LINKPATH' AND view = 'V3';
+COMMIT;
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestStmtString(t *testing.T) {
+	for _, tc := range []struct {
+		st   Stmt
+		want string
+	}{
+		{Stmt{Query: `SELECT 1`}, `SELECT 1`},
+		{Stmt{Query: `SELECT $1, $2`, Args: []any{"it's", 42}}, `SELECT 'it''s', 42`},
+		{Stmt{Query: `SELECT $2 || $1`, Args: []any{"a", true}}, `SELECT true || 'a'`},
+		{Stmt{Query: `SELECT $10`, Args: []any{1, 2, 3, 4, 5, 6, 7, 8, 9, "ten"}}, `SELECT 'ten'`},
+		// dollars that are not placeholders are written as they are
+		{Stmt{Query: `SELECT '$' || $1 || '$x' || $$q$$`, Args: []any{"a"}}, `SELECT '$' || 'a' || '$x' || $$q$$`},
+		{Stmt{Query: `SELECT $0, $2, $`, Args: []any{"a"}}, `SELECT $0, $2, $`},
+	} {
+		if got := tc.st.String(); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.st.Query, got, tc.want)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLite(t)
+	for _, v := range []ViewsFile{view("V1", "P1", 1), view("V2", "P2", 2)} {
+		if err := s.InsertViews(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := ViewKey{File: "F100", Access: "LINKPATH"}
+	d := KeyDiff{Key: key, Changes: []ViewChange{
+		{Kind: Changed, Old: view("V1", "P1", 1), New: view("V1", "PX", 1)},
+		{Kind: Removed, Old: view("V2", "P2", 2)},
+		{Kind: Added, New: view("V3", "P3", 3)},
+	}}
+	if err := s.Reconcile(ctx, []KeyDiff{d}); err != nil {
+		t.Fatal(err)
+	}
+	want := []ViewsFile{view("V1", "PX", 1), view("V3", "P3", 3)}
+	if got, err := s.GetFileView(ctx, key.File, key.Access); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("got %+v, %v, want %+v", got, err, want)
+	}
+
+	// the table changed since the diff: V2 is already gone
+	for _, c := range []ViewChange{
+		{Kind: Removed, Old: view("V2", "P2", 2)},
+		{Kind: Changed, Old: view("V2", "P2", 2), New: view("V2", "PY", 2)},
+	} {
+		stale := KeyDiff{Key: key, Changes: []ViewChange{{Kind: Changed, Old: view("V3", "P3", 3), New: view("V3", "PZ", 3)}, c}}
+		err := s.Reconcile(ctx, []KeyDiff{stale})
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("%s V2: got %v, want ErrConflict", c.Kind, err)
+		} else if strings.Contains(err.Error(), "PY") {
+			t.Errorf("%s V2: the error holds the arguments: %v", c.Kind, err)
+		}
+		// nothing was applied
+		if got, err := s.GetFileView(ctx, key.File, key.Access); err != nil || !slices.Equal(got, want) {
+			t.Errorf("%s V2: got %+v, %v, want %+v", c.Kind, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+
+	"streamz"
+)
+
+// ViewFilter selects viewsfile rows, an empty field matches every row.
+type ViewFilter struct {
+	File   string
+	Access string
+}
+
+// Views returns an iterator over the viewsfile rows matching f, ordered by
+// file, access and position. Rows are read from the database as the
+// iteration goes, never all at once. An error is yielded once, with a zero
+// ViewsFile, and ends the iteration.
+func (s *Storage) Views(ctx context.Context, f ViewFilter) iter.Seq2[ViewsFile, error] {
+	return func(yield func(ViewsFile, error) bool) {
+		const query = `SELECT file, view, access, path, position, pathfile FROM viewsfile
+			WHERE ($1 = '' OR file = $1) AND ($2 = '' OR access = $2)
+			ORDER BY file, access, position, view`
+		// the span lasts as long as the iteration
+		ctx, q := s.startQuery(ctx, "views", query, f.File, f.Access)
+		var n int64
+		rows, err := s.conn.QueryContext(ctx, query, f.File, f.Access)
+		if err != nil {
+			yield(ViewsFile{}, q.end(n, dbError(ctx, err)))
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v ViewsFile
+			if err := rows.Scan(&v.File, &v.View, &v.Access, &v.Path, &v.Order, &v.Pathfile); err != nil {
+				yield(ViewsFile{}, q.end(n, err))
+				return
+			}
+			n++
+			if !yield(v, nil) {
+				q.end(n, nil)
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(ViewsFile{}, q.end(n, dbError(ctx, err)))
+			return
+		}
+		q.end(n, nil)
+	}
+}
+
+// ViewWriter writes views in an export format. Flush must be called after
+// the last view.
+type ViewWriter interface {
+	Write(ViewsFile) error
+	Flush() error
+}
+
+// ExportViews writes the viewsfile rows matching f to w and returns how
+// many were written.
+func (s *Storage) ExportViews(ctx context.Context, f ViewFilter, w ViewWriter) (int, error) {
+	n := 0
+	for v, err := range s.Views(ctx, f) {
+		if err != nil {
+			return n, fmt.Errorf("export views: %w", err)
+		}
+		if err := w.Write(v); err != nil {
+			return n, fmt.Errorf("export views: %s %s %s: %w", v.File, v.Access, v.View, err)
+		}
+		n++
+	}
+	return n, w.Flush()
+}
+
+// Filevuep returns v as a FILEVUEP record, File being the Table and
+// Access the Method.
+func (v ViewsFile) Filevuep() streamz.Filevuep {
+	return streamz.Filevuep{Table: v.File, View: v.View, Method: v.Access, Path: v.Path, Pos: v.Order}
+}
+
+type filevuepWriter struct {
+	bw  *bufio.Writer
+	enc *streamz.Encoder
+}
+
+// NewFilevuepWriter writes views as canonical FILEVUEP lines. The pathfile
+// column is not part of the format and is dropped.
+func NewFilevuepWriter(w io.Writer) ViewWriter {
+	bw := bufio.NewWriter(w)
+	return &filevuepWriter{bw: bw, enc: streamz.NewEncoder(bw)}
+}
+
+func (w *filevuepWriter) Write(v ViewsFile) error { return w.enc.Encode(v.Filevuep()) }
+func (w *filevuepWriter) Flush() error            { return w.bw.Flush() }
+
+type csvWriter struct {
+	cw     *csv.Writer
+	header bool
+}
+
+// NewCSVWriter writes views as CSV with a header line naming the
+// viewsfile columns.
+func NewCSVWriter(w io.Writer) ViewWriter {
+	return &csvWriter{cw: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(v ViewsFile) error {
+	if !w.header {
+		w.header = true
+		if err := w.cw.Write([]string{"file", "view", "access", "path", "position", "pathfile"}); err != nil {
+			return err
+		}
+	}
+	return w.cw.Write([]string{v.File, v.View, v.Access, v.Path, strconv.Itoa(v.Order), v.Pathfile})
+}
+
+func (w *csvWriter) Flush() error {
+	if !w.header {
+		// an empty export still names its columns
+		w.header = true
+		w.cw.Write([]string{"file", "view", "access", "path", "position", "pathfile"})
+	}
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+type jsonlWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLWriter writes views as JSON Lines, one object per view.
+func NewJSONLWriter(w io.Writer) ViewWriter {
+	bw := bufio.NewWriter(w)
+	return &jsonlWriter{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+func (w *jsonlWriter) Write(v ViewsFile) error { return w.enc.Encode(v) }
+func (w *jsonlWriter) Flush() error            { return w.bw.Flush() }
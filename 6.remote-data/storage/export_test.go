@@ -0,0 +1,56 @@
+package storage_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"streamz"
+)
+
+func TestViewWriters(t *testing.T) {
+	views := []storage.ViewsFile{
+		{File: "F100", View: "V1", Access: "LINKPATH", Path: "F100LKS0", Order: 1, Pathfile: "F100"},
+		{File: "F100", View: "SALES, EMEA", Access: "SEQ", Path: "P", Order: 12, Pathfile: `a "b"`},
+	}
+	for _, tc := range []struct {
+		name  string
+		new   func(io.Writer) storage.ViewWriter
+		views []storage.ViewsFile
+		want  string
+	}{
+		{"csv", storage.NewCSVWriter, views, "file,view,access,path,position,pathfile\n" +
+			"F100,V1,LINKPATH,F100LKS0,1,F100\n" +
+			`F100,"SALES, EMEA",SEQ,P,12,"a ""b"""` + "\n"},
+		{"csv empty", storage.NewCSVWriter, nil, "file,view,access,path,position,pathfile\n"},
+		{"jsonl", storage.NewJSONLWriter, views,
+			`{"file":"F100","view":"V1","access":"LINKPATH","path":"F100LKS0","position":1,"pathfile":"F100"}` + "\n" +
+				`{"file":"F100","view":"SALES, EMEA","access":"SEQ","path":"P","position":12,"pathfile":"a \"b\""}` + "\n"},
+		{"jsonl empty", storage.NewJSONLWriter, nil, ""},
+		{"filevuep", storage.NewFilevuepWriter, views[:1],
+			"F100     V1                               LINKPATH F100LKS0     1\n"},
+		{"filevuep empty", storage.NewFilevuepWriter, nil, ""},
+	} {
+		var b bytes.Buffer
+		w := tc.new(&b)
+		for _, v := range tc.views {
+			if err := w.Write(v); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if b.String() != tc.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", tc.name, b.String(), tc.want)
+		}
+	}
+
+	// FILEVUEP has no quoting for a view with spaces
+	err := storage.NewFilevuepWriter(io.Discard).Write(views[1])
+	if !errors.Is(err, streamz.ErrInvalidField) {
+		t.Errorf("view with spaces: got %v, want ErrInvalidField", err)
+	}
+}
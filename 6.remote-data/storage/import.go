@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"streamz"
+)
+
+var (
+	// ErrDuplicate rejects a view appearing again later in the same import;
+	// the last occurrence is the one kept.
+	ErrDuplicate = errors.New("view appears again later in the input")
+	// ErrExists rejects a view already in viewsfile when not upserting.
+	ErrExists = errors.New("view already in viewsfile")
+)
+
+// ImportOptions tunes ImportViews. The zero value inserts new views and
+// rejects the ones already in the table.
+type ImportOptions struct {
+	// Upsert updates the path, position and pathfile of the views already
+	// in the table instead of rejecting them.
+	Upsert bool
+	// DryRun does the whole import and rolls it back, the summary tells
+	// what would have changed.
+	DryRun bool
+	// PathFile gives the pathfile column of a record, its Table when nil.
+	PathFile func(streamz.Filevuep) string
+	// Reject, if not nil, is called with every rejected row and why.
+	Reject func(ViewsFile, error)
+}
+
+// ImportSummary counts what an import did to viewsfile.
+type ImportSummary struct {
+	Read      int64
+	Inserted  int64
+	Updated   int64
+	Unchanged int64
+	Rejected  int64
+}
+
+// importTable is the staging table the records are copied into. It is
+// dropped at the end of the import.
+const importTable = "viewsfile_import"
+
+// keyMatch joins the staging table t with viewsfile v on the view key.
+const keyMatch = `t.file = v.file AND t.access = v.access AND t.view = v.view`
+
+// ImportViews loads the records of a FILEVUEP decoder into viewsfile in a
+// single transaction, or a savepoint when called on a Tx, so a failed
+// import changes nothing. Records are
+// streamed with COPY into a staging table, then merged with set based
+// statements. Views are keyed by (file, access, view): a Filevuep Table
+// is the file and its Method the access.
+//
+// A Result with Err set aborts the import, use a lenient decoder to skip
+// bad rows. ImportViews drains results before returning; cancel the
+// decoder's context to stop it early on error.
+func (s *Storage) ImportViews(ctx context.Context, results <-chan streamz.Result, opts ImportOptions) (ImportSummary, error) {
+	defer func() {
+		for range results {
+		}
+	}()
+	var sum ImportSummary
+	// no retry: the records are consumed by the first attempt
+	err := s.WithTx(ctx, func(tx *Tx) error {
+		return tx.sqlConn.Raw(func(driverConn any) error {
+			pc, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return fmt.Errorf("import needs the pgx driver, not %T", driverConn)
+			}
+			var err error
+			if sum, err = importTx(ctx, pc.Conn(), results, opts); err != nil {
+				return err
+			}
+			if opts.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+	}, TxRetries(0))
+	if err != nil && !errors.Is(err, errDryRun) {
+		return ImportSummary{}, fmt.Errorf("import views: %w", dbError(ctx, err))
+	}
+	return sum, nil
+}
+
+// errDryRun rolls back a dry run import.
+var errDryRun = errors.New("dry run")
+
+// pgxConn is the part of *pgx.Conn used by importTx, which runs in the
+// database/sql transaction of the connection.
+type pgxConn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error)
+}
+
+func importTx(ctx context.Context, tx pgxConn, results <-chan streamz.Result, opts ImportOptions) (ImportSummary, error) {
+	var sum ImportSummary
+	_, err := tx.Exec(ctx, `CREATE TEMP TABLE `+importTable+` (
+		seq bigint, file text, view text, access text, path text, position int, pathfile text
+	)`)
+	if err != nil {
+		return sum, err
+	}
+
+	src := &copySource{results: results, pathFile: opts.PathFile}
+	sum.Read, err = tx.CopyFrom(ctx, pgx.Identifier{importTable},
+		[]string{"seq", "file", "view", "access", "path", "position", "pathfile"}, src)
+	if err != nil {
+		return sum, err
+	}
+	if _, err := tx.Exec(ctx, `CREATE INDEX ON `+importTable+` (file, access, view)`); err != nil {
+		return sum, err
+	}
+
+	reject := func(sql string, why error) error {
+		rows, err := tx.Query(ctx, sql)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v ViewsFile
+			if err := rows.Scan(&v.File, &v.View, &v.Access, &v.Path, &v.Order, &v.Pathfile); err != nil {
+				return err
+			}
+			sum.Rejected++
+			if opts.Reject != nil {
+				opts.Reject(v, why)
+			}
+		}
+		return rows.Err()
+	}
+	const returning = ` RETURNING t.file, t.view, t.access, t.path, t.position, t.pathfile`
+
+	// keep the last occurrence of every key
+	err = reject(`DELETE FROM `+importTable+` t USING `+importTable+` d
+		WHERE t.file = d.file AND t.access = d.access AND t.view = d.view AND t.seq < d.seq`+returning, ErrDuplicate)
+	if err != nil {
+		return sum, err
+	}
+
+	if opts.Upsert {
+		err = tx.QueryRow(ctx, `SELECT count(*) FROM `+importTable+` t
+			WHERE EXISTS (SELECT 1 FROM viewsfile v WHERE `+keyMatch+`)`).Scan(&sum.Unchanged)
+		if err != nil {
+			return sum, err
+		}
+		tag, err := tx.Exec(ctx, `UPDATE viewsfile v SET path = t.path, position = t.position, pathfile = t.pathfile
+			FROM `+importTable+` t WHERE `+keyMatch+`
+			AND (v.path, v.position, v.pathfile) IS DISTINCT FROM (t.path, t.position, t.pathfile)`)
+		if err != nil {
+			return sum, err
+		}
+		sum.Updated = tag.RowsAffected()
+		sum.Unchanged = max(sum.Unchanged-sum.Updated, 0)
+		if _, err := tx.Exec(ctx, `DELETE FROM `+importTable+` t USING viewsfile v WHERE `+keyMatch); err != nil {
+			return sum, err
+		}
+	} else {
+		err = reject(`DELETE FROM `+importTable+` t USING viewsfile v WHERE `+keyMatch+returning, ErrExists)
+		if err != nil {
+			return sum, err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `INSERT INTO viewsfile (file, view, access, path, position, pathfile)
+		SELECT file, view, access, path, position, pathfile FROM `+importTable+` ORDER BY seq`)
+	if err != nil {
+		return sum, err
+	}
+	sum.Inserted = tag.RowsAffected()
+	// dropped now rather than on commit, in case the import is one step of
+	// a larger transaction
+	_, err = tx.Exec(ctx, `DROP TABLE `+importTable)
+	return sum, err
+}
+
+// copySource feeds the decoded records to COPY.
+type copySource struct {
+	results  <-chan streamz.Result
+	pathFile func(streamz.Filevuep) string
+	cur      streamz.Filevuep
+	seq      int64
+	err      error
+}
+
+func (c *copySource) Next() bool {
+	r, ok := <-c.results
+	if !ok {
+		return false
+	}
+	if r.Err != nil {
+		c.err = r.Err
+		return false
+	}
+	c.cur = r.Filevuep
+	c.seq++
+	return true
+}
+
+func (c *copySource) Values() ([]any, error) {
+	v := c.cur
+	pathfile := v.Table
+	if c.pathFile != nil {
+		pathfile = c.pathFile(v)
+	}
+	return []any{c.seq, v.Table, v.View, v.Method, v.Path, v.Pos, pathfile}, nil
+}
+
+func (c *copySource) Err() error {
+	return c.err
+}
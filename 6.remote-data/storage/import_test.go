@@ -0,0 +1,115 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"streamz"
+)
+
+// feed returns the results of a decoder reading vs.
+func feed(vs ...streamz.Filevuep) <-chan streamz.Result {
+	ch := make(chan streamz.Result, len(vs))
+	for _, v := range vs {
+		ch <- streamz.Result{Filevuep: v}
+	}
+	close(ch)
+	return ch
+}
+
+func TestPostgresImportViews(t *testing.T) {
+	s := newPostgres(t)
+	ctx := context.Background()
+	rec := func(view, path string, pos int) streamz.Filevuep {
+		return streamz.Filevuep{Table: "F100", View: view, Method: "LINKPATH", Path: path, Pos: pos}
+	}
+	row := func(view, path string, pos int) storage.ViewsFile {
+		return storage.ViewsFile{File: "F100", View: view, Access: "LINKPATH", Path: path, Order: pos, Pathfile: "F100"}
+	}
+	var rejected []storage.ViewsFile
+	var why []error
+	run := func(opts storage.ImportOptions, vs ...streamz.Filevuep) storage.ImportSummary {
+		t.Helper()
+		rejected, why = nil, nil
+		opts.Reject = func(v storage.ViewsFile, err error) {
+			rejected = append(rejected, v)
+			why = append(why, err)
+		}
+		sum, err := s.ImportViews(ctx, feed(vs...), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	table := func() []storage.ViewsFile {
+		t.Helper()
+		got, err := s.GetFileView(ctx, "F100", "LINKPATH")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// V1 appears twice in the file, the last one is kept
+	sum := run(storage.ImportOptions{}, rec("V1", "P0", 1), rec("V2", "P2", 2), rec("V1", "P1", 1))
+	if want := (storage.ImportSummary{Read: 3, Inserted: 2, Rejected: 1}); sum != want {
+		t.Errorf("insert: got %+v, want %+v", sum, want)
+	}
+	if len(rejected) != 1 || rejected[0] != row("V1", "P0", 1) || !errors.Is(why[0], storage.ErrDuplicate) {
+		t.Errorf("insert: rejected %+v for %v, want the first V1 for ErrDuplicate", rejected, why)
+	}
+	if got, want := table(), []storage.ViewsFile{row("V1", "P1", 1), row("V2", "P2", 2)}; !slices.Equal(got, want) {
+		t.Errorf("insert: table holds %+v, want %+v", got, want)
+	}
+
+	// without Upsert a view already in the table is rejected
+	sum = run(storage.ImportOptions{}, rec("V2", "P9", 2), rec("V3", "P3", 3))
+	if want := (storage.ImportSummary{Read: 2, Inserted: 1, Rejected: 1}); sum != want {
+		t.Errorf("conflict: got %+v, want %+v", sum, want)
+	}
+	if len(rejected) != 1 || rejected[0] != row("V2", "P9", 2) || !errors.Is(why[0], storage.ErrExists) {
+		t.Errorf("conflict: rejected %+v for %v, want V2 for ErrExists", rejected, why)
+	}
+	want := []storage.ViewsFile{row("V1", "P1", 1), row("V2", "P2", 2), row("V3", "P3", 3)}
+	if got := table(); !slices.Equal(got, want) {
+		t.Errorf("conflict: table holds %+v, want %+v", got, want)
+	}
+
+	// with Upsert it is updated, or counted unchanged when it is the same
+	sum = run(storage.ImportOptions{Upsert: true}, rec("V2", "P9", 2), rec("V3", "P3", 3), rec("V4", "P4", 4))
+	if want := (storage.ImportSummary{Read: 3, Inserted: 1, Updated: 1, Unchanged: 1}); sum != want {
+		t.Errorf("upsert: got %+v, want %+v", sum, want)
+	}
+	if len(rejected) != 0 {
+		t.Errorf("upsert: rejected %+v", rejected)
+	}
+	want = []storage.ViewsFile{row("V1", "P1", 1), row("V2", "P9", 2), row("V3", "P3", 3), row("V4", "P4", 4)}
+	if got := table(); !slices.Equal(got, want) {
+		t.Errorf("upsert: table holds %+v, want %+v", got, want)
+	}
+
+	// a dry run counts the same and leaves the table as it was
+	sum = run(storage.ImportOptions{Upsert: true, DryRun: true}, rec("V1", "PX", 1), rec("V5", "P5", 5), rec("V5", "P5", 5))
+	if want := (storage.ImportSummary{Read: 3, Inserted: 1, Updated: 1, Rejected: 1}); sum != want {
+		t.Errorf("dry run: got %+v, want %+v", sum, want)
+	}
+	if got := table(); !slices.Equal(got, want) {
+		t.Errorf("dry run: table holds %+v, want %+v", got, want)
+	}
+
+	// a decoding error aborts the whole import
+	boom := errors.New("line 2: bad row")
+	ch := make(chan streamz.Result, 2)
+	ch <- streamz.Result{Filevuep: rec("V6", "P6", 6)}
+	ch <- streamz.Result{Err: boom}
+	close(ch)
+	if _, err := s.ImportViews(ctx, ch, storage.ImportOptions{}); err == nil {
+		t.Error("decoding error: no error")
+	}
+	if got := table(); !slices.Equal(got, want) {
+		t.Errorf("decoding error: table holds %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Memory keeps views and contacts in memory, for tests and dry runs. The
+// zero Memory is empty and ready to use; it is safe for concurrent use.
+type Memory struct {
+	mu       sync.Mutex
+	views    map[ViewKey][]ViewsFile
+	contacts map[int]ContactRec
+	lastID   int
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// GetFileView implements ViewsRepository.
+func (m *Memory) GetFileView(ctx context.Context, file, access string) ([]ViewsFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("get views of %s %s: %w", file, access, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	views := slices.Clone(m.views[ViewKey{File: file, Access: access}])
+	if len(views) == 0 {
+		return nil, fmt.Errorf("views of %s %s: %w", file, access, ErrNotFound)
+	}
+	slices.SortFunc(views, func(a, b ViewsFile) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), cmp.Compare(a.View, b.View))
+	})
+	return views, nil
+}
+
+// InsertViews implements ViewsRepository.
+func (m *Memory) InsertViews(ctx context.Context, v ViewsFile) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("insert view %s %s %s: %w", v.File, v.Access, v.View, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := ViewKey{File: v.File, Access: v.Access}
+	if slices.ContainsFunc(m.views[key], func(old ViewsFile) bool { return old.View == v.View }) {
+		return fmt.Errorf("insert view %s %s %s: %w", v.File, v.Access, v.View, ErrConflict)
+	}
+	if m.views == nil {
+		m.views = map[ViewKey][]ViewsFile{}
+	}
+	m.views[key] = append(m.views[key], v)
+	return nil
+}
+
+// GetContact implements ContactsRepository.
+func (m *Memory) GetContact(ctx context.Context, id int) (ContactRec, error) {
+	if err := ctx.Err(); err != nil {
+		return ContactRec{}, fmt.Errorf("contact %d: %w", id, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.contacts[id]
+	if !ok {
+		return ContactRec{}, fmt.Errorf("contact %d: %w", id, ErrNotFound)
+	}
+	return c, nil
+}
+
+// InsertContact implements ContactsRepository.
+func (m *Memory) InsertContact(ctx context.Context, c ContactRec) (ContactRec, error) {
+	if err := ctx.Err(); err != nil {
+		return ContactRec{}, fmt.Errorf("insert contact: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.contacts == nil {
+		m.contacts = map[int]ContactRec{}
+	}
+	m.lastID++
+	c.ID = m.lastID
+	m.contacts[c.ID] = c
+	return c, nil
+}
+
+// UpdateView implements ViewsRepository.
+func (m *Memory) UpdateView(ctx context.Context, v ViewsFile) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("update view %s %s %s: %w", v.File, v.Access, v.View, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	views := m.views[ViewKey{File: v.File, Access: v.Access}]
+	i := slices.IndexFunc(views, func(old ViewsFile) bool { return old.View == v.View })
+	if i < 0 {
+		return fmt.Errorf("update view %s %s %s: %w", v.File, v.Access, v.View, ErrNotFound)
+	}
+	views[i] = v
+	return nil
+}
+
+// DeleteView implements ViewsRepository.
+func (m *Memory) DeleteView(ctx context.Context, file, access, view string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("delete view %s %s %s: %w", file, access, view, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := ViewKey{File: file, Access: access}
+	i := slices.IndexFunc(m.views[key], func(old ViewsFile) bool { return old.View == view })
+	if i < 0 {
+		return fmt.Errorf("delete view %s %s %s: %w", file, access, view, ErrNotFound)
+	}
+	m.views[key] = slices.Delete(m.views[key], i, i+1)
+	return nil
+}
+
+// ListViews implements ViewsRepository.
+func (m *Memory) ListViews(ctx context.Context, f ViewFilter, p Page) ([]ViewsFile, error) {
+	views, err := m.find(ctx, "list views", func(v ViewsFile) bool {
+		return (f.File == "" || v.File == f.File) && (f.Access == "" || v.Access == f.Access)
+	})
+	if err != nil {
+		return nil, err
+	}
+	offset, limit := p.bounds()
+	offset = min(offset, len(views))
+	return views[offset:min(offset+limit, len(views))], nil
+}
+
+// ViewsByName implements ViewsRepository.
+func (m *Memory) ViewsByName(ctx context.Context, view string) ([]ViewsFile, error) {
+	views, err := m.find(ctx, "views named "+view, func(v ViewsFile) bool { return v.View == view })
+	if err == nil && len(views) == 0 {
+		err = fmt.Errorf("views named %s: %w", view, ErrNotFound)
+	}
+	return views, err
+}
+
+// ViewsByPath implements ViewsRepository.
+func (m *Memory) ViewsByPath(ctx context.Context, path string) ([]ViewsFile, error) {
+	views, err := m.find(ctx, "views of path "+path, func(v ViewsFile) bool { return v.Path == path })
+	if err == nil && len(views) == 0 {
+		err = fmt.Errorf("views of path %s: %w", path, ErrNotFound)
+	}
+	return views, err
+}
+
+// find returns the views matching keep ordered by file, access and
+// position.
+func (m *Memory) find(ctx context.Context, what string, keep func(ViewsFile) bool) ([]ViewsFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", what, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	views := []ViewsFile{}
+	for _, vs := range m.views {
+		for _, v := range vs {
+			if keep(v) {
+				views = append(views, v)
+			}
+		}
+	}
+	slices.SortFunc(views, func(a, b ViewsFile) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Access, b.Access),
+			cmp.Compare(a.Order, b.Order), cmp.Compare(a.View, b.View))
+	})
+	return views, nil
+}
+
+// MoveView implements ViewsRepository.
+func (m *Memory) MoveView(ctx context.Context, key ViewKey, view string, to int) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("move view %s: %w", view, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	views := slices.Clone(m.views[key])
+	slices.SortFunc(views, func(a, b ViewsFile) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), cmp.Compare(a.View, b.View))
+	})
+	moved, err := Reorder(views, view, to)
+	if err != nil {
+		return fmt.Errorf("move view of %s %s: %w", key.File, key.Access, err)
+	}
+	m.views[key] = moved
+	return nil
+}
+
+// UpdateContact implements ContactsRepository.
+func (m *Memory) UpdateContact(ctx context.Context, c ContactRec) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("update contact %d: %w", c.ID, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.contacts[c.ID]; !ok {
+		return fmt.Errorf("update contact %d: %w", c.ID, ErrNotFound)
+	}
+	m.contacts[c.ID] = c
+	return nil
+}
+
+// DeleteContact implements ContactsRepository.
+func (m *Memory) DeleteContact(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("delete contact %d: %w", id, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.contacts[id]; !ok {
+		return fmt.Errorf("delete contact %d: %w", id, ErrNotFound)
+	}
+	delete(m.contacts, id)
+	return nil
+}
+
+// SearchContacts implements ContactsRepository.
+func (m *Memory) SearchContacts(ctx context.Context, f ContactFilter, p Page) ([]ContactRec, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("search contacts: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := strings.ToLower(f.Name)
+	contacts := []ContactRec{}
+	for _, id := range slices.Sorted(maps.Keys(m.contacts)) {
+		c := m.contacts[id]
+		if strings.Contains(strings.ToLower(c.Name), name) && strings.HasPrefix(c.Phone, f.PhonePrefix) {
+			contacts = append(contacts, c)
+		}
+	}
+	offset, limit := p.bounds()
+	offset = min(offset, len(contacts))
+	return contacts[offset:min(offset+limit, len(contacts))], nil
+}
@@ -0,0 +1,16 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"github.com/MoadHar/go_ops/6.remote-data/storage/storagetest"
+)
+
+func TestMemoryViews(t *testing.T) {
+	storagetest.TestViews(t, func(t *testing.T) storage.ViewsRepository { return storage.NewMemory() })
+}
+
+func TestMemoryContacts(t *testing.T) {
+	storagetest.TestContacts(t, func(t *testing.T) storage.ContactsRepository { return storage.NewMemory() })
+}
@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFS holds the schema migrations, NNNN_name.up.sql and
+// NNNN_name.down.sql for every version NNNN.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// ErrChecksum is returned when an applied migration is not the one
+// embedded in this binary any more.
+var ErrChecksum = errors.New("applied migration does not match its embedded SQL")
+
+// migrationLock is the advisory lock key held while migrating, so two
+// migrations never run at once.
+const migrationLock = 0x766965777366 // "viewsf"
+
+// Migration is one version of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the hex SHA-256 of Up.
+	Checksum string
+}
+
+// AppliedMigration is a row of the schema_migrations table.
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	return migrationsOf(migrationFS)
+}
+
+// migrationsOf returns the migrations of the migrations directory of
+// fsys ordered by version.
+func migrationsOf(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		base := path.Base(file)
+		stem, dir, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		num, name, ok2 := strings.Cut(stem, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || !ok2 || err != nil || version <= 0 || (dir != "up" && dir != "down") {
+			return nil, fmt.Errorf("migration %s: want NNNN_name.up.sql or NNNN_name.down.sql", base)
+		}
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name || (dir == "up" && m.Up != "") || (dir == "down" && m.Down != "") {
+			return nil, fmt.Errorf("migration %s: version %d is used twice", base, version)
+		}
+		if dir == "up" {
+			m.Up = string(b)
+			sum := sha256.Sum256(b)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	var ms []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s: needs both an up and a down file", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+	slices.SortFunc(ms, func(a, b Migration) int { return a.Version - b.Version })
+	return ms, nil
+}
+
+// migrator runs migrations on a single connection holding the lock.
+type migrator struct {
+	conn *sql.Conn
+	all  []Migration
+}
+
+// withMigrator locks the schema, makes sure schema_migrations exists and
+// checks the applied migrations against the embedded ones before calling
+// fn.
+func withMigrator(ctx context.Context, db *sql.DB, fn func(*migrator, []AppliedMigration) error) error {
+	all, err := Migrations()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	// the lock goes with the connection if the unlock cannot be sent
+	defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLock)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer     PRIMARY KEY,
+		name       text        NOT NULL,
+		checksum   text        NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+	m := &migrator{conn: conn, all: all}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(applied); err != nil {
+		return err
+	}
+	return fn(m, applied)
+}
+
+func (m *migrator) applied(ctx context.Context) ([]AppliedMigration, error) {
+	rows, err := m.conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// verify checks that every applied migration is embedded unchanged.
+func (m *migrator) verify(applied []AppliedMigration) error {
+	for _, a := range applied {
+		i := slices.IndexFunc(m.all, func(e Migration) bool { return e.Version == a.Version })
+		if i < 0 {
+			return fmt.Errorf("migration %04d_%s is applied but unknown to this binary", a.Version, a.Name)
+		}
+		if m.all[i].Checksum != a.Checksum {
+			return fmt.Errorf("migration %04d_%s: %w", a.Version, a.Name, ErrChecksum)
+		}
+	}
+	return nil
+}
+
+// pending returns the migrations not in applied, in version order. One
+// below the newest applied version is pending too, as when two branches
+// each added a migration.
+func (m *migrator) pending(applied []AppliedMigration) []Migration {
+	var pending []Migration
+	for _, mig := range m.all {
+		if !slices.ContainsFunc(applied, func(a AppliedMigration) bool { return a.Version == mig.Version }) {
+			pending = append(pending, mig)
+		}
+	}
+	return pending
+}
+
+// run applies or reverts mig and records the new version in one
+// transaction.
+func (m *migrator) run(ctx context.Context, mig Migration, up bool) error {
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query, dir := mig.Down, "down"
+	if up {
+		query, dir = mig.Up, "up"
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("migration %04d_%s %s: %w", mig.Version, mig.Name, dir, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			mig.Version, mig.Name, mig.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateUp applies the migrations not applied yet up to and including
+// target, all of them when target is 0, in version order. Each migration
+// runs in its own transaction. It returns the migrations applied.
+func MigrateUp(ctx context.Context, db *sql.DB, target int) ([]Migration, error) {
+	var done []Migration
+	err := withMigrator(ctx, db, func(m *migrator, applied []AppliedMigration) error {
+		for _, mig := range m.pending(applied) {
+			if target > 0 && mig.Version > target {
+				break
+			}
+			if err := m.run(ctx, mig, true); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts the applied migrations above target, newest first,
+// and returns them. A target of 0 reverts everything.
+func MigrateDown(ctx context.Context, db *sql.DB, target int) ([]Migration, error) {
+	var done []Migration
+	err := withMigrator(ctx, db, func(m *migrator, applied []AppliedMigration) error {
+		for _, a := range slices.Backward(applied) {
+			if a.Version <= target {
+				break
+			}
+			i := slices.IndexFunc(m.all, func(e Migration) bool { return e.Version == a.Version })
+			if err := m.run(ctx, m.all[i], false); err != nil {
+				return err
+			}
+			done = append(done, m.all[i])
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatus returns the applied migrations, checked against the
+// embedded ones, and the embedded migrations not applied yet.
+func MigrationStatus(ctx context.Context, db *sql.DB) (applied []AppliedMigration, pending []Migration, err error) {
+	err = withMigrator(ctx, db, func(m *migrator, a []AppliedMigration) error {
+		applied, pending = a, m.pending(a)
+		return nil
+	})
+	return applied, pending, err
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// migrationFiles returns a file system holding the migration files named.
+func migrationFiles(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte("-- " + name + "\n")}
+	}
+	return fsys
+}
+
+func TestMigrationFiles(t *testing.T) {
+	ms, err := migrationsOf(migrationFiles("0002_b.up.sql", "0002_b.down.sql", "0010_c.down.sql", "0010_c.up.sql", "0001_a.up.sql", "0001_a.down.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range ms {
+		got = append(got, fmt.Sprintf("%d %s", m.Version, m.Name))
+	}
+	if want := []string{"1 a", "2 b", "10 c"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if ms[0].Up != "-- 0001_a.up.sql\n" || ms[0].Down != "-- 0001_a.down.sql\n" {
+		t.Errorf("0001_a: got up %q and down %q", ms[0].Up, ms[0].Down)
+	}
+	if sum := sha256.Sum256([]byte(ms[0].Up)); ms[0].Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("0001_a: checksum %s", ms[0].Checksum)
+	}
+
+	for _, tc := range []struct {
+		files []string
+		want  string
+	}{
+		{[]string{"0001_a.sql"}, "want NNNN_name.up.sql"},
+		{[]string{"0001.up.sql"}, "want NNNN_name.up.sql"},
+		{[]string{"one_a.up.sql"}, "want NNNN_name.up.sql"},
+		{[]string{"0000_a.up.sql"}, "want NNNN_name.up.sql"},
+		{[]string{"0001_a.sideways.sql"}, "want NNNN_name.up.sql"},
+		{[]string{"0001_a.up.sql"}, "needs both an up and a down file"},
+		{[]string{"0001_a.up.sql", "0001_a.down.sql", "0001_b.up.sql", "0001_b.down.sql"}, "version 1 is used twice"},
+		{[]string{"0001_a.up.sql", "0001_a.down.sql", "1_a.up.sql"}, "version 1 is used twice"},
+	} {
+		_, err := migrationsOf(migrationFiles(tc.files...))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: got %v, want %s", tc.files, err, tc.want)
+		}
+	}
+}
+
+func TestVerifyMigrations(t *testing.T) {
+	all, err := migrationsOf(migrationFiles("0001_a.up.sql", "0001_a.down.sql", "0002_b.up.sql", "0002_b.down.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &migrator{all: all}
+	applied := func(version int, name, checksum string) []AppliedMigration {
+		return []AppliedMigration{{Version: 1, Name: "a", Checksum: all[0].Checksum}, {Version: version, Name: name, Checksum: checksum}}
+	}
+	if err := m.verify(applied(2, "b", all[1].Checksum)); err != nil {
+		t.Errorf("unchanged: %v", err)
+	}
+	if err := m.verify(applied(2, "b", all[0].Checksum)); !errors.Is(err, ErrChecksum) || !strings.HasPrefix(err.Error(), "migration 0002_b:") {
+		t.Errorf("changed checksum: got %v, want ErrChecksum", err)
+	}
+	if err := m.verify(applied(3, "c", "x")); err == nil || !strings.Contains(err.Error(), "0003_c is applied but unknown") {
+		t.Errorf("unknown version: got %v", err)
+	}
+
+	// the embedded migrations parse
+	if ms, err := Migrations(); err != nil || len(ms) == 0 {
+		t.Errorf("embedded migrations: %d, %v", len(ms), err)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	m := &migrator{}
+	for v := range 4 {
+		m.all = append(m.all, Migration{Version: v + 1})
+	}
+	for _, tc := range []struct {
+		applied []int
+		want    []int
+	}{
+		{nil, []int{1, 2, 3, 4}},
+		{[]int{1, 2}, []int{3, 4}},
+		// a gap below the newest applied version is still pending
+		{[]int{1, 3}, []int{2, 4}},
+		{[]int{2, 3, 4}, []int{1}},
+		{[]int{1, 2, 3, 4}, nil},
+	} {
+		var applied []AppliedMigration
+		for _, v := range tc.applied {
+			applied = append(applied, AppliedMigration{Version: v})
+		}
+		var got []int
+		for _, mig := range m.pending(applied) {
+			got = append(got, mig.Version)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("applied %v: got %v pending, want %v", tc.applied, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+-- views of the mainframe files, loaded from the FILEVUEP extracts
+CREATE TABLE viewsfile (
+    id       bigint  GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    file     text    NOT NULL,
+    view     text    NOT NULL,
+    access   text    NOT NULL,
+    path     text    NOT NULL,
+    position integer NOT NULL,
+    pathfile text    NOT NULL,
+    CONSTRAINT viewsfile_file_access_view_key UNIQUE (file, access, view)
+);
@@ -0,0 +1,5 @@
+CREATE TABLE contacts (
+    user_id      integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    contact_name text    NOT NULL,
+    phone        text    NOT NULL
+);
@@ -0,0 +1,197 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"github.com/MoadHar/go_ops/6.remote-data/storage/storagetest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newPostgres returns a Storage with opts on the emptied database of
+// GOOPS_TEST_DB, the tests are skipped without it.
+func newPostgres(t *testing.T, opts ...storage.Option) *storage.Storage {
+	dsn := os.Getenv("GOOPS_TEST_DB")
+	if dsn == "" {
+		t.Skip("GOOPS_TEST_DB is not set")
+	}
+	ctx := context.Background()
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := storage.MigrateUp(ctx, db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `TRUNCATE viewsfile, contacts RESTART IDENTITY`); err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.NewStorage(ctx, db, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPostgresViews(t *testing.T) {
+	storagetest.TestViews(t, func(t *testing.T) storage.ViewsRepository { return newPostgres(t) })
+}
+
+func TestPostgresContacts(t *testing.T) {
+	storagetest.TestContacts(t, func(t *testing.T) storage.ContactsRepository { return newPostgres(t) })
+}
+
+func TestPostgresMigrationGap(t *testing.T) {
+	newPostgres(t)
+	ctx := context.Background()
+	db, err := sql.Open("pgx", os.Getenv("GOOPS_TEST_DB"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	all, err := storage.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unapply the first migration only, as if it came from a branch merged
+	// after the second one was applied
+	first := all[0]
+	if _, err := db.ExecContext(ctx, first.Down); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, first.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	_, pending, err := storage.MigrationStatus(ctx, db)
+	if err != nil || len(pending) != 1 || pending[0].Version != first.Version {
+		t.Fatalf("status: pending %v, %v, want version %d", pending, err, first.Version)
+	}
+	done, err := storage.MigrateUp(ctx, db, 0)
+	if err != nil || len(done) != 1 || done[0].Version != first.Version {
+		t.Fatalf("up: applied %v, %v, want version %d", done, err, first.Version)
+	}
+	if _, pending, err := storage.MigrationStatus(ctx, db); err != nil || len(pending) != 0 {
+		t.Errorf("after up: pending %v, %v, want none", pending, err)
+	}
+}
+
+func TestPostgresTx(t *testing.T) {
+	s := newPostgres(t)
+	ctx := context.Background()
+	view := func(name string, pos int) storage.ViewsFile {
+		return storage.ViewsFile{File: "F100", View: name, Access: "LINKPATH", Path: "F100LKS0", Order: pos, Pathfile: "F100"}
+	}
+	boom := errors.New("boom")
+
+	err := s.WithTx(ctx, func(tx *storage.Tx) error {
+		if err := tx.InsertViews(ctx, view("V0", 1)); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithTx returned %v, want the error of fn", err)
+	}
+	if _, err := s.GetFileView(ctx, "F100", "LINKPATH"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("a rolled back insert is visible: %v", err)
+	}
+
+	err = s.WithTx(ctx, func(tx *storage.Tx) error {
+		if err := tx.InsertViews(ctx, view("V1", 1)); err != nil {
+			return err
+		}
+		err := tx.WithTx(ctx, func(tx *storage.Tx) error {
+			if err := tx.InsertViews(ctx, view("V2", 2)); err != nil {
+				return err
+			}
+			return tx.InsertViews(ctx, view("V1", 3))
+		})
+		if !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("savepoint returned %v, want ErrConflict", err)
+		}
+		if err := tx.InsertViews(ctx, view("V3", 2)); err != nil {
+			return err
+		}
+		return tx.MoveView(ctx, storage.ViewKey{File: "F100", Access: "LINKPATH"}, "V3", 1)
+	}, storage.Isolation(sql.LevelSerializable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetFileView(ctx, "F100", "LINKPATH")
+	if want := []storage.ViewsFile{view("V3", 1), view("V1", 2)}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("after the transaction got %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestPostgresTrace(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	var logged []string
+	logf := func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+	// every query is slow
+	s := newPostgres(t, storage.Traced(tp), storage.SlowQueries(time.Nanosecond, logf))
+	ctx := context.Background()
+
+	c, err := s.InsertContact(ctx, storage.ContactRec{Name: "Ada", Phone: "+33612345678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetContact(ctx, c.ID+1); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetContact of a missing contact: %v", err)
+	}
+	v := storage.ViewsFile{File: "F100", View: "V1", Access: "LINKPATH", Path: "F100LKS0", Order: 1, Pathfile: "F100"}
+	if err := s.InsertViews(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertViews(ctx, v); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("InsertViews of a view already there: %v", err)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("%d spans, want 4", len(spans))
+	}
+	rows := func(i int) int64 {
+		for _, kv := range spans[i].Attributes {
+			if kv.Key == attribute.Key("db.response.returned_rows") {
+				return kv.Value.AsInt64()
+			}
+		}
+		return -1
+	}
+	if spans[0].Name != "insert contact" || rows(0) != 1 || spans[0].Status.Code == codes.Error {
+		t.Errorf("insert span %s, %d rows, status %v", spans[0].Name, rows(0), spans[0].Status)
+	}
+	if spans[1].Name != "get contact" || rows(1) != 0 || spans[1].Status.Code == codes.Error {
+		t.Errorf("missing contact span %s, %d rows, status %v, want no error", spans[1].Name, rows(1), spans[1].Status)
+	}
+	if spans[3].Name != "insert view" || spans[3].Status.Code != codes.Error {
+		t.Errorf("conflicting insert span %s, status %v, want an error", spans[3].Name, spans[3].Status)
+	}
+
+	if len(logged) != 4 {
+		t.Fatalf("logged %q, want 4 slow queries", logged)
+	}
+	for _, l := range logged {
+		if strings.Contains(l, "Ada") || strings.Contains(l, "+3361") {
+			t.Errorf("logged the arguments: %s", l)
+		}
+	}
+	if !strings.Contains(logged[1], "$1=") {
+		t.Errorf("logged %s, want the id", logged[1])
+	}
+}
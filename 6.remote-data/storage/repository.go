@@ -0,0 +1,44 @@
+package storage
+
+import "context"
+
+// ViewsRepository stores the views of the mainframe files. Storage is the
+// Postgres implementation, Memory an in-memory one for tests and the
+// sqlite package a SQLite one.
+//
+// A view is identified by its file, access and view. GetFileView,
+// ViewsByName and ViewsByPath return ErrNotFound when nothing matches, as
+// do UpdateView, DeleteView and MoveView for an unknown view. InsertViews
+// returns ErrConflict for a view already stored. Lists are ordered by
+// file, access and position. Errors of a done context wrap the context
+// error.
+type ViewsRepository interface {
+	GetFileView(ctx context.Context, file, access string) ([]ViewsFile, error)
+	InsertViews(ctx context.Context, v ViewsFile) error
+	UpdateView(ctx context.Context, v ViewsFile) error
+	DeleteView(ctx context.Context, file, access, view string) error
+	ListViews(ctx context.Context, f ViewFilter, p Page) ([]ViewsFile, error)
+	ViewsByName(ctx context.Context, view string) ([]ViewsFile, error)
+	ViewsByPath(ctx context.Context, path string) ([]ViewsFile, error)
+	MoveView(ctx context.Context, key ViewKey, view string, to int) error
+}
+
+// ContactsRepository stores the contacts.
+//
+// GetContact, UpdateContact and DeleteContact return ErrNotFound for an
+// unknown id. InsertContact stores c under a new id, ignoring c.ID, and
+// returns it with its id. SearchContacts orders contacts by id.
+type ContactsRepository interface {
+	GetContact(ctx context.Context, id int) (ContactRec, error)
+	InsertContact(ctx context.Context, c ContactRec) (ContactRec, error)
+	UpdateContact(ctx context.Context, c ContactRec) error
+	DeleteContact(ctx context.Context, id int) error
+	SearchContacts(ctx context.Context, f ContactFilter, p Page) ([]ContactRec, error)
+}
+
+var (
+	_ ViewsRepository    = (*Storage)(nil)
+	_ ContactsRepository = (*Storage)(nil)
+	_ ViewsRepository    = (*Memory)(nil)
+	_ ContactsRepository = (*Memory)(nil)
+)
@@ -0,0 +1,17 @@
+-- the tables of storage/migrations in the SQLite dialect
+CREATE TABLE IF NOT EXISTS viewsfile (
+    id       INTEGER PRIMARY KEY,
+    file     TEXT    NOT NULL,
+    view     TEXT    NOT NULL,
+    access   TEXT    NOT NULL,
+    path     TEXT    NOT NULL,
+    position INTEGER NOT NULL,
+    pathfile TEXT    NOT NULL,
+    UNIQUE (file, access, view)
+);
+
+CREATE TABLE IF NOT EXISTS contacts (
+    user_id      INTEGER PRIMARY KEY,
+    contact_name TEXT    NOT NULL,
+    phone        TEXT    NOT NULL
+);
@@ -0,0 +1,283 @@
+// Package sqlite implements the storage repositories on SQLite, with the
+// pure Go modernc.org/sqlite driver, so they can be used and tested
+// without a Postgres server.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed schema.sql
+var schema string
+
+// Store is a ViewsRepository and a ContactsRepository in a SQLite
+// database.
+type Store struct {
+	db *sql.DB
+}
+
+var (
+	_ storage.ViewsRepository    = (*Store)(nil)
+	_ storage.ContactsRepository = (*Store)(nil)
+)
+
+// Open opens the SQLite database at path, ":memory:" for a private
+// in-memory one, and creates the tables it lacks.
+func Open(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection: SQLite serializes writes anyway and every
+	// connection to ":memory:" is a different database
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create the sqlite schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// GetFileView implements storage.ViewsRepository.
+func (s *Store) GetFileView(ctx context.Context, file, access string) ([]storage.ViewsFile, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE access = ? AND file = ? ORDER BY position, view`, access, file)
+	if err != nil {
+		return nil, fmt.Errorf("get views of %s %s: %w", file, access, dbError(ctx, err))
+	}
+	defer rows.Close()
+	var views []storage.ViewsFile
+	for rows.Next() {
+		var v storage.ViewsFile
+		if err := rows.Scan(&v.File, &v.View, &v.Access, &v.Path, &v.Order, &v.Pathfile); err != nil {
+			return nil, fmt.Errorf("get views of %s %s: %w", file, access, err)
+		}
+		views = append(views, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get views of %s %s: %w", file, access, dbError(ctx, err))
+	}
+	if len(views) == 0 {
+		return nil, fmt.Errorf("views of %s %s: %w", file, access, storage.ErrNotFound)
+	}
+	return views, nil
+}
+
+// InsertViews implements storage.ViewsRepository.
+func (s *Store) InsertViews(ctx context.Context, v storage.ViewsFile) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO viewsfile (file, view, access, path, position, pathfile)
+		VALUES (?, ?, ?, ?, ?, ?)`, v.File, v.View, v.Access, v.Path, v.Order, v.Pathfile)
+	if err != nil {
+		return fmt.Errorf("insert view %s %s %s: %w", v.File, v.Access, v.View, dbError(ctx, err))
+	}
+	return nil
+}
+
+// GetContact implements storage.ContactsRepository.
+func (s *Store) GetContact(ctx context.Context, id int) (storage.ContactRec, error) {
+	c := storage.ContactRec{ID: id}
+	err := s.db.QueryRowContext(ctx, `SELECT contact_name, phone FROM contacts WHERE user_id = ?`, id).Scan(&c.Name, &c.Phone)
+	if err != nil {
+		return storage.ContactRec{}, fmt.Errorf("contact %d: %w", id, dbError(ctx, err))
+	}
+	return c, nil
+}
+
+// InsertContact implements storage.ContactsRepository.
+func (s *Store) InsertContact(ctx context.Context, c storage.ContactRec) (storage.ContactRec, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO contacts (contact_name, phone) VALUES (?, ?)`, c.Name, c.Phone)
+	if err != nil {
+		return storage.ContactRec{}, fmt.Errorf("insert contact: %w", dbError(ctx, err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return storage.ContactRec{}, err
+	}
+	c.ID = int(id)
+	return c, nil
+}
+
+// dbError maps a driver error onto the errors of the storage package, as
+// storage.Storage does for Postgres.
+func dbError(ctx context.Context, err error) error {
+	var sqlErr *sqlite.Error
+	switch {
+	case ctx.Err() != nil && !errors.Is(err, ctx.Err()):
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	case errors.Is(err, sql.ErrNoRows):
+		return storage.ErrNotFound
+	case errors.As(err, &sqlErr) &&
+		(sqlErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqlErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY):
+		return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	}
+	return err
+}
+
+// UpdateView implements storage.ViewsRepository.
+func (s *Store) UpdateView(ctx context.Context, v storage.ViewsFile) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE viewsfile SET path = ?, position = ?, pathfile = ?
+		WHERE file = ? AND access = ? AND view = ?`, v.Path, v.Order, v.Pathfile, v.File, v.Access, v.View)
+	return affected(ctx, res, err, fmt.Sprintf("update view %s %s %s", v.File, v.Access, v.View))
+}
+
+// DeleteView implements storage.ViewsRepository.
+func (s *Store) DeleteView(ctx context.Context, file, access, view string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM viewsfile WHERE file = ? AND access = ? AND view = ?`, file, access, view)
+	return affected(ctx, res, err, fmt.Sprintf("delete view %s %s %s", file, access, view))
+}
+
+func affected(ctx context.Context, res sql.Result, err error, what string) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, dbError(ctx, err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", what, storage.ErrNotFound)
+	}
+	return nil
+}
+
+// ListViews implements storage.ViewsRepository.
+func (s *Store) ListViews(ctx context.Context, f storage.ViewFilter, p storage.Page) ([]storage.ViewsFile, error) {
+	limit := p.Limit
+	if limit <= 0 {
+		limit = storage.DefaultPageSize
+	}
+	return queryViews(ctx, s.db, "list views", `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE (?1 = '' OR file = ?1) AND (?2 = '' OR access = ?2)
+		ORDER BY file, access, position, view LIMIT ?3 OFFSET ?4`, f.File, f.Access, limit, max(p.Offset, 0))
+}
+
+// ViewsByName implements storage.ViewsRepository.
+func (s *Store) ViewsByName(ctx context.Context, view string) ([]storage.ViewsFile, error) {
+	views, err := queryViews(ctx, s.db, "views named "+view, `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE view = ? ORDER BY file, access, position`, view)
+	if err == nil && len(views) == 0 {
+		err = fmt.Errorf("views named %s: %w", view, storage.ErrNotFound)
+	}
+	return views, err
+}
+
+// ViewsByPath implements storage.ViewsRepository.
+func (s *Store) ViewsByPath(ctx context.Context, path string) ([]storage.ViewsFile, error) {
+	views, err := queryViews(ctx, s.db, "views of path "+path, `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE path = ? ORDER BY file, access, position, view`, path)
+	if err == nil && len(views) == 0 {
+		err = fmt.Errorf("views of path %s: %w", path, storage.ErrNotFound)
+	}
+	return views, err
+}
+
+// queryer is what queryViews needs of a *sql.DB or a *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryViews(ctx context.Context, q queryer, what, query string, args ...any) ([]storage.ViewsFile, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", what, dbError(ctx, err))
+	}
+	defer rows.Close()
+	views := []storage.ViewsFile{}
+	for rows.Next() {
+		var v storage.ViewsFile
+		if err := rows.Scan(&v.File, &v.View, &v.Access, &v.Path, &v.Order, &v.Pathfile); err != nil {
+			return nil, fmt.Errorf("%s: %w", what, err)
+		}
+		views = append(views, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", what, dbError(ctx, err))
+	}
+	return views, nil
+}
+
+// MoveView implements storage.ViewsRepository.
+func (s *Store) MoveView(ctx context.Context, key storage.ViewKey, view string, to int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("move view %s: %w", view, dbError(ctx, err))
+	}
+	defer tx.Rollback()
+
+	views, err := queryViews(ctx, tx, "move view "+view, `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE file = ? AND access = ? ORDER BY position, view`, key.File, key.Access)
+	if err != nil {
+		return err
+	}
+	moved, err := storage.Reorder(views, view, to)
+	if err != nil {
+		return fmt.Errorf("move view of %s %s: %w", key.File, key.Access, err)
+	}
+	for i, v := range moved {
+		if v.Order == views[i].Order && v.View == views[i].View {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE viewsfile SET position = ? WHERE file = ? AND access = ? AND view = ?`,
+			v.Order, v.File, v.Access, v.View)
+		if err != nil {
+			return fmt.Errorf("move view %s: %w", view, dbError(ctx, err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("move view %s: %w", view, dbError(ctx, err))
+	}
+	return nil
+}
+
+// UpdateContact implements storage.ContactsRepository.
+func (s *Store) UpdateContact(ctx context.Context, c storage.ContactRec) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE contacts SET contact_name = ?, phone = ? WHERE user_id = ?`, c.Name, c.Phone, c.ID)
+	return affected(ctx, res, err, fmt.Sprintf("update contact %d", c.ID))
+}
+
+// DeleteContact implements storage.ContactsRepository.
+func (s *Store) DeleteContact(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM contacts WHERE user_id = ?`, id)
+	return affected(ctx, res, err, fmt.Sprintf("delete contact %d", id))
+}
+
+// SearchContacts implements storage.ContactsRepository. Names are compared
+// case insensitively for ASCII letters only, as SQLite's lower does.
+func (s *Store) SearchContacts(ctx context.Context, f storage.ContactFilter, p storage.Page) ([]storage.ContactRec, error) {
+	limit := p.Limit
+	if limit <= 0 {
+		limit = storage.DefaultPageSize
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, contact_name, phone FROM contacts
+		WHERE instr(lower(contact_name), lower(?1)) > 0 AND substr(phone, 1, length(?2)) = ?2
+		ORDER BY user_id LIMIT ?3 OFFSET ?4`, f.Name, f.PhonePrefix, limit, max(p.Offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("search contacts: %w", dbError(ctx, err))
+	}
+	defer rows.Close()
+	contacts := []storage.ContactRec{}
+	for rows.Next() {
+		var c storage.ContactRec
+		if err := rows.Scan(&c.ID, &c.Name, &c.Phone); err != nil {
+			return nil, fmt.Errorf("search contacts: %w", err)
+		}
+		contacts = append(contacts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search contacts: %w", dbError(ctx, err))
+	}
+	return contacts, nil
+}
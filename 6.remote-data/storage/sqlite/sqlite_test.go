@@ -0,0 +1,27 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"github.com/MoadHar/go_ops/6.remote-data/storage/sqlite"
+	"github.com/MoadHar/go_ops/6.remote-data/storage/storagetest"
+)
+
+func newStore(t *testing.T) *sqlite.Store {
+	s, err := sqlite.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteViews(t *testing.T) {
+	storagetest.TestViews(t, func(t *testing.T) storage.ViewsRepository { return newStore(t) })
+}
+
+func TestSQLiteContacts(t *testing.T) {
+	storagetest.TestContacts(t, func(t *testing.T) storage.ContactsRepository { return newStore(t) })
+}
@@ -0,0 +1,146 @@
+// Package storage keeps the FILEVUEP views of the mainframe files in the
+// viewsfile Postgres table, and the contacts of the team.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+var (
+	// ErrNotFound is returned, wrapped, when no row matches.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned, wrapped with the driver error, when a row
+	// would break a unique constraint.
+	ErrConflict = errors.New("conflicts with an existing row")
+)
+
+// Storage reads and writes the views through prepared statements on a
+// connection pool it does not own.
+type Storage struct {
+	db *sql.DB
+	// conn is db, or the transaction of inTx in the Storage of a Tx
+	conn         dbtx
+	inTx         *Tx
+	getFileStmt  *sql.Stmt
+	insViewsStmt *sql.Stmt
+	trace        queryTrace
+}
+
+// the queries of the prepared statements
+const (
+	getFileQuery  = `select "file", "view", "access", "path", "position", "pathfile" FROM viewsfile WHERE "access" = $1 and "file" = $2 ORDER BY "position", "view"`
+	insViewsQuery = `insert into viewsfile (file, view, access, path, position, pathfile)
+	values ($1, $2, $3, $4, $5, $6)`
+)
+
+// NewStorage prepares the statements of Storage on conn. Close releases
+// them, conn stays open. By default the queries are not traced, see
+// SlowQueries and Traced.
+func NewStorage(ctx context.Context, conn *sql.DB, opts ...Option) (*Storage, error) {
+	selStmt, err := conn.PrepareContext(ctx, getFileQuery)
+	if err != nil {
+		return nil, fmt.Errorf("prepare views select: %w", dbError(ctx, err))
+	}
+	insStmt, err := conn.PrepareContext(ctx, insViewsQuery)
+	if err != nil {
+		selStmt.Close()
+		return nil, fmt.Errorf("prepare views insert: %w", dbError(ctx, err))
+	}
+	s := &Storage{
+		db:           conn,
+		conn:         conn,
+		getFileStmt:  selStmt,
+		insViewsStmt: insStmt,
+	}
+	for _, opt := range opts {
+		opt(&s.trace)
+	}
+	return s, nil
+}
+
+// Close releases the prepared statements.
+func (s *Storage) Close() error {
+	return errors.Join(s.getFileStmt.Close(), s.insViewsStmt.Close())
+}
+
+// GetFileView returns the views of filename with access ordered by
+// position. It returns ErrNotFound when there are none.
+func (s *Storage) GetFileView(ctx context.Context, filename string, access string) (recs []ViewsFile, err error) {
+	ctx, q := s.startQuery(ctx, "get views", getFileQuery, access, filename)
+	defer func() { q.end(int64(len(recs)), err) }()
+	rows, err := s.getFileStmt.QueryContext(ctx, access, filename)
+	if err != nil {
+		return nil, fmt.Errorf("get views of %s %s: %w", filename, access, dbError(ctx, err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rec := ViewsFile{}
+		if err := rows.Scan(&rec.File, &rec.View, &rec.Access, &rec.Path, &rec.Order, &rec.Pathfile); err != nil {
+			return nil, fmt.Errorf("get views of %s %s: %w", filename, access, err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get views of %s %s: %w", filename, access, dbError(ctx, err))
+	}
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("views of %s %s: %w", filename, access, ErrNotFound)
+	}
+	return recs, nil
+}
+
+// InsertViews adds one view. It returns ErrConflict when the view is
+// already there.
+func (s *Storage) InsertViews(ctx context.Context, p_viewfile ViewsFile) error {
+	args := []any{
+		p_viewfile.File,
+		p_viewfile.View,
+		p_viewfile.Access,
+		p_viewfile.Path,
+		p_viewfile.Order,
+		p_viewfile.Pathfile,
+	}
+	ctx, q := s.startQuery(ctx, "insert view", insViewsQuery, args...)
+	res, err := s.insViewsStmt.ExecContext(ctx, args...)
+	if err != nil {
+		err = fmt.Errorf("insert view %s %s %s: %w", p_viewfile.File, p_viewfile.Access, p_viewfile.View, dbError(ctx, err))
+	}
+	return q.endExec(res, err)
+}
+
+// dbError maps a driver error onto the errors of the package: the context
+// error when ctx is done, ErrNotFound for sql.ErrNoRows and ErrConflict,
+// joined to the driver error, for a unique violation, as ErrSerialization
+// for a serialization failure.
+func dbError(ctx context.Context, err error) error {
+	var pgErr *pgconn.PgError
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil && !errors.Is(err, ctx.Err()):
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case errors.As(err, &pgErr) && pgErr.Code == "40001":
+		return fmt.Errorf("%w: %w", ErrSerialization, err)
+	}
+	return err
+}
+
+// ViewsFile is one row of the viewsfile table.
+type ViewsFile struct {
+	File     string `json:"file"`
+	View     string `json:"view"`
+	Access   string `json:"access"`
+	Path     string `json:"path"`
+	Order    int    `json:"position"`
+	Pathfile string `json:"pathfile"`
+}
@@ -0,0 +1,355 @@
+// Package storagetest is the conformance suite of the storage
+// repositories. Every implementation runs it from its own tests so they
+// all behave like the Postgres one.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+)
+
+// TestViews runs the ViewsRepository suite. newRepo must return an empty
+// repository for every call.
+func TestViews(t *testing.T, newRepo func(t *testing.T) storage.ViewsRepository) {
+	ctx := context.Background()
+	view := func(file, access, name string, pos int) storage.ViewsFile {
+		return storage.ViewsFile{File: file, View: name, Access: access, Path: file + "LKS0", Order: pos, Pathfile: file}
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.GetFileView(ctx, "F100", "LINKPATH")
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetFileView of an empty repository: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("OrderedByPosition", func(t *testing.T) {
+		repo := newRepo(t)
+		want := []storage.ViewsFile{
+			view("F100", "LINKPATH", "V1", 1),
+			view("F100", "LINKPATH", "V2", 2),
+			view("F100", "LINKPATH", "V3", 3),
+		}
+		others := []storage.ViewsFile{
+			view("F100", "INDEX", "V1", 1),
+			view("F200", "LINKPATH", "V1", 1),
+		}
+		for _, v := range []storage.ViewsFile{want[2], others[0], want[0], others[1], want[1]} {
+			if err := repo.InsertViews(ctx, v); err != nil {
+				t.Fatalf("InsertViews(%+v): %v", v, err)
+			}
+		}
+		got, err := repo.GetFileView(ctx, "F100", "LINKPATH")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("GetFileView:\n got %+v\nwant %+v", got, want)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		repo := newRepo(t)
+		v := view("F100", "LINKPATH", "V1", 1)
+		if err := repo.InsertViews(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+		v.Order = 2
+		if err := repo.InsertViews(ctx, v); !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("InsertViews of the same view twice: got %v, want ErrConflict", err)
+		}
+		got, err := repo.GetFileView(ctx, "F100", "LINKPATH")
+		if err != nil || len(got) != 1 || got[0].Order != 1 {
+			t.Errorf("after a conflict got %+v, %v, want the first view only", got, err)
+		}
+	})
+
+	insert := func(t *testing.T, repo storage.ViewsRepository, views ...storage.ViewsFile) {
+		t.Helper()
+		for _, v := range views {
+			if err := repo.InsertViews(ctx, v); err != nil {
+				t.Fatalf("InsertViews(%+v): %v", v, err)
+			}
+		}
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+		insert(t, repo, view("F100", "LINKPATH", "V1", 1), view("F100", "LINKPATH", "V2", 2))
+		v := view("F100", "LINKPATH", "V1", 3)
+		v.Path, v.Pathfile = "F300LKS0", "F300"
+		if err := repo.UpdateView(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.GetFileView(ctx, "F100", "LINKPATH")
+		want := []storage.ViewsFile{view("F100", "LINKPATH", "V2", 2), v}
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("after UpdateView got %+v, %v, want %+v", got, err, want)
+		}
+		if err := repo.UpdateView(ctx, view("F100", "INDEX", "V1", 1)); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("UpdateView of a missing view: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		insert(t, repo, view("F100", "LINKPATH", "V1", 1), view("F100", "LINKPATH", "V2", 2))
+		if err := repo.DeleteView(ctx, "F100", "LINKPATH", "V1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteView(ctx, "F100", "LINKPATH", "V1"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("DeleteView of a deleted view: got %v, want ErrNotFound", err)
+		}
+		got, err := repo.GetFileView(ctx, "F100", "LINKPATH")
+		if want := []storage.ViewsFile{view("F100", "LINKPATH", "V2", 2)}; err != nil || !slices.Equal(got, want) {
+			t.Errorf("after DeleteView got %+v, %v, want %+v", got, err, want)
+		}
+		if err := repo.DeleteView(ctx, "F100", "LINKPATH", "V2"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetFileView(ctx, "F100", "LINKPATH"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetFileView after deleting every view: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepo(t)
+		all := []storage.ViewsFile{
+			view("F100", "INDEX", "V1", 1),
+			view("F100", "LINKPATH", "V2", 1),
+			view("F100", "LINKPATH", "V1", 2),
+			view("F200", "LINKPATH", "V1", 1),
+			view("F200", "LINKPATH", "V2", 2),
+		}
+		insert(t, repo, all[4], all[2], all[0], all[3], all[1])
+		var got []storage.ViewsFile
+		for p := (storage.Page{Limit: 2}); ; p.Offset += p.Limit {
+			page, err := repo.ListViews(ctx, storage.ViewFilter{}, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) > p.Limit {
+				t.Fatalf("ListViews(%+v) returned %d views", p, len(page))
+			}
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page...)
+		}
+		if !slices.Equal(got, all) {
+			t.Errorf("ListViews by pages of 2:\n got %+v\nwant %+v", got, all)
+		}
+
+		got, err := repo.ListViews(ctx, storage.ViewFilter{Access: "LINKPATH"}, storage.Page{Offset: 1})
+		if want := []storage.ViewsFile{all[2], all[3], all[4]}; err != nil || !slices.Equal(got, want) {
+			t.Errorf("ListViews of LINKPATH from 1:\n got %+v, %v\nwant %+v", got, err, want)
+		}
+		got, err = repo.ListViews(ctx, storage.ViewFilter{File: "F300"}, storage.Page{})
+		if err != nil || len(got) != 0 {
+			t.Errorf("ListViews of a missing file: got %+v, %v, want no views", got, err)
+		}
+	})
+
+	t.Run("Lookups", func(t *testing.T) {
+		repo := newRepo(t)
+		a, b, c := view("F100", "LINKPATH", "V1", 1), view("F200", "INDEX", "V1", 1), view("F200", "INDEX", "V2", 2)
+		c.Path = a.Path
+		insert(t, repo, c, b, a)
+		got, err := repo.ViewsByName(ctx, "V1")
+		if want := []storage.ViewsFile{a, b}; err != nil || !slices.Equal(got, want) {
+			t.Errorf("ViewsByName(V1) = %+v, %v, want %+v", got, err, want)
+		}
+		got, err = repo.ViewsByPath(ctx, a.Path)
+		if want := []storage.ViewsFile{a, c}; err != nil || !slices.Equal(got, want) {
+			t.Errorf("ViewsByPath(%s) = %+v, %v, want %+v", a.Path, got, err, want)
+		}
+		if _, err := repo.ViewsByName(ctx, "V9"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("ViewsByName of a missing view: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.ViewsByPath(ctx, "F900LKS0"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("ViewsByPath of a missing path: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		key := storage.ViewKey{File: "F100", Access: "LINKPATH"}
+		order := func(t *testing.T, repo storage.ViewsRepository) []string {
+			t.Helper()
+			views, err := repo.GetFileView(ctx, key.File, key.Access)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for i, v := range views {
+				if v.Order != i+1 {
+					t.Errorf("view %s at position %d, want %d", v.View, v.Order, i+1)
+				}
+				names = append(names, v.View)
+			}
+			return names
+		}
+		for _, tc := range []struct {
+			view string
+			to   int
+			want []string
+		}{
+			{"V3", 1, []string{"V3", "V1", "V2", "V4"}},
+			{"V1", 3, []string{"V2", "V3", "V1", "V4"}},
+			{"V2", 2, []string{"V1", "V2", "V3", "V4"}},
+			{"V2", 99, []string{"V1", "V3", "V4", "V2"}},
+			{"V4", -1, []string{"V4", "V1", "V2", "V3"}},
+		} {
+			repo := newRepo(t)
+			// sparse positions are renumbered from 1
+			insert(t, repo, view("F100", "LINKPATH", "V1", 10), view("F100", "LINKPATH", "V2", 20),
+				view("F100", "LINKPATH", "V3", 30), view("F100", "LINKPATH", "V4", 40), view("F100", "INDEX", "V1", 5))
+			if err := repo.MoveView(ctx, key, tc.view, tc.to); err != nil {
+				t.Fatalf("MoveView(%s, %d): %v", tc.view, tc.to, err)
+			}
+			if got := order(t, repo); !slices.Equal(got, tc.want) {
+				t.Errorf("MoveView(%s, %d): got %v, want %v", tc.view, tc.to, got, tc.want)
+			}
+			other, err := repo.GetFileView(ctx, "F100", "INDEX")
+			if err != nil || len(other) != 1 || other[0].Order != 5 {
+				t.Errorf("MoveView changed another access: %+v, %v", other, err)
+			}
+		}
+
+		repo := newRepo(t)
+		insert(t, repo, view("F100", "LINKPATH", "V1", 1))
+		if err := repo.MoveView(ctx, key, "V9", 1); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("MoveView of a missing view: got %v, want ErrNotFound", err)
+		}
+		if err := repo.MoveView(ctx, storage.ViewKey{File: "F900", Access: "LINKPATH"}, "V1", 1); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("MoveView in a missing file: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := repo.InsertViews(ctx, view("F100", "LINKPATH", "V1", 1)); !errors.Is(err, context.Canceled) {
+			t.Errorf("InsertViews with a canceled context: got %v, want context.Canceled", err)
+		}
+		if _, err := repo.GetFileView(ctx, "F100", "LINKPATH"); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetFileView with a canceled context: got %v, want context.Canceled", err)
+		}
+		if _, err := repo.ListViews(ctx, storage.ViewFilter{}, storage.Page{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("ListViews with a canceled context: got %v, want context.Canceled", err)
+		}
+		if err := repo.MoveView(ctx, storage.ViewKey{File: "F100", Access: "LINKPATH"}, "V1", 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("MoveView with a canceled context: got %v, want context.Canceled", err)
+		}
+	})
+}
+
+// TestContacts runs the ContactsRepository suite. newRepo must return an
+// empty repository for every call.
+func TestContacts(t *testing.T, newRepo func(t *testing.T) storage.ContactsRepository) {
+	ctx := context.Background()
+
+	t.Run("NotFound", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.GetContact(ctx, 1); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetContact of an empty repository: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("InsertGet", func(t *testing.T) {
+		repo := newRepo(t)
+		a, err := repo.InsertContact(ctx, storage.ContactRec{ID: 42, Name: "Ada", Phone: "+33612345678"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := repo.InsertContact(ctx, storage.ContactRec{Name: "Bob", Phone: "+14155550100"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.ID <= 0 || b.ID <= 0 || a.ID == b.ID {
+			t.Fatalf("InsertContact gave ids %d and %d, want distinct positive ids", a.ID, b.ID)
+		}
+		for _, want := range []storage.ContactRec{a, b} {
+			got, err := repo.GetContact(ctx, want.ID)
+			if err != nil || got != want {
+				t.Errorf("GetContact(%d) = %+v, %v, want %+v", want.ID, got, err, want)
+			}
+		}
+	})
+
+	t.Run("UpdateDelete", func(t *testing.T) {
+		repo := newRepo(t)
+		c, err := repo.InsertContact(ctx, storage.ContactRec{Name: "Ada", Phone: "+33612345678"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Name, c.Phone = "Ada Lovelace", "+442071234567"
+		if err := repo.UpdateContact(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := repo.GetContact(ctx, c.ID); err != nil || got != c {
+			t.Errorf("after UpdateContact got %+v, %v, want %+v", got, err, c)
+		}
+		if err := repo.DeleteContact(ctx, c.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetContact(ctx, c.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetContact of a deleted contact: got %v, want ErrNotFound", err)
+		}
+		if err := repo.UpdateContact(ctx, c); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("UpdateContact of a deleted contact: got %v, want ErrNotFound", err)
+		}
+		if err := repo.DeleteContact(ctx, c.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("DeleteContact of a deleted contact: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		repo := newRepo(t)
+		var all []storage.ContactRec
+		for _, c := range []storage.ContactRec{
+			{Name: "Ada Lovelace", Phone: "+442071234567"},
+			{Name: "Bob", Phone: "+14155550100"},
+			{Name: "Adam", Phone: "+33612345678"},
+			{Name: "Lada", Phone: "+441632960000"},
+		} {
+			c, err := repo.InsertContact(ctx, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			all = append(all, c)
+		}
+		for _, tc := range []struct {
+			f    storage.ContactFilter
+			p    storage.Page
+			want []storage.ContactRec
+		}{
+			{storage.ContactFilter{}, storage.Page{}, all},
+			{storage.ContactFilter{}, storage.Page{Offset: 1, Limit: 2}, all[1:3]},
+			{storage.ContactFilter{}, storage.Page{Offset: 9}, nil},
+			{storage.ContactFilter{Name: "ADA"}, storage.Page{}, []storage.ContactRec{all[0], all[2], all[3]}},
+			{storage.ContactFilter{PhonePrefix: "+44"}, storage.Page{}, []storage.ContactRec{all[0], all[3]}},
+			{storage.ContactFilter{Name: "ada", PhonePrefix: "+44"}, storage.Page{Limit: 1}, all[:1]},
+			{storage.ContactFilter{PhonePrefix: "4415"}, storage.Page{}, nil},
+		} {
+			got, err := repo.SearchContacts(ctx, tc.f, tc.p)
+			if err != nil || got == nil || !slices.Equal(got, tc.want) {
+				t.Errorf("SearchContacts(%+v, %+v) = %+v, %v, want %+v", tc.f, tc.p, got, err, tc.want)
+			}
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		repo := newRepo(t)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := repo.GetContact(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetContact with a canceled context: got %v, want context.Canceled", err)
+		}
+	})
+}
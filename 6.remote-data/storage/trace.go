@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of the spans of Storage.
+const tracerName = "github.com/MoadHar/go_ops/6.remote-data/storage"
+
+// Option configures a Storage made by NewStorage.
+type Option func(*queryTrace)
+
+// SlowQueries logs with logf the queries taking threshold or more, with
+// their duration, row count, kind of error and redacted arguments: strings
+// and bytes, which may be names, phones or passwords, only show their
+// length, and errors, which may quote them, only their class.
+func SlowQueries(threshold time.Duration, logf func(format string, args ...any)) Option {
+	return func(t *queryTrace) { t.slow, t.logf = threshold, logf }
+}
+
+// Traced records every query as a span of tp, with its statement, row
+// count and class of error, see NewTracerProvider.
+func Traced(tp trace.TracerProvider) Option {
+	return func(t *queryTrace) { t.tracer = tp.Tracer(tracerName) }
+}
+
+// queryTrace is how the queries of a Storage are traced. The zero value
+// traces nothing.
+type queryTrace struct {
+	slow   time.Duration
+	logf   func(format string, args ...any)
+	tracer trace.Tracer
+}
+
+// querySpan is a query being traced, from startQuery to end.
+type querySpan struct {
+	t     *queryTrace
+	op    string
+	query string
+	args  []any
+	start time.Time
+	span  trace.Span
+}
+
+// startQuery starts tracing the query of operation op. The returned
+// context carries the span, if any, and is the one to run the query with.
+func (s *Storage) startQuery(ctx context.Context, op, query string, args ...any) (context.Context, *querySpan) {
+	q := &querySpan{t: &s.trace, op: op, query: query, args: args, start: time.Now()}
+	if s.trace.tracer != nil {
+		ctx, q.span = s.trace.tracer.Start(ctx, op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system.name", "postgresql"),
+				attribute.String("db.operation.name", op),
+				attribute.String("db.query.text", oneLine(query)),
+			))
+	}
+	return ctx, q
+}
+
+// end records that the query returned or changed rows rows, or failed
+// with err, and returns err. ErrNotFound is a result, not a failure.
+func (q *querySpan) end(rows int64, err error) error {
+	d := time.Since(q.start)
+	if q.span != nil {
+		q.span.SetAttributes(attribute.Int64("db.response.returned_rows", rows))
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			class := errorClass(err)
+			q.span.SetAttributes(attribute.String("error.type", class))
+			q.span.SetStatus(codes.Error, class)
+		}
+		q.span.End()
+	}
+	if q.t.logf != nil && q.t.slow > 0 && d >= q.t.slow {
+		outcome := fmt.Sprintf("%d rows", rows)
+		if err != nil {
+			outcome = "error: " + errorClass(err)
+		}
+		q.t.logf("slow query %s: %v, %s: %s [%s]", q.op, d.Round(time.Millisecond), outcome, oneLine(q.query), redact(q.args))
+	}
+	return err
+}
+
+// endExec is end for a statement of result res, nil when it failed.
+func (q *querySpan) endExec(res sql.Result, err error) error {
+	var rows int64
+	if res != nil {
+		rows, _ = res.RowsAffected()
+	}
+	return q.end(rows, err)
+}
+
+// errorClass names the kind of err without its text, which may quote the
+// arguments of the query: the error of this package or of the context it
+// wraps, and the SQLSTATE of a Postgres error.
+func errorClass(err error) string {
+	cause := err
+	for next := errors.Unwrap(cause); next != nil; next = errors.Unwrap(cause) {
+		cause = next
+	}
+	class := fmt.Sprintf("%T", cause)
+	for _, known := range []error{ErrNotFound, ErrConflict, ErrSerialization, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(err, known) {
+			class = known.Error()
+			break
+		}
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		class += " (SQLSTATE " + pgErr.Code + ")"
+	}
+	return class
+}
+
+// oneLine collapses the white space of a query, for logs and spans.
+func oneLine(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// redact describes the arguments of a query without showing the values of
+// strings and bytes.
+func redact(args []any) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		var v string
+		switch a := a.(type) {
+		case nil:
+			v = "NULL"
+		case bool, int, int32, int64, float64, time.Time:
+			v = fmt.Sprint(a)
+		case string:
+			v = fmt.Sprintf("<%d chars>", utf8.RuneCountInString(a))
+		case []byte:
+			v = fmt.Sprintf("<%d bytes>", len(a))
+		default:
+			v = fmt.Sprintf("<%T>", a)
+		}
+		parts[i] = fmt.Sprintf("$%d=%s", i+1, v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// NewTracerProvider returns a TracerProvider exporting its spans as JSON,
+// one object per span, to dest: a file, appended to, or "-" for stdout.
+// Its Shutdown must be called before exiting, it flushes the spans and
+// closes the file.
+func NewTracerProvider(dest string) (*sdktrace.TracerProvider, error) {
+	var w io.Writer = os.Stdout
+	closeFile := func() error { return nil }
+	if dest != "-" {
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("trace to %s: %w", dest, err)
+		}
+		w, closeFile = f, f.Close
+	}
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		closeFile()
+		return nil, fmt.Errorf("trace to %s: %w", dest, err)
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(closingExporter{exp, closeFile}),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", filepath.Base(os.Args[0])),
+		)),
+	), nil
+}
+
+// closingExporter closes the file of its spans once shut down.
+type closingExporter struct {
+	sdktrace.SpanExporter
+	close func() error
+}
+
+func (e closingExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.close())
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSlowQueryError(t *testing.T) {
+	ctx := context.Background()
+	exp := tracetest.NewInMemoryExporter()
+	var logged []string
+	s := newSQLite(t,
+		Traced(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))),
+		SlowQueries(time.Nanosecond, func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }))
+
+	c := ContactRec{Name: "Ada Lovelace", Phone: "+33612345678"}
+	if _, err := s.InsertContact(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.InsertContact(ctx, c)
+	if err == nil {
+		t.Fatal("inserting the name twice: no error")
+	}
+	if strings.Contains(err.Error(), "Ada") {
+		t.Errorf("the error holds the name: %v", err)
+	}
+	if len(logged) != 2 || !strings.Contains(logged[1], "error: *sqlite.Error") {
+		t.Fatalf("logged %q, want the class of the error", logged)
+	}
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(spans))
+	}
+	for _, l := range append(logged, spans[1].Status.Description, fmt.Sprint(spans[1].Attributes), fmt.Sprint(spans[1].Events)) {
+		if strings.Contains(l, "Ada") || strings.Contains(l, "+3361") {
+			t.Errorf("logged the arguments: %s", l)
+		}
+	}
+	if !strings.Contains(fmt.Sprint(spans[1].Attributes), string(attribute.Key("error.type"))) {
+		t.Errorf("span attributes %v, want error.type", spans[1].Attributes)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("contact 3: %w", ErrNotFound), "not found"},
+		{fmt.Errorf("insert view: %w", fmt.Errorf("%w: %w", ErrConflict, errors.New("name Ada"))), "conflicts with an existing row"},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), "context deadline exceeded"},
+		{fmt.Errorf("%w: %w", ErrConflict, &pgconn.PgError{Code: "23505", Detail: "Key (name)=(Ada) already exists."}), "conflicts with an existing row (SQLSTATE 23505)"},
+		{&pgconn.PgError{Code: "23502"}, "*pgconn.PgError (SQLSTATE 23502)"},
+		{fmt.Errorf("insert contact Ada: %w", errors.New("boom")), "*errors.errorString"},
+	} {
+		if got := errorClass(tc.err); got != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrSerialization is returned, wrapped with the driver error, when
+// Postgres aborts a transaction that could not be serialized with
+// concurrent ones. WithTx retries those transactions before giving up.
+var ErrSerialization = errors.New("could not serialize the transaction")
+
+// DefaultTxRetries is how many times WithTx runs a transaction again after
+// a serialization failure, unless told otherwise by TxRetries.
+const DefaultTxRetries = 3
+
+// dbtx is what the Storage methods need of a *sql.DB or a *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TxOption configures a transaction started by WithTx.
+type TxOption func(*txConfig)
+
+type txConfig struct {
+	opts    sql.TxOptions
+	retries int
+}
+
+// Isolation sets the isolation level of the transaction, the database
+// default when not given.
+func Isolation(level sql.IsolationLevel) TxOption {
+	return func(c *txConfig) { c.opts.Isolation = level }
+}
+
+// ReadOnly starts a read only transaction.
+func ReadOnly() TxOption {
+	return func(c *txConfig) { c.opts.ReadOnly = true }
+}
+
+// TxRetries sets how many times a transaction is run again after a
+// serialization failure, 0 to never retry.
+func TxRetries(n int) TxOption {
+	return func(c *txConfig) { c.retries = max(n, 0) }
+}
+
+// Tx is a transaction started by Storage.WithTx. Every Storage method
+// called on it runs in the transaction. A Tx is not safe for concurrent
+// use and must not be used once the function it was given to returns.
+type Tx struct {
+	*Storage
+	sqlConn *sql.Conn
+	sqlTx   *sql.Tx
+	depth   int
+}
+
+// WithTx runs fn in a transaction, committed when fn returns nil and
+// rolled back when it returns an error or panics. The error of fn is
+// returned as is.
+//
+// When the transaction fails to serialize, see ErrSerialization, fn is run
+// again in a new transaction, up to DefaultTxRetries times, so it must not
+// have side effects outside of tx. Retrying is only useful at the
+// Isolation levels sql.LevelRepeatableRead and sql.LevelSerializable.
+//
+// Called on a Tx, WithTx runs fn in a savepoint of that transaction
+// instead: an error of fn rolls back to the savepoint only, and opts are
+// ignored.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx *Tx) error, opts ...TxOption) error {
+	if s.inTx != nil {
+		return s.inTx.savepoint(ctx, fn)
+	}
+	cfg := txConfig{retries: DefaultTxRetries}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for attempt := 0; ; attempt++ {
+		err := s.runTx(ctx, fn, &cfg.opts)
+		if attempt >= cfg.retries || !serializationFailure(err) {
+			return err
+		}
+		// back off a little, with jitter, so the transactions that
+		// conflicted do not meet again
+		wait := time.Duration(rand.Int64N(int64(10*time.Millisecond) << attempt))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// runTx runs fn once in a transaction on a connection of its own, so the
+// driver connection can be reached by ImportViews.
+func (s *Storage) runTx(ctx context.Context, fn func(tx *Tx) error, opts *sql.TxOptions) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", dbError(ctx, err))
+	}
+	defer conn.Close()
+	sqlTx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("begin: %w", dbError(ctx, err))
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	tx := &Tx{sqlConn: conn, sqlTx: sqlTx}
+	tx.Storage = &Storage{
+		db:           s.db,
+		conn:         sqlTx,
+		inTx:         tx,
+		getFileStmt:  sqlTx.StmtContext(ctx, s.getFileStmt),
+		insViewsStmt: sqlTx.StmtContext(ctx, s.insViewsStmt),
+		trace:        s.trace,
+	}
+	if err := fn(tx); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", dbError(ctx, err))
+	}
+	return nil
+}
+
+// savepoint runs fn in a new savepoint of t.
+func (t *Tx) savepoint(ctx context.Context, fn func(tx *Tx) error) error {
+	t.depth++
+	name := fmt.Sprintf("goops_sp%d", t.depth)
+	defer func() { t.depth-- }()
+	if _, err := t.sqlTx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("savepoint: %w", dbError(ctx, err))
+	}
+	if err := fn(t); err != nil {
+		if _, rbErr := t.sqlTx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback to savepoint: %w", dbError(ctx, rbErr)))
+		}
+		return err
+	}
+	if _, err := t.sqlTx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("release savepoint: %w", dbError(ctx, err))
+	}
+	return nil
+}
+
+// serializationFailure reports whether err comes from a transaction
+// Postgres could not serialize.
+func serializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
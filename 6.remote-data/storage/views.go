@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// DefaultPageSize is the page size of a Page without a Limit.
+const DefaultPageSize = 100
+
+// Page selects a page of a list.
+type Page struct {
+	Offset int
+	// Limit is the most rows returned, DefaultPageSize when 0.
+	Limit int
+}
+
+// bounds returns the offset and limit to query.
+func (p Page) bounds() (offset, limit int) {
+	offset, limit = max(p.Offset, 0), p.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	return offset, limit
+}
+
+// Reorder moves view to position to, 1 being the first, among views of a
+// single file and access, and returns them in their new order with their
+// positions renumbered from 1. to is clamped to the number of views.
+func Reorder(views []ViewsFile, view string, to int) ([]ViewsFile, error) {
+	i := slices.IndexFunc(views, func(v ViewsFile) bool { return v.View == view })
+	if i < 0 {
+		return nil, fmt.Errorf("view %s: %w", view, ErrNotFound)
+	}
+	moved := views[i]
+	out := slices.Delete(slices.Clone(views), i, i+1)
+	to = min(max(to, 1), len(views))
+	out = slices.Insert(out, to-1, moved)
+	for i := range out {
+		out[i].Order = i + 1
+	}
+	return out, nil
+}
+
+// UpdateView changes the path, position and pathfile of the view with the
+// file, access and view of v. It returns ErrNotFound when there is none.
+func (s *Storage) UpdateView(ctx context.Context, v ViewsFile) error {
+	const query = `UPDATE viewsfile SET path = $4, position = $5, pathfile = $6
+		WHERE file = $1 AND access = $2 AND view = $3`
+	args := []any{v.File, v.Access, v.View, v.Path, v.Order, v.Pathfile}
+	ctx, q := s.startQuery(ctx, "update view", query, args...)
+	res, err := s.conn.ExecContext(ctx, query, args...)
+	return q.endExec(res, affected(ctx, res, err, "update view %s %s %s", v.File, v.Access, v.View))
+}
+
+// DeleteView removes a view. It returns ErrNotFound when there is none.
+func (s *Storage) DeleteView(ctx context.Context, file, access, view string) error {
+	const query = `DELETE FROM viewsfile WHERE file = $1 AND access = $2 AND view = $3`
+	ctx, q := s.startQuery(ctx, "delete view", query, file, access, view)
+	res, err := s.conn.ExecContext(ctx, query, file, access, view)
+	return q.endExec(res, affected(ctx, res, err, "delete view %s %s %s", file, access, view))
+}
+
+// affected wraps the error of a statement changing a single row, or
+// ErrNotFound when it changed none.
+func affected(ctx context.Context, res sql.Result, err error, format string, args ...any) error {
+	if err != nil {
+		return fmt.Errorf(format+": %w", append(args, dbError(ctx, err))...)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf(format+": %w", append(args, err)...)
+	}
+	if n == 0 {
+		return fmt.Errorf(format+": %w", append(args, ErrNotFound)...)
+	}
+	return nil
+}
+
+// ListViews returns a page of the views matching f, ordered by file,
+// access and position. A page past the end is empty.
+func (s *Storage) ListViews(ctx context.Context, f ViewFilter, p Page) ([]ViewsFile, error) {
+	offset, limit := p.bounds()
+	return s.queryViews(ctx, "list views", "list views", `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE ($1 = '' OR file = $1) AND ($2 = '' OR access = $2)
+		ORDER BY file, access, position, view LIMIT $3 OFFSET $4`, f.File, f.Access, limit, offset)
+}
+
+// ViewsByName returns the views named view in every file and access. It
+// returns ErrNotFound when there are none.
+func (s *Storage) ViewsByName(ctx context.Context, view string) ([]ViewsFile, error) {
+	views, err := s.queryViews(ctx, "views by name", "views named "+view, `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE view = $1 ORDER BY file, access, position`, view)
+	if err == nil && len(views) == 0 {
+		err = fmt.Errorf("views named %s: %w", view, ErrNotFound)
+	}
+	return views, err
+}
+
+// ViewsByPath returns the views going through path. It returns ErrNotFound
+// when there are none.
+func (s *Storage) ViewsByPath(ctx context.Context, path string) ([]ViewsFile, error) {
+	views, err := s.queryViews(ctx, "views by path", "views of path "+path, `SELECT file, view, access, path, position, pathfile FROM viewsfile
+		WHERE path = $1 ORDER BY file, access, position, view`, path)
+	if err == nil && len(views) == 0 {
+		err = fmt.Errorf("views of path %s: %w", path, ErrNotFound)
+	}
+	return views, err
+}
+
+// queryViews returns the views of query, traced as op. Its errors start
+// with what.
+func (s *Storage) queryViews(ctx context.Context, op, what, query string, args ...any) (views []ViewsFile, err error) {
+	ctx, q := s.startQuery(ctx, op, query, args...)
+	defer func() { q.end(int64(len(views)), err) }()
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", what, dbError(ctx, err))
+	}
+	defer rows.Close()
+	views = []ViewsFile{}
+	for rows.Next() {
+		var v ViewsFile
+		if err := rows.Scan(&v.File, &v.View, &v.Access, &v.Path, &v.Order, &v.Pathfile); err != nil {
+			return nil, fmt.Errorf("%s: %w", what, err)
+		}
+		views = append(views, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", what, dbError(ctx, err))
+	}
+	return views, nil
+}
+
+// MoveView moves view to position to among the views of its file and
+// access, see Reorder, and renumbers them all in one transaction.
+func (s *Storage) MoveView(ctx context.Context, key ViewKey, view string, to int) error {
+	return s.WithTx(ctx, func(tx *Tx) error {
+		views, err := tx.queryViews(ctx, "move view", "move view "+view, `SELECT file, view, access, path, position, pathfile FROM viewsfile
+			WHERE file = $1 AND access = $2 ORDER BY position, view FOR UPDATE`, key.File, key.Access)
+		if err != nil {
+			return err
+		}
+		moved, err := Reorder(views, view, to)
+		if err != nil {
+			return fmt.Errorf("move view of %s %s: %w", key.File, key.Access, err)
+		}
+		for i, v := range moved {
+			if v.Order == views[i].Order && v.View == views[i].View {
+				continue
+			}
+			const query = `UPDATE viewsfile SET position = $4 WHERE file = $1 AND access = $2 AND view = $3`
+			ctx, q := tx.startQuery(ctx, "move view", query, v.File, v.Access, v.View, v.Order)
+			res, err := tx.conn.ExecContext(ctx, query, v.File, v.Access, v.View, v.Order)
+			if err != nil {
+				err = fmt.Errorf("move view %s: %w", view, dbError(ctx, err))
+			}
+			if err := q.endExec(res, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
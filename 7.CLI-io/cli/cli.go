@@ -0,0 +1,247 @@
+// Package cli is a small subcommand framework on top of the standard flag
+// package. Every Command owns its own flag.FlagSet, can declare groups of
+// mutually exclusive flags, and gets generated help text and shell
+// completion for free.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Command is a node in the command tree. A command with subcommands and no
+// Run func only dispatches; a leaf command must have a Run func.
+type Command struct {
+	// Name is the word typed on the command line, e.g. "get".
+	Name string
+	// Args describes the positional arguments in the usage line, e.g. "[author...]".
+	Args string
+	// Short is the one line description shown in the parent's command list.
+	Short string
+	// Long is the optional longer description shown in the command's own help.
+	Long string
+	// Run is called with the positional arguments left after flag parsing.
+	Run func(ctx context.Context, args []string) error
+
+	// Stdout and Stderr default to os.Stdout and os.Stderr when nil.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	flags    *flag.FlagSet
+	groups   []group
+	commands []*Command
+	parent   *Command
+}
+
+// group is a set of mutually exclusive flags. When required is set exactly
+// one of them must be given, otherwise at most one.
+type group struct {
+	names    []string
+	required bool
+}
+
+// UsageError is returned when the command line itself is wrong, as opposed
+// to the command failing while running.
+type UsageError struct {
+	Cmd *Command
+	Err error
+}
+
+// Error implements error.Error().
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Cmd.Path(), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// Flags returns the command's flag set, creating it on first use.
+func (c *Command) Flags() *flag.FlagSet {
+	if c.flags == nil {
+		c.flags = flag.NewFlagSet(c.Name, flag.ContinueOnError)
+		// we print our own usage and errors
+		c.flags.SetOutput(io.Discard)
+		c.flags.Usage = func() {}
+	}
+	return c.flags
+}
+
+// Exclusive declares that at most one of the named flags may be set. If
+// required is true, exactly one of them must be set.
+func (c *Command) Exclusive(required bool, names ...string) {
+	c.groups = append(c.groups, group{names: names, required: required})
+}
+
+// Add attaches subcommands to c and returns c so trees can be built inline.
+func (c *Command) Add(cmds ...*Command) *Command {
+	for _, sub := range cmds {
+		sub.parent = c
+		c.commands = append(c.commands, sub)
+	}
+	return c
+}
+
+// Commands returns the direct subcommands of c.
+func (c *Command) Commands() []*Command {
+	return c.commands
+}
+
+// Lookup returns the direct subcommand called name, or nil.
+func (c *Command) Lookup(name string) *Command {
+	for _, sub := range c.commands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Path returns the full command path, e.g. "goops qotd get".
+func (c *Command) Path() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.Path() + " " + c.Name
+}
+
+// Root returns the top of the command tree.
+func (c *Command) Root() *Command {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// OutOrStdout returns the writer the command should print results to.
+func (c *Command) OutOrStdout() io.Writer {
+	for p := c; p != nil; p = p.parent {
+		if p.Stdout != nil {
+			return p.Stdout
+		}
+	}
+	return os.Stdout
+}
+
+// ErrOrStderr returns the writer the command should print diagnostics to.
+func (c *Command) ErrOrStderr() io.Writer {
+	for p := c; p != nil; p = p.parent {
+		if p.Stderr != nil {
+			return p.Stderr
+		}
+	}
+	return os.Stderr
+}
+
+// Execute parses args against c, walks down to the selected subcommand and
+// runs it. args must not include the program name.
+func (c *Command) Execute(ctx context.Context, args []string) error {
+	fs := c.Flags()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			c.Help(c.OutOrStdout())
+			return nil
+		}
+		return &UsageError{Cmd: c, Err: err}
+	}
+	rest := fs.Args()
+
+	if len(c.commands) > 0 && len(rest) > 0 {
+		if rest[0] == "help" {
+			return c.help(rest[1:])
+		}
+		if sub := c.Lookup(rest[0]); sub != nil {
+			return sub.Execute(ctx, rest[1:])
+		}
+		if c.Run == nil {
+			return &UsageError{Cmd: c, Err: fmt.Errorf("unknown command %q", rest[0])}
+		}
+	}
+
+	if err := c.checkGroups(); err != nil {
+		return &UsageError{Cmd: c, Err: err}
+	}
+	if c.Run == nil {
+		return &UsageError{Cmd: c, Err: errors.New("missing command")}
+	}
+	return c.Run(ctx, rest)
+}
+
+// help prints the help of the subcommand named by path.
+func (c *Command) help(path []string) error {
+	target := c
+	for _, name := range path {
+		sub := target.Lookup(name)
+		if sub == nil {
+			return &UsageError{Cmd: target, Err: fmt.Errorf("unknown command %q", name)}
+		}
+		target = sub
+	}
+	target.Help(c.OutOrStdout())
+	return nil
+}
+
+// checkGroups validates the mutually exclusive flag groups against the
+// flags that were actually set on the command line.
+func (c *Command) checkGroups() error {
+	set := map[string]bool{}
+	c.Flags().Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, g := range c.groups {
+		var given []string
+		for _, n := range g.names {
+			if set[n] {
+				given = append(given, "-"+n)
+			}
+		}
+		switch {
+		case len(given) > 1:
+			return fmt.Errorf("%s cannot be set together", strings.Join(given, " and "))
+		case len(given) == 0 && g.required:
+			return fmt.Errorf("one of %s must be set", dashed(g.names))
+		}
+	}
+	return nil
+}
+
+// dashed renders flag names as "-a, -b or -c".
+func dashed(names []string) string {
+	d := make([]string, len(names))
+	for i, n := range names {
+		d[i] = "-" + n
+	}
+	if len(d) == 1 {
+		return d[0]
+	}
+	return strings.Join(d[:len(d)-1], ", ") + " or " + d[len(d)-1]
+}
+
+// Main runs root with the process arguments and exits. Usage errors print
+// the relevant help and exit with 2, failures while running exit with 1.
+// The context passed to commands is cancelled on SIGINT.
+func Main(root *Command) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := root.Execute(ctx, os.Args[1:])
+	stop()
+
+	var uerr *UsageError
+	switch {
+	case err == nil:
+		return
+	case errors.As(err, &uerr):
+		fmt.Fprintln(root.ErrOrStderr(), "Error:", uerr)
+		fmt.Fprintln(root.ErrOrStderr())
+		uerr.Cmd.Help(root.ErrOrStderr())
+		os.Exit(2)
+	default:
+		fmt.Fprintln(root.ErrOrStderr(), "Error:", err)
+		os.Exit(1)
+	}
+}
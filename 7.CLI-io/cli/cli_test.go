@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tree returns "prog" with a "get" subcommand, the arguments get ran with
+// being stored in ran.
+func tree(ran *[]string) (*Command, *Command) {
+	get := &Command{Name: "get", Args: "[author...]", Short: "Get quotes"}
+	get.Flags().Int("n", 1, "Number of quotes")
+	get.Flags().Bool("json", false, "Print JSON")
+	get.Flags().Bool("yaml", false, "Print YAML")
+	get.Exclusive(false, "json", "yaml")
+	get.Run = func(ctx context.Context, args []string) error {
+		*ran = append([]string{}, args...)
+		return nil
+	}
+	root := &Command{Name: "prog", Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	root.Flags().Bool("v", false, "Verbose")
+	root.Add(get, CompletionCommand())
+	return root, get
+}
+
+func TestExecute(t *testing.T) {
+	var ran []string
+	root, get := tree(&ran)
+	if err := root.Execute(context.Background(), []string{"-v", "get", "-n", "3", "ada", "alan"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ran, " ") != "ada alan" {
+		t.Errorf("args: got %q", ran)
+	}
+	if n := get.Flags().Lookup("n").Value.String(); n != "3" {
+		t.Errorf("-n: got %s, want 3", n)
+	}
+	if get.Path() != "prog get" || get.Root() != root {
+		t.Errorf("path: got %q", get.Path())
+	}
+
+	for _, args := range [][]string{
+		{"put"},
+		{},
+		{"get", "-m"},
+		{"get", "-n", "x"},
+		{"help", "put"},
+		{"completion"},
+	} {
+		var uerr *UsageError
+		if err := root.Execute(context.Background(), args); !errors.As(err, &uerr) {
+			t.Errorf("%q: got %v, want a UsageError", args, err)
+		}
+	}
+
+	out := root.Stdout.(*bytes.Buffer)
+	out.Reset()
+	if err := root.Execute(context.Background(), []string{"help", "get"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"prog get [flags] [author...]", "-n int", "At most one of -json or -yaml may be set."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("help does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	for _, tc := range []struct {
+		required bool
+		args     []string
+		want     string
+	}{
+		{false, nil, ""},
+		{false, []string{"-json"}, ""},
+		{false, []string{"-json", "-yaml"}, "-json and -yaml cannot be set together"},
+		{true, []string{"-csv"}, ""},
+		{true, nil, "one of -json, -yaml or -csv must be set"},
+		{true, []string{"-json=false"}, ""},
+	} {
+		ran := false
+		cmd := &Command{Name: "get", Run: func(context.Context, []string) error { ran = true; return nil }}
+		for _, name := range []string{"json", "yaml", "csv"} {
+			cmd.Flags().Bool(name, false, "")
+		}
+		cmd.Exclusive(tc.required, "json", "yaml", "csv")
+		err := cmd.Execute(context.Background(), tc.args)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%v %q: %v", tc.required, tc.args, err)
+		case tc.want != "" && (err == nil || !strings.HasSuffix(err.Error(), tc.want)):
+			t.Errorf("%v %q: got %v, want %s", tc.required, tc.args, err, tc.want)
+		case ran != (tc.want == ""):
+			t.Errorf("%v %q: ran is %v", tc.required, tc.args, ran)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	var ran []string
+	root, _ := tree(&ran)
+	for shell, want := range map[string][]string{
+		"bash": {
+			"complete -F _prog prog",
+			`"get"|"completion") cmdpath="$next" ;;`,
+			`"") COMPREPLY=($(compgen -W "get completion -v" -- "$cur")) ;;`,
+			`"get") COMPREPLY=($(compgen -W "-json -n -yaml" -- "$cur")) ;;`,
+		},
+		"zsh": {
+			"#compdef prog",
+			"'get:Get quotes'",
+			"'-n:Number of quotes'",
+			"compdef _prog prog",
+		},
+		"fish": {
+			`complete -c prog -n '__prog_at ""' -a get -d "Get quotes"`,
+			`complete -c prog -n '__prog_at "get"' -o json -d "Print JSON"`,
+		},
+	} {
+		out := root.Stdout.(*bytes.Buffer)
+		out.Reset()
+		if err := root.Execute(context.Background(), []string{"completion", shell}); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		for _, w := range want {
+			if !strings.Contains(out.String(), w) {
+				t.Errorf("%s does not contain %s:\n%s", shell, w, out)
+			}
+		}
+	}
+	if err := WriteCompletion(&bytes.Buffer{}, root, "tcsh"); err == nil {
+		t.Error("tcsh: no error")
+	}
+	if got := zshQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("zshQuote: got %s", got)
+	}
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CompletionCommand returns a "completion" command that prints the
+// completion script of root's command tree for bash, zsh or fish.
+func CompletionCommand() *Command {
+	cmd := &Command{
+		Name:  "completion",
+		Args:  "bash|zsh|fish",
+		Short: "Print the shell completion script",
+		Long: `Print the shell completion script for bash, zsh or fish.
+Source the output from the shell's startup file, or save it where the
+shell looks for completions.`,
+	}
+	cmd.Run = func(ctx context.Context, args []string) error {
+		if len(args) != 1 {
+			return &UsageError{Cmd: cmd, Err: fmt.Errorf("expected exactly one shell, got %d", len(args))}
+		}
+		return WriteCompletion(cmd.OutOrStdout(), cmd.Root(), args[0])
+	}
+	return cmd
+}
+
+// WriteCompletion writes the completion script for shell ("bash", "zsh" or
+// "fish") covering every command and flag under root.
+func WriteCompletion(w io.Writer, root *Command, shell string) error {
+	nodes := walk(root, "")
+	switch shell {
+	case "bash":
+		writeBash(w, root.Name, nodes)
+	case "zsh":
+		writeZsh(w, root.Name, nodes)
+	case "fish":
+		writeFish(w, root.Name, nodes)
+	default:
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", shell)
+	}
+	return nil
+}
+
+// node is a flattened view of one command for the script generators. path
+// is the space separated list of subcommand names below the root.
+type node struct {
+	path  string
+	subs  []*Command
+	flags []*flag.Flag
+}
+
+func walk(c *Command, path string) []node {
+	n := node{path: path, subs: c.commands}
+	c.Flags().VisitAll(func(f *flag.Flag) { n.flags = append(n.flags, f) })
+	nodes := []node{n}
+	for _, sub := range c.commands {
+		nodes = append(nodes, walk(sub, strings.TrimSpace(path+" "+sub.Name))...)
+	}
+	return nodes
+}
+
+// words lists what can follow a command: its subcommands then its flags.
+func (n node) words() string {
+	var w []string
+	for _, s := range n.subs {
+		w = append(w, s.Name)
+	}
+	for _, f := range n.flags {
+		w = append(w, "-"+f.Name)
+	}
+	return strings.Join(w, " ")
+}
+
+// ident turns the program name into something usable as a shell function name.
+func ident(prog string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, prog)
+}
+
+// paths returns the quoted case pattern matching every known command path.
+func paths(nodes []node) string {
+	var p []string
+	for _, n := range nodes[1:] {
+		p = append(p, fmt.Sprintf("%q", n.path))
+	}
+	return strings.Join(p, "|")
+}
+
+func writeBash(w io.Writer, prog string, nodes []node) {
+	fn := "_" + ident(prog)
+	fmt.Fprintf(w, "# bash completion for %s\n", prog)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" cmdpath=\"\" next i\n")
+	fmt.Fprintf(w, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "        next=\"${cmdpath:+$cmdpath }${COMP_WORDS[i]}\"\n")
+	if len(nodes) > 1 {
+		fmt.Fprintf(w, "        case \"$next\" in\n")
+		fmt.Fprintf(w, "            %s) cmdpath=\"$next\" ;;\n", paths(nodes))
+		fmt.Fprintf(w, "        esac\n")
+	}
+	fmt.Fprintf(w, "    done\n")
+	fmt.Fprintf(w, "    case \"$cmdpath\" in\n")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "        %q) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", n.path, n.words())
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    if [[ ${#COMPREPLY[@]} -eq 0 ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -F %s %s\n", fn, prog)
+}
+
+func writeZsh(w io.Writer, prog string, nodes []node) {
+	fn := "_" + ident(prog)
+	fmt.Fprintf(w, "#compdef %s\n\n", prog)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local cmdpath=\"\" next i\n")
+	fmt.Fprintf(w, "    for ((i = 2; i < CURRENT; i++)); do\n")
+	fmt.Fprintf(w, "        next=\"${cmdpath:+$cmdpath }${words[i]}\"\n")
+	if len(nodes) > 1 {
+		fmt.Fprintf(w, "        case \"$next\" in\n")
+		fmt.Fprintf(w, "            %s) cmdpath=\"$next\" ;;\n", paths(nodes))
+		fmt.Fprintf(w, "        esac\n")
+	}
+	fmt.Fprintf(w, "    done\n")
+	fmt.Fprintf(w, "    case \"$cmdpath\" in\n")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "        %q)\n", n.path)
+		if len(n.subs)+len(n.flags) > 0 {
+			fmt.Fprintf(w, "            local -a entries=(\n")
+			for _, s := range n.subs {
+				fmt.Fprintf(w, "                %s\n", zshQuote(s.Name+":"+s.Short))
+			}
+			for _, f := range n.flags {
+				fmt.Fprintf(w, "                %s\n", zshQuote("-"+f.Name+":"+f.Usage))
+			}
+			fmt.Fprintf(w, "            )\n")
+			fmt.Fprintf(w, "            _describe command entries\n")
+		}
+		if len(n.subs) == 0 {
+			fmt.Fprintf(w, "            _files\n")
+		}
+		fmt.Fprintf(w, "            ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "compdef %s %s\n", fn, prog)
+}
+
+func zshQuote(s string) string {
+	return "'" + zshEscape(s) + "'"
+}
+
+func zshEscape(s string) string {
+	return strings.ReplaceAll(s, "'", `'\''`)
+}
+
+func writeFish(w io.Writer, prog string, nodes []node) {
+	fn := "__" + ident(prog)
+	var all []string
+	for _, n := range nodes[1:] {
+		all = append(all, fmt.Sprintf("%q", n.path))
+	}
+	fmt.Fprintf(w, "# fish completion for %s\n", prog)
+	fmt.Fprintf(w, "function %s_path\n", fn)
+	fmt.Fprintf(w, "    set -l known %s\n", strings.Join(all, " "))
+	fmt.Fprintf(w, "    set -l p \"\"\n")
+	fmt.Fprintf(w, "    for t in (commandline -opc)[2..-1]\n")
+	fmt.Fprintf(w, "        set -l next (string trim -- \"$p $t\")\n")
+	fmt.Fprintf(w, "        if contains -- $next $known\n")
+	fmt.Fprintf(w, "            set p $next\n")
+	fmt.Fprintf(w, "        end\n")
+	fmt.Fprintf(w, "    end\n")
+	fmt.Fprintf(w, "    echo $p\n")
+	fmt.Fprintf(w, "end\n\n")
+	fmt.Fprintf(w, "function %s_at\n", fn)
+	fmt.Fprintf(w, "    set -l p (%s_path)\n", fn)
+	fmt.Fprintf(w, "    test \"$p\" = \"$argv[1]\"\n")
+	fmt.Fprintf(w, "end\n\n")
+	for _, n := range nodes {
+		cond := fmt.Sprintf("'%s_at %s'", fn, fishQuote(n.path))
+		if len(n.subs) > 0 {
+			fmt.Fprintf(w, "complete -c %s -n %s -f\n", prog, cond)
+		}
+		for _, s := range n.subs {
+			fmt.Fprintf(w, "complete -c %s -n %s -a %s -d %s\n", prog, cond, s.Name, fishQuote(s.Short))
+		}
+		for _, f := range n.flags {
+			fmt.Fprintf(w, "complete -c %s -n %s -o %s -d %s\n", prog, cond, f.Name, fishQuote(f.Usage))
+		}
+	}
+}
+
+// fishQuote double quotes s for use inside a single quoted fish condition or
+// as a plain argument.
+func fishQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `'`, ``, "$", `\$`).Replace(s)
+	return `"` + s + `"`
+}
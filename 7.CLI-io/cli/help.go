@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Help writes the generated help text for c to w.
+func (c *Command) Help(w io.Writer) {
+	fmt.Fprintf(w, "Usage:\n  %s\n", c.usageLine())
+
+	if c.Long != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(c.Long))
+	} else if c.Short != "" {
+		fmt.Fprintf(w, "\n%s\n", c.Short)
+	}
+
+	if len(c.commands) > 0 {
+		fmt.Fprintf(w, "\nCommands:\n")
+		tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+		for _, sub := range c.commands {
+			fmt.Fprintf(tw, "  %s\t%s\n", sub.Name, sub.Short)
+		}
+		tw.Flush()
+	}
+
+	if c.hasFlags() {
+		fmt.Fprintf(w, "\nFlags:\n")
+		fs := c.Flags()
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+		fs.SetOutput(io.Discard)
+	}
+
+	for _, g := range c.groups {
+		if g.required {
+			fmt.Fprintf(w, "\nExactly one of %s must be set.\n", dashed(g.names))
+		} else {
+			fmt.Fprintf(w, "\nAt most one of %s may be set.\n", dashed(g.names))
+		}
+	}
+
+	if len(c.commands) > 0 {
+		fmt.Fprintf(w, "\nUse \"%s help <command>\" for more information about a command.\n", c.Path())
+	}
+}
+
+// usageLine builds the synopsis, e.g. "goops qotd get [flags] [author...]".
+func (c *Command) usageLine() string {
+	parts := []string{c.Path()}
+	if c.hasFlags() {
+		parts = append(parts, "[flags]")
+	}
+	if len(c.commands) > 0 {
+		parts = append(parts, "<command>")
+	}
+	if c.Args != "" {
+		parts = append(parts, c.Args)
+	}
+	return strings.Join(parts, " ")
+}
+
+// hasFlags reports whether any flag was defined on c.
+func (c *Command) hasFlags() bool {
+	n := 0
+	c.Flags().VisitAll(func(*flag.Flag) { n++ })
+	return n > 0
+}
@@ -0,0 +1,93 @@
+package flagval
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size flag in bytes that accepts units, e.g. "512", "64KB"
+// or "10MiB". KB, MB, ... are powers of 1000 and KiB, MiB, ... powers of
+// 1024. Units are not case sensitive.
+type ByteSize int64
+
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// ParseByteSize parses a size such as "1.5GiB" into a number of bytes. A
+// size that is not a whole number of bytes, such as "1.5B" or "0.1KiB", is
+// an error rather than being truncated.
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	if num == "" {
+		return 0, fmt.Errorf("size %q does not start with a number", s)
+	}
+	mult, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q, want B, KB, KiB, MB, MiB, GB, GiB, TB, TiB, PB or PiB", s[i:])
+	}
+	// exact arithmetic, so "0.3KB" is 300 bytes and not 299.99...
+	n, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, fmt.Errorf("size %q: invalid number %q", s, num)
+	}
+	n.Mul(n, new(big.Rat).SetInt64(mult))
+	if !n.IsInt() {
+		return 0, fmt.Errorf("size %q is not a whole number of bytes", s)
+	}
+	if !n.Num().IsInt64() {
+		return 0, fmt.Errorf("size %q overflows", s)
+	}
+	return ByteSize(n.Num().Int64()), nil
+}
+
+// String implements flag.Value. It uses the largest binary unit that
+// represents the size exactly, so "10MiB" prints back as "10MiB".
+func (b *ByteSize) String() string {
+	if b == nil {
+		return ""
+	}
+	n := int64(*b)
+	for _, u := range []struct {
+		name string
+		size int64
+	}{{"PiB", 1 << 50}, {"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if n != 0 && n%u.size == 0 {
+			return strconv.FormatInt(n/u.size, 10) + u.name
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// Set implements flag.Value.
+func (b *ByteSize) Set(s string) error {
+	n, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = n
+	return nil
+}
+
+// Get implements flag.Getter.
+func (b *ByteSize) Get() any { return int64(*b) }
@@ -0,0 +1,48 @@
+package flagval
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want ByteSize
+		err  bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"64KB", 64000, false},
+		{"10MiB", 10 << 20, false},
+		{"1.5gib", 3 << 29, false},
+		{" 2 TB ", 2e12, false},
+		{"8191PiB", 8191 << 50, false},
+		{"8192PiB", 0, true},
+		{"9223372036854775807", 1<<63 - 1, false},
+		{"9223372036854775808", 0, true},
+		{"0.3KB", 300, false},
+		{"1.5B", 0, true},
+		{"0.1KiB", 0, true},
+		{".", 0, true},
+		{"1e3", 0, true},
+		{"KB", 0, true},
+		{"", 0, true},
+		{"1.2.3MB", 0, true},
+		{"10 bits", 0, true},
+	} {
+		got, err := ParseByteSize(tc.in)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("%q: got %d, %v, want %d", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	for in, want := range map[string]string{"10MiB": "10MiB", "1000": "1000B", "2048": "2KiB", "0": "0B", "1PiB": "1PiB"} {
+		var b ByteSize
+		if err := b.Set(in); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package flagval provides flag.Value implementations for the typed options
+// our tools take. Each type validates its input in Set, so a bad value is
+// rejected while parsing and the flag package reports it as
+//
+//	invalid value "ftp://x" for flag -url: scheme "ftp" is not allowed, want http or https
+//
+// All values use pointer receivers: register them with fs.Var(&v, ...).
+package flagval
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URL is a URL flag. When Schemes is not empty the scheme must be one of
+// them, and RequireHost rejects URLs without a host such as "localhost:80"
+// which url.Parse reads as scheme "localhost".
+type URL struct {
+	URL         *url.URL
+	Schemes     []string
+	RequireHost bool
+}
+
+// String implements flag.Value.
+func (v *URL) String() string {
+	if v == nil || v.URL == nil {
+		return ""
+	}
+	return v.URL.String()
+}
+
+// Set implements flag.Value.
+func (v *URL) Set(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if len(v.Schemes) > 0 && !slices.Contains(v.Schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("scheme %q is not allowed, want %s", u.Scheme, oneOf(v.Schemes))
+	}
+	if v.RequireHost && u.Host == "" {
+		return errors.New("URL has no host")
+	}
+	v.URL = u
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *URL) Get() any { return v.URL }
+
+// HostPort is a "host:port" flag. The host may be empty to mean every
+// interface. When DefaultPort is set a bare host is accepted and gets it.
+type HostPort struct {
+	Host        string
+	Port        int
+	DefaultPort int
+}
+
+// String implements flag.Value.
+func (v *HostPort) String() string {
+	if v == nil || (v.Host == "" && v.Port == 0) {
+		return ""
+	}
+	return net.JoinHostPort(v.Host, strconv.Itoa(v.Port))
+}
+
+// Set implements flag.Value.
+func (v *HostPort) Set(s string) error {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		if v.DefaultPort == 0 || strings.Contains(s, ":") {
+			return fmt.Errorf("want host:port: %w", err)
+		}
+		host, port = s, strconv.Itoa(v.DefaultPort)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("port %q is not a number between 1 and 65535", port)
+	}
+	v.Host, v.Port = host, p
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *HostPort) Get() any { return v.String() }
+
+// Duration is a time.Duration flag bounded by Min and Max. A zero bound is
+// not checked.
+type Duration struct {
+	D   time.Duration
+	Min time.Duration
+	Max time.Duration
+}
+
+// String implements flag.Value.
+func (v *Duration) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.D.String()
+}
+
+// Set implements flag.Value.
+func (v *Duration) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v.Min != 0 && d < v.Min {
+		return fmt.Errorf("%v is shorter than the minimum %v", d, v.Min)
+	}
+	if v.Max != 0 && d > v.Max {
+		return fmt.Errorf("%v is longer than the maximum %v", d, v.Max)
+	}
+	v.D = d
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *Duration) Get() any { return v.D }
+
+// Choice is a string flag restricted to one of Choices.
+type Choice struct {
+	Value   string
+	Choices []string
+}
+
+// String implements flag.Value.
+func (v *Choice) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.Value
+}
+
+// Set implements flag.Value.
+func (v *Choice) Set(s string) error {
+	if !slices.Contains(v.Choices, s) {
+		return fmt.Errorf("want %s", oneOf(v.Choices))
+	}
+	v.Value = s
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *Choice) Get() any { return v.Value }
+
+// List is a comma separated list flag. The flag can be repeated, values
+// accumulate; the first use replaces any default.
+type List struct {
+	Values []string
+	set    bool
+}
+
+// String implements flag.Value.
+func (v *List) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(v.Values, ",")
+}
+
+// Set implements flag.Value.
+func (v *List) Set(s string) error {
+	if !v.set {
+		v.Values, v.set = nil, true
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			v.Values = append(v.Values, item)
+		}
+	}
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *List) Get() any { return v.Values }
+
+// Strings is a repeatable string flag. Unlike List each use adds exactly
+// one value, commas included, which suits flags such as regexps.
+type Strings struct {
+	Values []string
+	set    bool
+}
+
+// String implements flag.Value.
+func (v *Strings) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(v.Values, " ")
+}
+
+// Set implements flag.Value.
+func (v *Strings) Set(s string) error {
+	if !v.set {
+		v.Values, v.set = nil, true
+	}
+	v.Values = append(v.Values, s)
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *Strings) Get() any { return v.Values }
+
+// Map is a "key=value,key2=value2" flag. The flag can be repeated, later
+// keys override earlier ones; the first use replaces any default.
+type Map struct {
+	M   map[string]string
+	set bool
+}
+
+// String implements flag.Value.
+func (v *Map) String() string {
+	if v == nil || len(v.M) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(v.M))
+	for k := range v.M {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + v.M[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value.
+func (v *Map) Set(s string) error {
+	if !v.set || v.M == nil {
+		v.M, v.set = map[string]string{}, true
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("%q is not key=value", pair)
+		}
+		v.M[strings.TrimSpace(k)] = strings.TrimSpace(val)
+	}
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *Map) Get() any { return v.M }
+
+// PathKind says what an existing Path must be.
+type PathKind int
+
+const (
+	AnyPath PathKind = iota
+	FilePath
+	DirPath
+)
+
+// Path is a flag for a path that must exist when the flag is parsed, and be
+// a regular file or a directory depending on Kind.
+type Path struct {
+	Path string
+	Kind PathKind
+}
+
+// String implements flag.Value.
+func (v *Path) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.Path
+}
+
+// Set implements flag.Value.
+func (v *Path) Set(s string) error {
+	fi, err := os.Stat(s)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%s does not exist", s)
+	case err != nil:
+		return err
+	case v.Kind == FilePath && !fi.Mode().IsRegular():
+		return fmt.Errorf("%s is not a regular file", s)
+	case v.Kind == DirPath && !fi.IsDir():
+		return fmt.Errorf("%s is not a directory", s)
+	}
+	v.Path = s
+	return nil
+}
+
+// Get implements flag.Getter.
+func (v *Path) Get() any { return v.Path }
+
+// oneOf renders choices as "a, b or c".
+func oneOf(choices []string) string {
+	if len(choices) == 1 {
+		return choices[0]
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
@@ -0,0 +1,154 @@
+package flagval
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setCase is an input of Set, with the String it gives or the start of
+// its error.
+type setCase struct {
+	in, want, err string
+}
+
+func testSet(t *testing.T, name string, v flag.Value, cases []setCase) {
+	t.Helper()
+	for _, tc := range cases {
+		err := v.Set(tc.in)
+		switch {
+		case tc.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.err)):
+			t.Errorf("%s %q: got %v, want %s", name, tc.in, err, tc.err)
+		case tc.err == "" && err != nil:
+			t.Errorf("%s %q: %v", name, tc.in, err)
+		case tc.err == "" && v.String() != tc.want:
+			t.Errorf("%s %q: String is %q, want %q", name, tc.in, v.String(), tc.want)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	testSet(t, "URL", &URL{Schemes: []string{"http", "https"}, RequireHost: true}, []setCase{
+		{in: "https://example.org/a?b=c", want: "https://example.org/a?b=c"},
+		{in: "HTTP://example.org", want: "http://example.org"},
+		{in: "ftp://x", err: `scheme "ftp" is not allowed, want http or https`},
+		{in: "localhost:80", err: `scheme "localhost" is not allowed`},
+		{in: "http://", err: "URL has no host"},
+		{in: "http://a b", err: "parse"},
+	})
+	testSet(t, "URL", &URL{}, []setCase{{in: "localhost:80", want: "localhost:80"}})
+	var u *URL
+	if u.String() != "" || (&URL{}).String() != "" {
+		t.Error("String of a nil URL is not empty")
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	testSet(t, "HostPort", &HostPort{}, []setCase{
+		{in: "db:5432", want: "db:5432"},
+		{in: ":80", want: ":80"},
+		{in: "[::1]:443", want: "[::1]:443"},
+		{in: "db", err: "want host:port"},
+		{in: "db:0", err: `port "0" is not a number between 1 and 65535`},
+		{in: "db:65536", err: `port "65536"`},
+		{in: "db:http", err: `port "http"`},
+	})
+	testSet(t, "HostPort", &HostPort{DefaultPort: 5432}, []setCase{
+		{in: "db", want: "db:5432"},
+		{in: "db:6432", want: "db:6432"},
+		{in: "db:", err: `port ""`},
+	})
+	if (&HostPort{}).String() != "" {
+		t.Error("String of an unset HostPort is not empty")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	v := &Duration{Min: time.Second, Max: time.Hour}
+	testSet(t, "Duration", v, []setCase{
+		{in: "90s", want: "1m30s"},
+		{in: "1h", want: "1h0m0s"},
+		{in: "500ms", err: "500ms is shorter than the minimum 1s"},
+		{in: "2h", err: "2h0m0s is longer than the maximum 1h0m0s"},
+		{in: "10", err: "time: missing unit"},
+	})
+	if v.D != time.Hour {
+		t.Errorf("a rejected value changed D to %v", v.D)
+	}
+	testSet(t, "Duration", &Duration{}, []setCase{{in: "-5m", want: "-5m0s"}})
+}
+
+func TestChoice(t *testing.T) {
+	testSet(t, "Choice", &Choice{Value: "json", Choices: []string{"json", "yaml", "csv"}}, []setCase{
+		{in: "yaml", want: "yaml"},
+		{in: "YAML", err: "want json, yaml or csv"},
+		{in: "", err: "want json, yaml or csv"},
+	})
+	testSet(t, "Choice", &Choice{Choices: []string{"on"}}, []setCase{{in: "off", err: "want on"}})
+}
+
+func TestList(t *testing.T) {
+	v := &List{Values: []string{"default"}}
+	// the first use replaces the default, the next ones add to it
+	testSet(t, "List", v, []setCase{
+		{in: "a, b,,c", want: "a,b,c"},
+		{in: "d", want: "a,b,c,d"},
+		{in: " , ", want: "a,b,c,d"},
+	})
+
+	s := &Strings{Values: []string{"default"}}
+	testSet(t, "Strings", s, []setCase{
+		{in: "a,b", want: "a,b"},
+		{in: "c d", want: "a,b c d"},
+	})
+	if len(s.Values) != 2 {
+		t.Errorf("Strings: got %q, want 2 values", s.Values)
+	}
+}
+
+func TestMap(t *testing.T) {
+	v := &Map{M: map[string]string{"default": "x"}}
+	testSet(t, "Map", v, []setCase{
+		{in: "b=2, a = 1", want: "a=1,b=2"},
+		{in: "a=3,c=", want: "a=3,b=2,c="},
+		{in: "d", err: `"d" is not key=value`},
+		{in: "=4", err: `"=4" is not key=value`},
+	})
+	testSet(t, "Map", &Map{}, []setCase{{in: "k=v=w", want: "k=v=w"}})
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	testSet(t, "Path", &Path{}, []setCase{
+		{in: dir, want: dir},
+		{in: file, want: file},
+		{in: missing, err: missing + " does not exist"},
+	})
+	testSet(t, "Path", &Path{Kind: FilePath}, []setCase{
+		{in: file, want: file},
+		{in: dir, err: dir + " is not a regular file"},
+	})
+	testSet(t, "Path", &Path{Kind: DirPath}, []setCase{
+		{in: dir, want: dir},
+		{in: file, err: file + " is not a directory"},
+	})
+}
+
+func TestFlagSet(t *testing.T) {
+	fs := flag.NewFlagSet("prog", flag.ContinueOnError)
+	fs.SetOutput(&strings.Builder{})
+	u := &URL{Schemes: []string{"https"}}
+	fs.Var(u, "url", "")
+	err := fs.Parse([]string{"-url", "ftp://x"})
+	if want := `invalid value "ftp://x" for flag -url: scheme "ftp" is not allowed, want https`; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"formats/convert"
+	"github.com/MoadHar/go_ops/7.CLI-io/cli"
+	"github.com/MoadHar/go_ops/7.CLI-io/flagval"
+)
+
+func convertCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "convert",
+		Args:  "[file]",
+		Short: "Convert records between CSV, JSON, JSON Lines, YAML, TOML and XML",
+		Long: `Reads file, or STDIN when it is missing or "-", and writes it in the -to
+format. The formats default to the extensions of file and -o.
+
+Nested objects become dotted CSV columns, "address.city", and back; a
+record holding an array cannot be written as CSV. CSV columns are typed
+from their values unless -raw-csv is given. With -stream the records are
+converted as they are read, the CSV columns being inferred from the first
+-sample records.`,
+	}
+	var formats []string
+	for _, f := range convert.Formats {
+		formats = append(formats, string(f))
+	}
+	from := &flagval.Choice{Choices: formats}
+	to := &flagval.Choice{Choices: formats}
+	cmd.Flags().Var(from, "from", "Input `format` (default: by the extension of file)")
+	cmd.Flags().Var(to, "to", "Output `format` (default: by the extension of -o)")
+	out := cmd.Flags().String("o", "", "Write to `path` instead of STDOUT")
+	stream := cmd.Flags().Bool("stream", false, "Convert the records as they are read, for large inputs")
+	sample := cmd.Flags().Int("sample", convert.DefaultSample, "Infer the CSV columns from the first `n` records with -stream")
+	delim := cmd.Flags().String("delimiter", ",", "CSV field `separator`, a single character or \"tab\"")
+	raw := cmd.Flags().Bool("raw-csv", false, "Read the CSV fields as strings")
+	schema := cmd.Flags().Bool("schema", false, "Print the inferred fields and their types instead of converting")
+	cmd.Run = func(ctx context.Context, args []string) error {
+		if len(args) > 1 {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("expected at most one file")}
+		}
+		in := "-"
+		if len(args) == 1 {
+			in = args[0]
+		}
+		fromFormat, err := formatFlag(cmd, from, "-from", in)
+		if err != nil {
+			return err
+		}
+		opts := convert.Options{Stream: *stream, Sample: *sample, RawCSV: *raw}
+		if opts.Comma, err = comma(*delim); err != nil {
+			return &cli.UsageError{Cmd: cmd, Err: err}
+		}
+
+		var r io.Reader = os.Stdin
+		if in != "-" {
+			f, err := os.Open(in)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		if *schema {
+			s, err := convert.InferSchema(r, fromFormat, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(cmd.OutOrStdout(), s)
+			return nil
+		}
+
+		outPath := *out
+		if outPath == "" {
+			outPath = "-"
+		}
+		toFormat, err := formatFlag(cmd, to, "-to", outPath)
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		var f *os.File
+		if *out != "" {
+			if f, err = os.Create(*out); err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := convert.Convert(w, toFormat, r, fromFormat, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "%d records\n", n)
+		if f != nil {
+			return f.Close()
+		}
+		return nil
+	}
+	return cmd
+}
+
+// formatFlag returns the format of flag, or the one of the extension of
+// path when the flag is not set.
+func formatFlag(cmd *cli.Command, flag *flagval.Choice, name, path string) (convert.Format, error) {
+	if flag.Value != "" {
+		return convert.Format(flag.Value), nil
+	}
+	if path == "-" {
+		return "", &cli.UsageError{Cmd: cmd, Err: fmt.Errorf("%s is needed to convert STDIN or STDOUT", name)}
+	}
+	f, err := convert.FormatOf(path)
+	if err != nil {
+		return "", &cli.UsageError{Cmd: cmd, Err: fmt.Errorf("%w, set %s", err, name)}
+	}
+	return f, nil
+}
+
+// comma returns the CSV separator of the -delimiter flag.
+func comma(s string) (rune, error) {
+	if s == "tab" {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("-delimiter %q: want a single character other than a quote or a newline", s)
+	}
+	return r, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"github.com/MoadHar/go_ops/7.CLI-io/cli"
+)
+
+func dbCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "db",
+		Short: "Manage the schema of the views database",
+		Long: `The schema is versioned by the migrations built into goops. On a fresh
+database, such as the one of 6.remote-data/docker-compose.yaml, run
+"goops db up -db <url>" to create the tables.
+
+Instead of -db, the database can be given by GOOPS_DB_URL or the PGHOST,
+PGPORT, PGUSER, PGPASSWORD, PGDATABASE and PGSSLMODE variables, with the
+password looked up in PGPASSFILE or ~/.pgpass when missing. The pool is
+sized by GOOPS_DB_MAX_OPEN_CONNS, GOOPS_DB_MAX_IDLE_CONNS,
+GOOPS_DB_CONN_MAX_LIFETIME and GOOPS_DB_CONN_MAX_IDLE_TIME, and the
+database is waited for up to GOOPS_DB_CONNECT_TIMEOUT, 30s by default.
+
+The queries of "goops views" and "goops qotd serve" taking
+GOOPS_DB_SLOW_QUERY or more, such as 200ms, are logged to stderr with
+their arguments redacted. With GOOPS_DB_TRACE set to a file, or "-" for
+stdout, every query is exported there as an OpenTelemetry span in JSON.`,
+	}
+	return cmd.Add(dbUpCmd(), dbDownCmd(), dbStatusCmd())
+}
+
+// errNoDB is returned, in a usage error, by openDB when no database is
+// configured.
+var errNoDB = errors.New("-db, GOOPS_DB_URL or PGHOST is required")
+
+// dbFlag adds the -db flag to cmd and returns a function opening its
+// database with openDB.
+func dbFlag(cmd *cli.Command, usage string) func(ctx context.Context) (*sql.DB, error) {
+	dsn := cmd.Flags().String("db", "", usage)
+	return func(ctx context.Context) (*sql.DB, error) {
+		return openDB(ctx, cmd, *dsn)
+	}
+}
+
+// openDB opens the database at the url dsn of the -db flag of cmd, or the
+// one of the environment when dsn is empty, see storage.DBConfigFromEnv.
+// The pool is sized from the environment either way.
+func openDB(ctx context.Context, cmd *cli.Command, dsn string) (*sql.DB, error) {
+	cfg, err := storage.DBConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if dsn != "" {
+		// -db replaces the whole connection config of the environment
+		cfg.URL, cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, cfg.SSLMode = dsn, "", 0, "", "", "", ""
+	}
+	if !cfg.Configured() {
+		return nil, &cli.UsageError{Cmd: cmd, Err: errNoDB}
+	}
+	cfg.Logf = logf(cmd)
+	return storage.OpenDB(ctx, cfg)
+}
+
+// newStorage returns the Storage of db, with its queries traced as the
+// environment says, see storage.DBConfig.StorageOptions. The returned
+// function closes it and flushes the spans.
+func newStorage(ctx context.Context, cmd *cli.Command, db *sql.DB) (*storage.Storage, func(), error) {
+	cfg, err := storage.DBConfigFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.Logf = logf(cmd)
+	opts, flush, err := cfg.StorageOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := storage.NewStorage(ctx, db, opts...)
+	if err != nil {
+		flush(context.WithoutCancel(ctx))
+		return nil, nil, err
+	}
+	return store, func() {
+		store.Close()
+		if err := flush(context.WithoutCancel(ctx)); err != nil {
+			fmt.Fprintln(cmd.ErrOrStderr(), "trace:", err)
+		}
+	}, nil
+}
+
+// logf logs a line to the stderr of cmd.
+func logf(cmd *cli.Command) func(format string, args ...any) {
+	return func(format string, args ...any) {
+		fmt.Fprintf(cmd.ErrOrStderr(), format+"\n", args...)
+	}
+}
+
+func dbUpCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "up",
+		Short: "Apply the pending migrations",
+	}
+	open := dbFlag(cmd, "Postgres `url` of the database")
+	to := cmd.Flags().Int("to", 0, "Stop at `version` instead of the latest")
+	cmd.Run = func(ctx context.Context, args []string) error {
+		db, err := open(ctx)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		done, err := storage.MigrateUp(ctx, db, *to)
+		for _, m := range done {
+			fmt.Fprintf(cmd.OutOrStdout(), "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "schema is up to date")
+		}
+		return err
+	}
+	return cmd
+}
+
+func dbDownCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "down",
+		Short: "Revert the last migration, or down to a version",
+	}
+	open := dbFlag(cmd, "Postgres `url` of the database")
+	to := cmd.Flags().Int("to", -1, "Revert the migrations above `version`, 0 for all of them")
+	cmd.Run = func(ctx context.Context, args []string) error {
+		db, err := open(ctx)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		target := *to
+		if target < 0 {
+			applied, _, err := storage.MigrationStatus(ctx, db)
+			if err != nil {
+				return err
+			}
+			if len(applied) < 2 {
+				target = 0
+			} else {
+				target = applied[len(applied)-2].Version
+			}
+		}
+		done, err := storage.MigrateDown(ctx, db, target)
+		for _, m := range done {
+			fmt.Fprintf(cmd.OutOrStdout(), "reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	}
+	return cmd
+}
+
+func dbStatusCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "status",
+		Short: "List the applied and pending migrations",
+	}
+	open := dbFlag(cmd, "Postgres `url` of the database")
+	cmd.Run = func(ctx context.Context, args []string) error {
+		db, err := open(ctx)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		applied, pending, err := storage.MigrationStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		for _, a := range applied {
+			fmt.Fprintf(w, "applied %04d_%s  %s\n", a.Version, a.Name, a.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		for _, m := range pending {
+			fmt.Fprintf(w, "pending %04d_%s\n", m.Version, m.Name)
+		}
+		return nil
+	}
+	return cmd
+}
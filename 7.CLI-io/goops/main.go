@@ -0,0 +1,25 @@
+// goops is the single entry point for our ops tooling. Each tool is a
+// subcommand with its own flags, run "goops help" for the list.
+package main
+
+import (
+	"github.com/MoadHar/go_ops/7.CLI-io/cli"
+	"github.com/MoadHar/go_ops/7.CLI-io/logscan"
+)
+
+func main() {
+	root := &cli.Command{
+		Name:  "goops",
+		Short: "Ops tooling: quotes, log scanning, views files and database, data conversion, tool checks",
+	}
+	root.Add(
+		qotdCmd(),
+		logscan.Command(),
+		viewsCmd(),
+		dbCmd(),
+		convertCmd(),
+		toolsCmd(),
+		cli.CompletionCommand(),
+	)
+	cli.Main(root)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MoadHar/go_ops/6.remote-data/REST/qotd"
+	"github.com/MoadHar/go_ops/6.remote-data/contacts"
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"github.com/MoadHar/go_ops/7.CLI-io/cli"
+	"github.com/MoadHar/go_ops/7.CLI-io/flagval"
+)
+
+const (
+	prodEndpoint = "http://myserver.aws.com"
+	devEndpoint  = "http://127.0.0.1:8009"
+)
+
+func qotdCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "qotd",
+		Short: "Quote of the day client and server",
+	}
+	return cmd.Add(qotdGetCmd(), qotdServeCmd())
+}
+
+func qotdGetCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "get",
+		Args:  "[author...]",
+		Short: "Fetch a quote of the day, one per author or a random one",
+	}
+	fs := cmd.Flags()
+	useProd := fs.Bool("prod", false, "Use a production endpoint")
+	useDev := fs.Bool("dev", false, "Use development endpoint")
+	endpoint := &flagval.URL{Schemes: []string{"http", "https"}, RequireHost: true}
+	fs.Var(endpoint, "url", "Server `URL` to use instead of the -prod or -dev endpoint")
+	timeout := &flagval.Duration{D: 2 * time.Second, Min: time.Millisecond, Max: time.Minute}
+	fs.Var(timeout, "timeout", "Timeout for each request, a `duration` up to 1m")
+	cmd.Exclusive(true, "prod", "dev", "url")
+
+	cmd.Run = func(ctx context.Context, authors []string) error {
+		addr := endpoint.String()
+		switch {
+		case *useProd:
+			addr = prodEndpoint
+		case *useDev:
+			addr = devEndpoint
+		}
+		client, err := qotd.New(addr)
+		if err != nil {
+			return err
+		}
+		if len(authors) == 0 {
+			// an empty author asks the server for a random one
+			authors = []string{""}
+		}
+		for _, author := range authors {
+			reqCtx, cancel := context.WithTimeout(ctx, timeout.D)
+			quote, err := client.Get(reqCtx, author)
+			cancel()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), quote)
+		}
+		return nil
+	}
+	return cmd
+}
+
+func qotdServeCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "serve",
+		Short: "Run the QOTD REST server until interrupted",
+		Long: `With a database, given by -db or the environment as for "goops db", the
+server also serves the contacts under /contacts/v1/ and the statistics of
+its connection pool, with the other expvars, under /debug/vars.`,
+	}
+	fs := cmd.Flags()
+	port := fs.Int("port", 8009, "Port to listen on")
+	open := dbFlag(cmd, "Postgres `url` of the contacts database")
+	cc := fs.String("country-code", "", "Country calling `code` of national phone numbers, such as 33")
+	statsEvery := &flagval.Duration{D: 10 * time.Second, Min: time.Second, Max: time.Hour}
+	fs.Var(statsEvery, "stats-every", "Refresh the pool statistics every `duration`")
+
+	cmd.Run = func(ctx context.Context, args []string) error {
+		serv, err := qotd.NewServer(*port)
+		if err != nil {
+			return err
+		}
+		db, err := open(ctx)
+		switch {
+		case errors.Is(err, errNoDB):
+			// quotes only
+		case err != nil:
+			return err
+		default:
+			defer db.Close()
+			store, closeStore, err := newStorage(ctx, cmd, db)
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+			svc, err := contacts.New(store, *cc)
+			if err != nil {
+				return &cli.UsageError{Cmd: cmd, Err: err}
+			}
+			serv.Handle("/contacts/", contacts.NewHandler(svc))
+			storage.ExportStats(ctx, db, "contacts", statsEvery.D)
+			serv.Handle("/debug/vars", expvar.Handler())
+		}
+		go func() {
+			<-ctx.Done()
+			shutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			serv.Shutdown(shutCtx)
+		}()
+		if err := serv.Start(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/MoadHar/go_ops/7.CLI-io/cli"
+)
+
+// defaultTools are the binaries our automation shells out to.
+var defaultTools = []string{"kubectl", "git"}
+
+func toolsCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "tools",
+		Short: "Helpers around the external tools we depend on",
+	}
+	return cmd.Add(toolsCheckCmd())
+}
+
+func toolsCheckCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "check",
+		Args:  "[tool...]",
+		Short: "Check that the tools are in PATH (default: kubectl and git)",
+	}
+	cmd.Run = func(ctx context.Context, tools []string) error {
+		if len(tools) == 0 {
+			tools = defaultTools
+		}
+		missing := 0
+		for _, tool := range tools {
+			p, err := exec.LookPath(tool)
+			if err != nil {
+				fmt.Fprintf(cmd.OutOrStdout(), "missing  %s: %v\n", tool, err)
+				missing++
+				continue
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "ok       %s: %s\n", tool, p)
+		}
+		if missing > 0 {
+			return fmt.Errorf("%d of %d tools not found in PATH", missing, len(tools))
+		}
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"formats"
+	"github.com/MoadHar/go_ops/6.remote-data/storage"
+	"github.com/MoadHar/go_ops/7.CLI-io/cli"
+	"github.com/MoadHar/go_ops/7.CLI-io/flagval"
+	"streamz"
+)
+
+func viewsCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "views",
+		Short: "Work with FILEVUEP views files",
+	}
+	return cmd.Add(viewsImportCmd(), viewsExportCmd(), viewsDiffCmd())
+}
+
+// viewsSchemas are the FILEVUEP variants known to -schema.
+var viewsSchemas = map[string]streamz.Schema{
+	"whitespace": streamz.Whitespace,
+	"semicolon":  streamz.Semicolon,
+	"tab":        streamz.Tab,
+	"fixed":      streamz.FixedWidth(streamz.DefaultLayout),
+}
+
+func viewsImportCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "import",
+		Args:  "<FILEVUEP>",
+		Short: "Load a FILEVUEP extract into viewsfile, or list its records",
+		Long: `Without -db the records are listed on STDOUT. With -db they are loaded
+into the viewsfile table in one transaction, Table as file and Method as
+access, and a summary of inserted, updated and rejected rows is printed.
+
+With -rules the records are checked against a JSON Schema whose
+properties are the columns Table, View, Method, Path and Pos, such as
+{"properties": {"Pos": {"minimum": 1}}}; a record breaking it is a bad
+row.`,
+	}
+	lenient := cmd.Flags().Bool("lenient", false, "Skip and report bad rows instead of stopping at the first one")
+	schema := &flagval.Choice{Value: "whitespace", Choices: []string{"whitespace", "semicolon", "tab", "fixed"}}
+	jobs := cmd.Flags().Int("j", 1, "Parse with `n` goroutines")
+	cmd.Flags().Var(schema, "schema", "File `layout`: whitespace, semicolon or tab with a header line, or fixed width")
+	dsn := cmd.Flags().String("db", "", "Postgres `url` to load the records into")
+	upsert := cmd.Flags().Bool("upsert", false, "Update the views already in the table instead of rejecting them")
+	dryRun := cmd.Flags().Bool("dry-run", false, "Roll the import back and only print the summary")
+	rulesPath := cmd.Flags().String("rules", "", "Check the records against the JSON Schema at `path`")
+	cmd.Run = func(ctx context.Context, args []string) error {
+		if len(args) != 1 {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("expected exactly one FILEVUEP file")}
+		}
+		var valid *dataformats.Validator[streamz.Filevuep]
+		if *rulesPath != "" {
+			var err error
+			if valid, err = loadRules(*rulesPath); err != nil {
+				return err
+			}
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if *dsn == "" && (*upsert || *dryRun) {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("-upsert and -dry-run need -db")}
+		}
+
+		// stops the decoder when the import fails
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// counted by the decoder and by the validator, on their goroutines
+		skipped, invalid := 0, 0
+		opts := []streamz.Option{streamz.WithSchema(viewsSchemas[schema.Value])}
+		if *lenient {
+			opts = append(opts, streamz.Lenient(func(perr *streamz.ParseError) {
+				skipped++
+				fmt.Fprintln(cmd.ErrOrStderr(), "rejected:", perr)
+			}))
+		}
+		var results <-chan streamz.Result
+		if *jobs > 1 {
+			results = unbatch(ctx, streamz.Pipeline{Workers: *jobs}.Decode(ctx, f, opts...))
+		} else {
+			results = streamz.DecodeFilevuep(ctx, f, opts...)
+		}
+		if valid != nil {
+			results = validateViews(results, valid, *lenient, func(v streamz.Filevuep, err error) {
+				invalid++
+				fmt.Fprintf(cmd.ErrOrStderr(), "rejected: %s %s: %s\n", v.Table, v.View, strings.ReplaceAll(err.Error(), "\n", "; "))
+			})
+		}
+		if *dsn != "" {
+			return importViews(ctx, cmd, *dsn, results, storage.ImportOptions{Upsert: *upsert, DryRun: *dryRun}, func() int { return skipped + invalid })
+		}
+
+		n := 0
+		for v := range results {
+			if v.Err != nil {
+				return v.Err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%s\t%d\n", v.Table, v.View, v.Method, v.Path, v.Pos)
+			n++
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "%d records\n", n)
+		return nil
+	}
+	return cmd
+}
+
+// unbatch sends the records of the batches of a Pipeline one by one, as
+// DecodeFilevuep does.
+func unbatch(ctx context.Context, batches <-chan []streamz.Result) <-chan streamz.Result {
+	ch := make(chan streamz.Result, 1)
+	go func() {
+		defer close(ch)
+		for batch := range batches {
+			for _, r := range batch {
+				select {
+				case ch <- r:
+				case <-ctx.Done():
+					// a record left unread makes room for the error of
+					// ctx, the pipeline stopping on ctx too
+					select {
+					case <-ch:
+					default:
+					}
+					ch <- streamz.Result{Err: ctx.Err()}
+					for range batches {
+					}
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// loadRules returns the validator of the FILEVUEP records of the JSON
+// Schema at path.
+func loadRules(path string) (*dataformats.Validator[streamz.Filevuep], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := dataformats.ParseJSONSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	valid, err := dataformats.NewValidator[streamz.Filevuep](rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return valid, nil
+}
+
+// validateViews passes on the records of results that valid accepts. An
+// invalid record is given to reject when lenient, and otherwise ends the
+// results with its error. results is drained either way.
+func validateViews(results <-chan streamz.Result, valid *dataformats.Validator[streamz.Filevuep], lenient bool, reject func(streamz.Filevuep, error)) <-chan streamz.Result {
+	ch := make(chan streamz.Result, 1)
+	go func() {
+		defer close(ch)
+		for r := range results {
+			if r.Err == nil {
+				if err := valid.Validate(r.Filevuep); err != nil {
+					if lenient {
+						reject(r.Filevuep, err)
+						continue
+					}
+					r.Err = fmt.Errorf("view %s %s: %w", r.Table, r.View, err)
+				}
+			}
+			ch <- r
+			if r.Err != nil {
+				break
+			}
+		}
+		for range results {
+		}
+	}()
+	return ch
+}
+
+// importViews loads results into the database at dsn. skipped returns the
+// count of rows rejected before the import, called once it is done.
+func importViews(ctx context.Context, cmd *cli.Command, dsn string, results <-chan streamz.Result, opts storage.ImportOptions, skipped func() int) error {
+	db, err := openDB(ctx, cmd, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	opts.Reject = func(v storage.ViewsFile, why error) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "rejected: %s %s %s: %v\n", v.File, v.Access, v.View, why)
+	}
+	store, closeStore, err := newStorage(ctx, cmd, db)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	sum, err := store.ImportViews(ctx, results, opts)
+	if err != nil {
+		return err
+	}
+	sum.Rejected += int64(skipped())
+
+	w := cmd.OutOrStdout()
+	if opts.DryRun {
+		fmt.Fprintln(w, "dry run, nothing was changed")
+	}
+	fmt.Fprintf(w, "read:      %d\n", sum.Read)
+	fmt.Fprintf(w, "inserted:  %d\n", sum.Inserted)
+	fmt.Fprintf(w, "updated:   %d\n", sum.Updated)
+	fmt.Fprintf(w, "unchanged: %d\n", sum.Unchanged)
+	fmt.Fprintf(w, "rejected:  %d\n", sum.Rejected)
+	return nil
+}
+
+func viewsExportCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "export",
+		Short: "Write the viewsfile table as FILEVUEP, CSV or JSON Lines",
+	}
+	open := dbFlag(cmd, "Postgres `url` to read the views from")
+	file := cmd.Flags().String("file", "", "Only export the views of `file`")
+	access := cmd.Flags().String("access", "", "Only export the views with this `access`")
+	format := &flagval.Choice{Value: "filevuep", Choices: []string{"filevuep", "csv", "jsonl"}}
+	cmd.Flags().Var(format, "format", "Output `format`: filevuep, csv (with a header line) or jsonl")
+	out := cmd.Flags().String("o", "", "Write to `path` instead of STDOUT")
+	cmd.Run = func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("unexpected arguments")}
+		}
+		db, err := open(ctx)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		w := cmd.OutOrStdout()
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		var vw storage.ViewWriter
+		switch format.Value {
+		case "csv":
+			vw = storage.NewCSVWriter(w)
+		case "jsonl":
+			vw = storage.NewJSONLWriter(w)
+		default:
+			vw = storage.NewFilevuepWriter(w)
+		}
+
+		filter := storage.ViewFilter{File: *file, Access: *access}
+		store, closeStore, err := newStorage(ctx, cmd, db)
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+		n, err := store.ExportViews(ctx, filter, vw)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "%d views\n", n)
+		if f, ok := w.(*os.File); ok && *out != "" {
+			return f.Close()
+		}
+		return nil
+	}
+	return cmd
+}
+
+func viewsDiffCmd() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "diff",
+		Args:  "<FILEVUEP>",
+		Short: "Compare a FILEVUEP extract with the viewsfile table",
+		Long: `Lists the views added (+), removed (-) and changed (~) in the extract for
+every file and access, ordered by position. Only the file and access
+pairs of the extract are compared unless -all is given.`,
+	}
+	open := dbFlag(cmd, "Postgres `url` to compare with")
+	all := cmd.Flags().Bool("all", false, "Report the file and access pairs missing from the extract as removed")
+	schema := &flagval.Choice{Value: "whitespace", Choices: []string{"whitespace", "semicolon", "tab", "fixed"}}
+	cmd.Flags().Var(schema, "schema", "File `layout`: whitespace, semicolon or tab with a header line, or fixed width")
+	script := cmd.Flags().Bool("sql", false, "Print the SQL that reconciles the table instead of the report")
+	apply := cmd.Flags().Bool("apply", false, "Reconcile the table with the extract in one transaction")
+	cmd.Run = func(ctx context.Context, args []string) error {
+		if len(args) != 1 {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("expected exactly one FILEVUEP file")}
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		db, err := open(ctx)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		store, closeStore, err := newStorage(ctx, cmd, db)
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+		results := streamz.DecodeFilevuep(ctx, f, streamz.WithSchema(viewsSchemas[schema.Value]))
+		diffs, err := store.DiffViews(ctx, results, storage.DiffOptions{AllKeys: *all})
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		if *script {
+			if err := storage.WriteSQL(w, diffs); err != nil {
+				return err
+			}
+		} else {
+			writeDiff(w, diffs)
+		}
+		if *apply && len(diffs) > 0 {
+			if err := store.Reconcile(ctx, diffs); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.ErrOrStderr(), "reconciled")
+		}
+		return nil
+	}
+	return cmd
+}
+
+// writeDiff prints diffs in the report format of views diff.
+func writeDiff(w io.Writer, diffs []storage.KeyDiff) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "no differences")
+		return
+	}
+	for _, d := range diffs {
+		fmt.Fprintf(w, "%s %s\n", d.Key.File, d.Key.Access)
+		for _, c := range d.Changes {
+			v := c.View()
+			switch c.Kind {
+			case storage.Added:
+				fmt.Fprintf(w, "  + %5d %s %s\n", v.Order, v.View, v.Path)
+			case storage.Removed:
+				fmt.Fprintf(w, "  - %5d %s %s\n", v.Order, v.View, v.Path)
+			case storage.Changed:
+				fmt.Fprintf(w, "  ~ %5d %s", v.Order, v.View)
+				if c.Old.Path != c.New.Path {
+					fmt.Fprintf(w, " path %s -> %s", c.Old.Path, c.New.Path)
+				}
+				if c.Old.Order != c.New.Order {
+					fmt.Fprintf(w, " position %d -> %d", c.Old.Order, c.New.Order)
+				}
+				if c.Old.Pathfile != c.New.Pathfile {
+					fmt.Fprintf(w, " pathfile %s -> %s", c.Old.Pathfile, c.New.Pathfile)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+	}
+}
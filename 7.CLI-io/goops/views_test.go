@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"formats"
+	"streamz"
+)
+
+const views = `VUEP01 CLIENTS SEQ /data/clients 1
+VUEP01 ORDERS IDX /data/orders 0
+VUEP02 STOCK SEQ /data/stock 3
+`
+
+func newViewsValidator(t *testing.T, schema string) *dataformats.Validator[streamz.Filevuep] {
+	t.Helper()
+	rules, err := dataformats.ParseJSONSchema([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := dataformats.NewValidator[streamz.Filevuep](rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return valid
+}
+
+func TestValidateFilevuep(t *testing.T) {
+	valid := newViewsValidator(t, `{"properties": {"Pos": {"minimum": 1, "maximum": 2}, "method": {"enum": ["SEQ", "IDX"]}}}`)
+	for _, tc := range []struct {
+		v    streamz.Filevuep
+		want string
+	}{
+		{streamz.Filevuep{Table: "T", View: "V", Method: "SEQ", Path: "/p", Pos: 1}, ""},
+		{streamz.Filevuep{Table: "T", View: "V", Method: "SEQ", Path: "/p", Pos: 0}, `column Pos: "0": less than the minimum 1`},
+		{streamz.Filevuep{Table: "T", View: "V", Method: "RND", Path: "/p", Pos: 3}, "not one of SEQ, IDX"},
+		{streamz.Filevuep{Table: "T", View: "V", Method: "RND", Path: "/p", Pos: 3}, "greater than the maximum 2"},
+	} {
+		err := valid.Validate(tc.v)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%+v: %v", tc.v, err)
+		case tc.want != "" && (!errors.Is(err, dataformats.ErrInvalid) || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%+v: got %v, want %s", tc.v, err, tc.want)
+		}
+	}
+	rules, err := dataformats.ParseJSONSchema([]byte(`{"required": ["Offset"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dataformats.NewValidator[streamz.Filevuep](rules); !errors.Is(err, dataformats.ErrType) {
+		t.Errorf("unknown column: got %v, want ErrType", err)
+	}
+}
+
+func TestValidateViews(t *testing.T) {
+	valid := newViewsValidator(t, `{"properties": {"Pos": {"minimum": 1}}}`)
+
+	var rejected []string
+	results := validateViews(streamz.DecodeFilevuep(context.Background(), strings.NewReader(views)), valid, true, func(v streamz.Filevuep, err error) {
+		rejected = append(rejected, v.View)
+	})
+	var got []string
+	for r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		got = append(got, r.View)
+	}
+	if strings.Join(got, " ") != "CLIENTS STOCK" || strings.Join(rejected, " ") != "ORDERS" {
+		t.Errorf("lenient: got %q, rejected %q", got, rejected)
+	}
+
+	// strict, the invalid record ends the results
+	got = nil
+	var last error
+	for r := range validateViews(streamz.DecodeFilevuep(context.Background(), strings.NewReader(views)), valid, false, nil) {
+		if r.Err != nil {
+			last = r.Err
+			continue
+		}
+		got = append(got, r.View)
+	}
+	if strings.Join(got, " ") != "CLIENTS" || !errors.Is(last, dataformats.ErrInvalid) || !strings.Contains(last.Error(), "view VUEP01 ORDERS") {
+		t.Errorf("strict: got %q and %v", got, last)
+	}
+}
+
+func TestUnbatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := strings.Repeat(views, 1000)
+	ch := unbatch(ctx, streamz.Pipeline{Workers: 2, BatchSize: 16}.Decode(ctx, strings.NewReader(in)))
+	<-ch
+	cancel()
+	var last error
+	for r := range ch {
+		last = r.Err
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("last result: got %v, want context.Canceled", last)
+	}
+}
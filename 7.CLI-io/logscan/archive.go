@@ -0,0 +1,215 @@
+package logscan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// codec is a compression format our archived logs come in. It is detected
+// by its magic bytes, or by extension when the magic is not there.
+type codec struct {
+	exts []string
+	// magic reports whether a stream starting with head is in this format.
+	magic func(head []byte) bool
+	open  func(io.Reader) (io.ReadCloser, error)
+}
+
+// prefix returns a codec magic func matching streams starting with b.
+func prefix(b ...byte) func([]byte) bool {
+	return func(head []byte) bool { return bytes.HasPrefix(head, b) }
+}
+
+// bzip2 block and end of stream magics, the digits of pi and of sqrt(pi).
+var (
+	bzBlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzEndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// isBzip2 checks the block size digit and the magic of the first block
+// after "BZh", a plain log may well start with those three letters.
+func isBzip2(head []byte) bool {
+	if len(head) < 10 || !bytes.HasPrefix(head, []byte("BZh")) || head[3] < '1' || head[3] > '9' {
+		return false
+	}
+	return bytes.Equal(head[4:10], bzBlockMagic) || bytes.Equal(head[4:10], bzEndMagic)
+}
+
+var codecs = []codec{
+	{
+		exts:  []string{".gz", ".tgz"},
+		magic: prefix(0x1f, 0x8b),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		exts:  []string{".bz2", ".tbz2"},
+		magic: isBzip2,
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		exts:  []string{".zst", ".tzst"},
+		magic: prefix(0x28, 0xb5, 0x2f, 0xfd),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	},
+}
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	tarMagic = []byte("ustar")
+)
+
+// tarMagicOffset is where the magic sits in a tar header.
+const tarMagicOffset = 257
+
+// detectCodec returns the codec of a stream starting with head, or nil.
+func detectCodec(name string, head []byte) *codec {
+	for i := range codecs {
+		if codecs[i].magic(head) {
+			return &codecs[i]
+		}
+	}
+	for i := range codecs {
+		for _, ext := range codecs[i].exts {
+			if strings.HasSuffix(name, ext) {
+				return &codecs[i]
+			}
+		}
+	}
+	return nil
+}
+
+// stripCodecExt returns the name of the decompressed stream, "a.tgz"
+// becoming "a.tar" so the tar inside is recognised too.
+func stripCodecExt(name string, c *codec) string {
+	for _, ext := range c.exts {
+		if strings.HasSuffix(name, ext) {
+			name = strings.TrimSuffix(name, ext)
+			if strings.HasPrefix(ext, ".t") {
+				name += ".tar"
+			}
+			return name
+		}
+	}
+	return name
+}
+
+func isTar(name string, head []byte) bool {
+	if len(head) >= tarMagicOffset+len(tarMagic) && bytes.Equal(head[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic) {
+		return true
+	}
+	return strings.HasSuffix(name, ".tar")
+}
+
+// openInput calls fn for every log stream in the named input, "-" being
+// STDIN. Compressed inputs are decompressed and archives are walked, their
+// members being named "archive!member".
+func openInput(name string, fn func(src string, r io.Reader) error) error {
+	if name == "-" {
+		return walkStream(stdinName, stdinName, os.Stdin, fn)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// a zip on disk can be read in place, anywhere else it is buffered
+	head := make([]byte, len(zipMagic))
+	if n, _ := f.ReadAt(head, 0); n == len(head) && bytes.Equal(head, zipMagic) {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return walkZip(name, f, fi.Size(), fn)
+	}
+	return walkStream(name, name, f, fn)
+}
+
+// walkStream decompresses r and walks the archive it holds, if any. src
+// is the provenance reported for r and name is used to guess its format.
+func walkStream(src, name string, r io.Reader, fn func(src string, r io.Reader) error) error {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(tarMagicOffset + len(tarMagic))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+
+	if c := detectCodec(name, head); c != nil {
+		dr, err := c.open(br)
+		if err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+		defer dr.Close()
+		return walkStream(src, stripCodecExt(name, c), dr, fn)
+	}
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+		return walkZip(src, bytes.NewReader(b), int64(len(b)), fn)
+	case isTar(name, head):
+		tr := tar.NewReader(br)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", src, err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := walkStream(src+"!"+hdr.Name, path.Base(hdr.Name), tr, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return fn(src, br)
+}
+
+// walkZip walks the members of a zip archive.
+func walkZip(src string, r io.ReaderAt, size int64, fn func(src string, r io.Reader) error) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("%s!%s: %w", src, zf.Name, err)
+		}
+		err = walkStream(src+"!"+zf.Name, path.Base(zf.Name), rc, fn)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
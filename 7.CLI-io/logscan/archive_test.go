@@ -0,0 +1,83 @@
+package logscan
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// streams returns the sources openInput finds in name and their text.
+func streams(t *testing.T, name string) map[string]string {
+	t.Helper()
+	got := map[string]string{}
+	err := openInput(name, func(src string, r io.Reader) error {
+		b, err := io.ReadAll(r)
+		got[src] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return got
+}
+
+func TestOpenInput(t *testing.T) {
+	b, err := os.ReadFile("testdata/app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := string(b)
+	// a bzip2 stream is recognised without its extension too
+	noext := filepath.Join(t.TempDir(), "app")
+	bz, err := os.ReadFile("testdata/app.log.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(noext, bz, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string][]string{
+		"testdata/app.log":     {"testdata/app.log"},
+		"testdata/app.log.gz":  {"testdata/app.log.gz"},
+		"testdata/app.log.bz2": {"testdata/app.log.bz2"},
+		"testdata/app.log.zst": {"testdata/app.log.zst"},
+		"testdata/logs.tar":    {"testdata/logs.tar!a/app.log", "testdata/logs.tar!b.log.gz"},
+		"testdata/logs.tgz":    {"testdata/logs.tgz!a/app.log"},
+		"testdata/logs.zip":    {"testdata/logs.zip!app.log", "testdata/logs.zip!c.log.bz2"},
+		noext:                  {noext},
+	} {
+		got := streams(t, name)
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d streams, want %q", name, len(got), want)
+		}
+		for _, src := range want {
+			if text, ok := got[src]; !ok || text != app {
+				t.Errorf("%s: %s is %q, want the text of app.log", name, src, text)
+			}
+		}
+	}
+
+	// plain text starting with the bzip2 letters is left alone
+	got := streams(t, "testdata/bzh.log")
+	if text := got["testdata/bzh.log"]; !strings.HasPrefix(text, "BZh9 is") {
+		t.Errorf("bzh.log: got %q", got)
+	}
+}
+
+func TestIsBzip2(t *testing.T) {
+	for head, want := range map[string]bool{
+		"BZh91AY&SYoR":                    true,
+		"BZh1\x17\x72\x45\x38\x50\x90":    true,
+		"BZh0\x31\x41\x59\x26\x53\x59":    false,
+		"BZh9 is a bzip2 header":          false,
+		"BZh91AY&S":                       false,
+		"2024-05-18T10:01:02Z BZh91AY&SY": false,
+	} {
+		if got := isBzip2([]byte(head)); got != want {
+			t.Errorf("%q: got %v, want %v", head, got, want)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package logscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// headSize is how much of the start of a file is hashed to recognise it
+// again when resuming from an offset file.
+const headSize = 256
+
+// Follower follows a growing file like tail -F: it scans what is already
+// there, then keeps polling for new lines. A truncated file is read again
+// from the start, and when the path is renamed away and recreated the old
+// file is drained before switching to the new one.
+type Follower struct {
+	// Path is the file to follow. It may not exist yet.
+	Path string
+	// Poll is how often the file is checked for new data.
+	Poll time.Duration
+	// OffsetFile, when set, records how far Path was read so a restarted
+	// scan resumes there instead of at the start of the file.
+	OffsetFile string
+}
+
+// testHookRead, when set, is called after the file was read to EOF and
+// before it is checked for rotation.
+var testHookRead func()
+
+// followState is what gets persisted in the offset file.
+type followState struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Line   int    `json:"line"`
+	// Head is the hash of the first HeadLen bytes of the file, so a file
+	// rotated in while we were not running is not resumed at a stale offset.
+	Head    string `json:"head"`
+	HeadLen int    `json:"head_len"`
+}
+
+// Follow scans the file and the lines appended to it until ctx is done,
+// calling emit like Matcher.Scan does. It returns nil when ctx is done.
+func (f *Follower) Follow(ctx context.Context, m *Matcher, emit func(Match) error) error {
+	poll := f.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	tick := time.NewTicker(poll)
+	defer tick.Stop()
+
+	st := m.stream(f.Path, emit)
+	var (
+		file    *os.File
+		r       *bufio.Reader
+		partial []byte
+		saved   int64 = -1
+	)
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+	// drain reads every complete line available; a trailing partial line
+	// is kept until the rest of it is written.
+	drain := func() error {
+		for {
+			b, err := r.ReadBytes('\n')
+			partial = append(partial, b...)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := st.feed(bytes.TrimRight(partial, "\r\n"), len(partial)); err != nil {
+				return err
+			}
+			partial = partial[:0]
+		}
+	}
+
+	for {
+		if file == nil {
+			var err error
+			file, err = f.open(st)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				// wait for the file to show up
+			case err != nil:
+				return err
+			default:
+				r = bufio.NewReader(file)
+				partial = partial[:0]
+			}
+		}
+
+		if file != nil {
+			if err := drain(); err != nil {
+				return err
+			}
+			if testHookRead != nil {
+				testHookRead()
+			}
+			if st.next != saved {
+				if err := f.save(file, st); err != nil {
+					return err
+				}
+				saved = st.next
+			}
+
+			rotated, err := f.rotated(file, st.next+int64(len(partial)))
+			if err != nil {
+				return err
+			}
+			switch rotated {
+			case truncated:
+				log.Printf("%s: file truncated, reading from the start", f.Path)
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				r.Reset(file)
+				partial = partial[:0]
+				st.reset(0, 0)
+			case renamed:
+				log.Printf("%s: file rotated, following the new file", f.Path)
+				// lines written to the old file since the last read
+				if err := drain(); err != nil {
+					return err
+				}
+				if len(partial) > 0 {
+					// the old file will not get the end of this line anymore
+					if err := st.feed(bytes.TrimRight(partial, "\r\n"), len(partial)); err != nil {
+						return err
+					}
+				}
+				file.Close()
+				file = nil
+				st.reset(0, 0)
+				saved = -1
+				if err := f.clear(); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// open opens Path and positions it at the offset recorded in the offset
+// file, when that offset still belongs to this file.
+func (f *Follower) open(st *stream) (*os.File, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	state, err := f.load()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if state == nil {
+		return file, nil
+	}
+	head, _, err := fileHead(file, state.HeadLen)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if head != state.Head || fi.Size() < state.Offset {
+		log.Printf("%s: offset file does not match the file anymore, reading from the start", f.Path)
+		return file, nil
+	}
+	if _, err := file.Seek(state.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	st.reset(state.Line, state.Offset)
+	return file, nil
+}
+
+type rotation int
+
+const (
+	unchanged rotation = iota
+	truncated
+	renamed
+)
+
+// rotated compares the open file with what Path is now. pos is how far the
+// open file was read.
+func (f *Follower) rotated(file *os.File, pos int64) (rotation, error) {
+	cur, err := file.Stat()
+	if err != nil {
+		return unchanged, err
+	}
+	fi, err := os.Stat(f.Path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// renamed away and not recreated yet, keep reading the old one
+		return unchanged, nil
+	case err != nil:
+		return unchanged, err
+	case !os.SameFile(cur, fi):
+		return renamed, nil
+	case cur.Size() < pos:
+		return truncated, nil
+	}
+	return unchanged, nil
+}
+
+// fileHead hashes the first n bytes of file, n being at most headSize.
+// n < 0 means as many as there are. The read position is restored.
+func fileHead(file *os.File, n int) (string, int, error) {
+	if n < 0 || n > headSize {
+		n = headSize
+	}
+	buf := make([]byte, n)
+	got, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(buf[:got])
+	return hex.EncodeToString(sum[:]), got, nil
+}
+
+// load reads the offset file. It returns nil when there is none or it is
+// about another file.
+func (f *Follower) load() (*followState, error) {
+	if f.OffsetFile == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(f.OffsetFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &followState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("offset file %s: %w", f.OffsetFile, err)
+	}
+	abs, err := filepath.Abs(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	if state.Path != abs {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// save records the position of st in the offset file. The file is
+// replaced atomically so a crash never leaves it half written.
+func (f *Follower) save(file *os.File, st *stream) error {
+	if f.OffsetFile == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(f.Path)
+	if err != nil {
+		return err
+	}
+	head, n, err := fileHead(file, -1)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(followState{Path: abs, Offset: st.next, Line: st.line, Head: head, HeadLen: n})
+	if err != nil {
+		return err
+	}
+	tmp := f.OffsetFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.OffsetFile)
+}
+
+// clear forgets the recorded offset, used once the file was rotated.
+func (f *Follower) clear() error {
+	if f.OffsetFile == "" {
+		return nil
+	}
+	err := os.Remove(f.OffsetFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package logscan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// follow runs fl in the background. It returns the "line:text" of the
+// matches and a func stopping it and returning its error.
+func follow(t *testing.T, fl *Follower) (<-chan string, func() error) {
+	t.Helper()
+	fl.Poll = 5 * time.Millisecond
+	m := &Matcher{Patterns: []*regexp.Regexp{regexp.MustCompile(".")}}
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- fl.Follow(ctx, m, func(mt Match) error {
+			lines <- fmt.Sprintf("%d:%s", mt.Line, mt.Text)
+			return nil
+		})
+	}()
+	stop := sync.OnceValue(func() error {
+		cancel()
+		return <-done
+	})
+	t.Cleanup(func() { stop() })
+	return lines, stop
+}
+
+// expect waits for the next lines of ch.
+func expect(t *testing.T, ch <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-ch:
+			if got != w {
+				t.Fatalf("got %s, want %s", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", w)
+		}
+	}
+}
+
+func appendFile(t *testing.T, path, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a1\na2\n")
+	lines, _ := follow(t, &Follower{Path: path})
+	expect(t, lines, "1:a1", "2:a2")
+
+	appendFile(t, path, "a3\na4 is cut")
+	expect(t, lines, "3:a3")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	// the old file is drained before the new one is followed
+	appendFile(t, path+".1", " short\na5\n")
+	appendFile(t, path, "b1\n")
+	expect(t, lines, "4:a4 is cut short", "5:a5", "1:b1")
+}
+
+func TestFollowRotationBetweenPolls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a1\n")
+	// a2 is written and the file rotated between the last read and the
+	// rotation check
+	var once sync.Once
+	testHookRead = func() {
+		once.Do(func() {
+			appendFile(t, path, "a2\n")
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Error(err)
+			}
+			appendFile(t, path, "b1\n")
+		})
+	}
+	t.Cleanup(func() { testHookRead = nil })
+	lines, _ := follow(t, &Follower{Path: path})
+	expect(t, lines, "1:a1", "2:a2", "1:b1")
+}
+
+func TestFollowTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a1\na2\n")
+	lines, _ := follow(t, &Follower{Path: path})
+	expect(t, lines, "1:a1", "2:a2")
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "b1\n")
+	expect(t, lines, "1:b1")
+}
+
+func TestFollowOffsetFile(t *testing.T) {
+	dir := t.TempDir()
+	path, offsets := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.offset")
+	appendFile(t, path, "a1\na2\n")
+	lines, stop := follow(t, &Follower{Path: path, OffsetFile: offsets})
+	expect(t, lines, "1:a1", "2:a2")
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	appendFile(t, path, "a3\n")
+	lines, stop = follow(t, &Follower{Path: path, OffsetFile: offsets})
+	expect(t, lines, "3:a3")
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// another file at the same path is read from the start
+	if err := os.WriteFile(path, []byte("b1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lines, _ = follow(t, &Follower{Path: path, OffsetFile: offsets})
+	expect(t, lines, "1:b1")
+}
@@ -0,0 +1,251 @@
+// Package logscan greps log files for the lines we care about during an
+// incident.
+package logscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/MoadHar/go_ops/7.CLI-io/cli"
+	"github.com/MoadHar/go_ops/7.CLI-io/flagval"
+)
+
+// stdinName is the Source of lines read from STDIN.
+const stdinName = "(standard input)"
+
+// Command returns the logscan command. It reads the files and globs given
+// as arguments, or STDIN when there are none.
+func Command() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "logscan",
+		Args:  "[file|glob...]",
+		Short: "Print the log lines matching the given patterns",
+		Long: `Print the log lines matching any of the patterns given with -e or
+-pattern-file. Named groups such as (?P<user>\w+) are extracted and printed
+as fields after the line. With -format json every line is a JSON object
+with the file, line number, byte offset, pattern, groups and timestamp.
+Globs are expanded even when quoted, "-" reads STDIN. Inputs compressed
+with gzip, bzip2 or zstd are decompressed and tar and zip archives are
+walked, their lines being reported as archive!member:line. With -f a single
+file is followed like tail -F until the scan is interrupted.
+
+Directories are walked and inputs are scanned in parallel, the output still
+comes input after input in the order given unless -merge-by-time is set.`,
+	}
+	fs := cmd.Flags()
+	exprs := &flagval.Strings{}
+	fs.Var(exprs, "e", "Regexp `pattern` to match, can be repeated")
+	patternFile := fs.String("pattern-file", "", "Read patterns from `file`, one per line, skipping blank lines and # comments")
+	ignoreCase := fs.Bool("i", false, "Match the patterns case insensitively")
+	invert := fs.Bool("v", false, "Select the lines matching none of the patterns")
+	countOnly := fs.Bool("c", false, "Only print the number of selected lines per input")
+	before := fs.Int("B", 0, "Print `n` lines of context before each match")
+	after := fs.Int("A", 0, "Print `n` lines of context after each match")
+	around := fs.Int("C", 0, "Print `n` lines of context before and after each match")
+	format := &flagval.Choice{Value: "text", Choices: []string{"text", "json"}}
+	fs.Var(format, "format", "Output `format`: text or json (JSON Lines)")
+	color := &flagval.Choice{Value: "auto", Choices: []string{"auto", "always", "never"}}
+	fs.Var(color, "color", "Highlight matches: auto (when STDOUT is a terminal), always or never")
+	withSummary := fs.Bool("summary", false, "Print the number of selected lines per pattern to STDERR when done")
+	follow := fs.Bool("f", false, "Follow the file as it grows, across truncation and rotation, until interrupted")
+	offsetFile := fs.String("offset-file", "", "With -f, record the read position in `file` and resume from it on restart")
+	poll := &flagval.Duration{D: time.Second, Min: 10 * time.Millisecond}
+	fs.Var(poll, "poll", "With -f, how often to check the file for new lines, a `duration`")
+	jobs := fs.Int("j", runtime.NumCPU(), "Number of inputs to scan in parallel")
+	mergeByTime := fs.Bool("merge-by-time", false, "Interleave the lines of all inputs by their timestamp instead of printing input after input")
+	maxLine := flagval.ByteSize(DefaultMaxLine)
+	fs.Var(&maxLine, "max-line", "Longest line accepted, a `size` such as 64MiB")
+
+	cmd.Run = func(ctx context.Context, args []string) error {
+		patterns := exprs.Values
+		if *patternFile != "" {
+			more, err := ReadPatterns(*patternFile)
+			if err != nil {
+				return err
+			}
+			patterns = append(patterns, more...)
+		}
+		if len(patterns) == 0 {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("no pattern given, use -e or -pattern-file")}
+		}
+		res, err := Compile(patterns, *ignoreCase)
+		if err != nil {
+			return &cli.UsageError{Cmd: cmd, Err: err}
+		}
+		if *before < 0 || *after < 0 || *around < 0 {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("context line counts cannot be negative")}
+		}
+		if *jobs < 1 {
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("-j must be at least 1")}
+		}
+		m := &Matcher{
+			Patterns: res,
+			Invert:   *invert,
+			Before:   max(*before, *around),
+			After:    max(*after, *around),
+			MaxLine:  int(min(maxLine, math.MaxInt32)),
+		}
+		if *countOnly {
+			m.Before, m.After = 0, 0
+		}
+
+		inputs, err := expandInputs(args)
+		if err != nil {
+			return &cli.UsageError{Cmd: cmd, Err: err}
+		}
+		switch {
+		case *follow && (len(inputs) != 1 || inputs[0] == "-"):
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("-f needs exactly one file")}
+		case *follow && *countOnly:
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("-f and -c cannot be set together")}
+		case *offsetFile != "" && !*follow:
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("-offset-file needs -f")}
+		case *mergeByTime && (*follow || *countOnly):
+			return &cli.UsageError{Cmd: cmd, Err: errors.New("-merge-by-time cannot be used with -f or -c")}
+		}
+		out := cmd.OutOrStdout()
+		var p printer
+		switch format.Value {
+		case "json":
+			p = newJSONPrinter(out, res)
+		default:
+			tp := &textPrinter{
+				w:        out,
+				context:  m.Before+m.After > 0,
+				color:    color.Value == "always" || (color.Value == "auto" && isTerminal(out)),
+				patterns: res,
+			}
+			if len(inputs) == 1 {
+				tp.only = inputs[0]
+				if tp.only == "-" {
+					tp.only = stdinName
+				}
+			}
+			p = tp
+		}
+		sum := newSummary(res)
+
+		if *follow {
+			fl := &Follower{Path: inputs[0], Poll: poll.D, OffsetFile: *offsetFile}
+			err := fl.Follow(ctx, m, func(match Match) error {
+				sum.add(match)
+				return p.print(match)
+			})
+			sum.inputs++
+			if *withSummary {
+				if werr := sum.write(cmd.ErrOrStderr(), res, m.Invert); err == nil {
+					err = werr
+				}
+			}
+			return err
+		}
+
+		failed, count := 0, 0
+		run := scanOrdered
+		if *mergeByTime {
+			run = scanMerged
+		}
+		err = run(ctx, m, inputs, *jobs, func(match Match) error {
+			sum.add(match)
+			if *countOnly {
+				count++
+				return nil
+			}
+			return p.print(match)
+		}, func(name string, err error) error {
+			defer func() { count = 0 }()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				log.Println("Error:", err)
+				failed++
+				return nil
+			}
+			sum.inputs++
+			if *countOnly {
+				if len(inputs) > 1 {
+					_, err = fmt.Fprintf(out, "%s:%d\n", name, count)
+				} else {
+					_, err = fmt.Fprintln(out, count)
+				}
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if *withSummary {
+			if err := sum.write(cmd.ErrOrStderr(), res, m.Invert); err != nil {
+				return err
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d inputs could not be scanned", failed, len(inputs))
+		}
+		return nil
+	}
+	return cmd
+}
+
+// expandInputs expands the globs in args and walks the directories. No
+// args means STDIN.
+func expandInputs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		log.Printf("no file specified, using STDIN")
+		return []string{"-"}, nil
+	}
+	var inputs []string
+	for _, arg := range args {
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("glob %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+			paths = matches
+		}
+		for _, p := range paths {
+			fi, err := os.Stat(p)
+			if err != nil || !fi.IsDir() {
+				// missing files are reported when scanned
+				inputs = append(inputs, p)
+				continue
+			}
+			err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					inputs = append(inputs, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// scanInput scans every log stream of the named input with m, see
+// openInput.
+func scanInput(ctx context.Context, m *Matcher, name string, emit func(Match) error) error {
+	return openInput(name, func(src string, r io.Reader) error {
+		return m.Scan(ctx, src, r, emit)
+	})
+}
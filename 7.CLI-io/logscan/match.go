@@ -0,0 +1,229 @@
+package logscan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Match is a line reported by Matcher.Scan, either a matching line or a
+// context line around one.
+type Match struct {
+	// Source names the input the line was read from.
+	Source string
+	// Line is the 1-based line number in Source.
+	Line int
+	// Offset is the byte offset of the start of the line in Source.
+	Offset int64
+	// Text is the line without its line ending.
+	Text string
+	// Pattern is the index of the first pattern that matched, or -1 for
+	// context lines and inverted matches.
+	Pattern int
+	// Groups holds the named capture groups of the pattern that matched.
+	Groups map[string]string
+	// Context is true for lines printed only as context.
+	Context bool
+	// Time is the timestamp found at the start of the line, zero if none.
+	Time time.Time
+}
+
+// Matcher selects lines matching any of its patterns.
+type Matcher struct {
+	Patterns []*regexp.Regexp
+	// Invert selects the lines matching none of the patterns instead.
+	Invert bool
+	// Before and After are the number of context lines around each match.
+	Before int
+	After  int
+	// MaxLine is the longest line Scan accepts, DefaultMaxLine when zero.
+	MaxLine int
+}
+
+// DefaultMaxLine is the default Matcher.MaxLine. bufio.Scanner alone stops
+// at 64KiB, which minified JSON logs easily exceed.
+const DefaultMaxLine = 64 << 20
+
+// Compile compiles the patterns, optionally making them case insensitive.
+func Compile(exprs []string, ignoreCase bool) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		src := expr
+		if ignoreCase {
+			src = "(?i)" + expr
+		}
+		re, err := regexp.Compile(src)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", expr, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// ReadPatterns reads one pattern per line from the named file. Blank lines
+// and lines starting with # are skipped.
+func ReadPatterns(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var exprs []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		exprs = append(exprs, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return exprs, nil
+}
+
+// find returns the index and the named groups of the first pattern
+// matching line, or -1.
+func (m *Matcher) find(line []byte) (int, map[string]string) {
+	for i, re := range m.Patterns {
+		sub := re.FindSubmatch(line)
+		if sub == nil {
+			continue
+		}
+		var groups map[string]string
+		for j, name := range re.SubexpNames() {
+			if name == "" || sub[j] == nil {
+				continue
+			}
+			if groups == nil {
+				groups = map[string]string{}
+			}
+			groups[name] = string(sub[j])
+		}
+		return i, groups
+	}
+	return -1, nil
+}
+
+// Scan reads r line by line and calls emit, in input order, for every
+// selected line and the context lines around it. src is used as
+// Match.Source.
+func (m *Matcher) Scan(ctx context.Context, src string, r io.Reader, emit func(Match) error) error {
+	s := bufio.NewScanner(r)
+	maxLine := m.MaxLine
+	if maxLine <= 0 {
+		maxLine = DefaultMaxLine
+	}
+	s.Buffer(make([]byte, 0, 64<<10), maxLine)
+	// track how much of the input each line used, line endings included,
+	// so matches can report their byte offset.
+	var advance int
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		n, tok, err := bufio.ScanLines(data, atEOF)
+		advance = n
+		return n, tok, err
+	})
+
+	st := m.stream(src, emit)
+	for s.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := st.feed(s.Bytes(), advance); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("%s: line %d: %w", src, st.line+1, err)
+	}
+	return nil
+}
+
+// stream applies a Matcher to the successive lines of one source. It keeps
+// the context and position state between lines so it can be fed from a
+// scanner as well as from a file being followed.
+type stream struct {
+	m    *Matcher
+	src  string
+	emit func(Match) error
+
+	before []Match
+	after  int
+	// line is the number of the last line fed, next the offset of the next one.
+	line int
+	next int64
+}
+
+func (m *Matcher) stream(src string, emit func(Match) error) *stream {
+	return &stream{m: m, src: src, emit: emit}
+}
+
+// reset starts over at the given position, dropping any pending context.
+func (st *stream) reset(line int, offset int64) {
+	st.before = st.before[:0]
+	st.after = 0
+	st.line, st.next = line, offset
+}
+
+// feed processes one line without its line ending. n is the number of
+// bytes the line used in the input, line ending included.
+func (st *stream) feed(line []byte, n int) error {
+	m := st.m
+	st.line++
+	offset := st.next
+	st.next += int64(n)
+
+	idx, groups := m.find(line)
+	if (idx >= 0) == m.Invert {
+		switch {
+		case st.after > 0:
+			st.after--
+			return st.send(newMatch(st.src, st.line, offset, line, -1, nil, true))
+		case m.Before > 0:
+			if len(st.before) == m.Before {
+				st.before = append(st.before[:0], st.before[1:]...)
+			}
+			st.before = append(st.before, newMatch(st.src, st.line, offset, line, -1, nil, true))
+		}
+		return nil
+	}
+	if m.Invert {
+		idx, groups = -1, nil
+	}
+
+	for _, b := range st.before {
+		if err := st.send(b); err != nil {
+			return err
+		}
+	}
+	st.before = st.before[:0]
+	st.after = m.After
+	return st.send(newMatch(st.src, st.line, offset, line, idx, groups, false))
+}
+
+// send emits m with its timestamp. Lines are only parsed for one once they
+// are emitted, most of the buffered context lines never are.
+func (st *stream) send(m Match) error {
+	m.Time, _ = ParseTimestamp(m.Text)
+	return st.emit(m)
+}
+
+func newMatch(src string, lineno int, offset int64, line []byte, idx int, groups map[string]string, isContext bool) Match {
+	return Match{
+		Source:  src,
+		Line:    lineno,
+		Offset:  offset,
+		Text:    string(line),
+		Pattern: idx,
+		Groups:  groups,
+		Context: isContext,
+	}
+}
@@ -0,0 +1,100 @@
+package logscan
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scan returns the matches of m in in, one "line:text" per match with a
+// "-" instead of the colon for context lines.
+func scan(t *testing.T, m *Matcher, in string) []string {
+	t.Helper()
+	var got []string
+	err := m.Scan(context.Background(), "test", strings.NewReader(in), func(mt Match) error {
+		sep := ":"
+		if mt.Context {
+			sep = "-"
+		}
+		got = append(got, fmt.Sprintf("%d%s%s", mt.Line, sep, mt.Text))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestScanContext(t *testing.T) {
+	in := "a\nb\nERR 1\nc\nd\ne\nf\nERR 2\nERR 3\ng\n"
+	for _, tc := range []struct {
+		before, after int
+		invert        bool
+		want          string
+	}{
+		{0, 0, false, "3:ERR 1 8:ERR 2 9:ERR 3"},
+		{1, 1, false, "2-b 3:ERR 1 4-c 7-f 8:ERR 2 9:ERR 3 10-g"},
+		{2, 0, false, "1-a 2-b 3:ERR 1 6-e 7-f 8:ERR 2 9:ERR 3"},
+		// the context of two matches is not printed twice
+		{0, 4, false, "3:ERR 1 4-c 5-d 6-e 7-f 8:ERR 2 9:ERR 3 10-g"},
+		{0, 1, true, "1:a 2:b 3-ERR 1 4:c 5:d 6:e 7:f 8-ERR 2 10:g"},
+	} {
+		m := &Matcher{Patterns: []*regexp.Regexp{regexp.MustCompile("ERR")}, Before: tc.before, After: tc.after, Invert: tc.invert}
+		if got := strings.Join(scan(t, m, in), " "); got != tc.want {
+			t.Errorf("-B %d -A %d -v=%v:\ngot  %s\nwant %s", tc.before, tc.after, tc.invert, got, tc.want)
+		}
+	}
+}
+
+func TestScanMatch(t *testing.T) {
+	in := "2024-05-18T10:01:02Z ok\r\n2024-05-18T10:01:03Z user=ada failed\nuser=alan failed\n"
+	m := &Matcher{Patterns: []*regexp.Regexp{regexp.MustCompile(`nope`), regexp.MustCompile(`user=(?P<user>\w+) failed`)}, Before: 1}
+	var got []Match
+	err := m.Scan(context.Background(), "auth.log", strings.NewReader(in), func(mt Match) error {
+		got = append(got, mt)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d matches, want 3", len(got))
+	}
+	want := []struct {
+		offset  int64
+		pattern int
+		user    string
+		time    time.Time
+	}{
+		{0, -1, "", time.Date(2024, 5, 18, 10, 1, 2, 0, time.UTC)},
+		{25, 1, "ada", time.Date(2024, 5, 18, 10, 1, 3, 0, time.UTC)},
+		{62, 1, "alan", time.Time{}},
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Source != "auth.log" || g.Line != i+1 || g.Offset != w.offset || g.Pattern != w.pattern || g.Groups["user"] != w.user || !g.Time.Equal(w.time) {
+			t.Errorf("line %d: got %+v", i+1, g)
+		}
+	}
+}
+
+func TestScanLongLine(t *testing.T) {
+	long := `{"msg":"` + strings.Repeat("x", 200<<10) + `","level":"error"}`
+	in := "start\n" + long + "\nend\n"
+	m := &Matcher{Patterns: []*regexp.Regexp{regexp.MustCompile(`"level":"error"`)}, After: 1}
+	got := scan(t, m, in)
+	if len(got) != 2 || got[0] != "2:"+long || got[1] != "3-end" {
+		t.Errorf("got %d matches, want the long line and its context", len(got))
+	}
+
+	m.MaxLine = 64 << 10
+	err := m.Scan(context.Background(), "test", strings.NewReader(in), func(Match) error { return nil })
+	if !errors.Is(err, bufio.ErrTooLong) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("MaxLine: got %v, want ErrTooLong at line 2", err)
+	}
+}
@@ -0,0 +1,203 @@
+package logscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printer writes selected lines in one of the output formats.
+type printer interface {
+	print(Match) error
+}
+
+// ANSI escapes used when colouring text output, the same colours as grep.
+const (
+	colorMatch = "\x1b[1;31m"
+	colorName  = "\x1b[35m"
+	colorSep   = "\x1b[36m"
+	colorReset = "\x1b[0m"
+)
+
+// isTerminal reports whether w is a terminal that wants colours.
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// textPrinter prints matches as ">>>> line", context lines indented and
+// "--" between groups of lines that are not adjacent.
+type textPrinter struct {
+	w io.Writer
+	// only is the single input being scanned, if there is one. Lines from
+	// any other source, such as archive members, are prefixed with their
+	// source and line number.
+	only string
+	// context enables the "--" separators.
+	context bool
+	// color highlights what the patterns matched.
+	color    bool
+	patterns []*regexp.Regexp
+
+	lastSrc  string
+	lastLine int
+}
+
+func (p *textPrinter) print(m Match) error {
+	if p.context && p.lastLine > 0 && (m.Source != p.lastSrc || m.Line != p.lastLine+1) {
+		if _, err := fmt.Fprintln(p.w, p.paint(colorSep, "--")); err != nil {
+			return err
+		}
+	}
+	p.lastSrc, p.lastLine = m.Source, m.Line
+
+	prefix := ">>>> "
+	if m.Context {
+		prefix = "     "
+	}
+	if m.Source != p.only {
+		prefix += p.paint(colorName, fmt.Sprintf("%s:%d", m.Source, m.Line)) + p.paint(colorSep, ": ")
+	}
+	text := m.Text
+	if p.color && m.Pattern >= 0 {
+		text = highlight(text, p.patterns[m.Pattern])
+	}
+	_, err := fmt.Fprintf(p.w, "%s%s%s\n", prefix, text, fields(m.Groups))
+	return err
+}
+
+func (p *textPrinter) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// highlight wraps every non empty match of re in s in colour escapes.
+func highlight(s string, re *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(colorMatch)
+		b.WriteString(s[loc[0]:loc[1]])
+		b.WriteString(colorReset)
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// fields renders named groups as "\tname=value ..." sorted by name.
+func fields(groups map[string]string) string {
+	if len(groups) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteByte('\t')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s=%q", name, groups[name])
+	}
+	return b.String()
+}
+
+// jsonRecord is one line of the JSON Lines output.
+type jsonRecord struct {
+	File    string            `json:"file"`
+	Line    int               `json:"line"`
+	Offset  int64             `json:"offset"`
+	Text    string            `json:"text"`
+	Pattern string            `json:"pattern,omitempty"`
+	Groups  map[string]string `json:"groups,omitempty"`
+	Time    *time.Time        `json:"time,omitempty"`
+	Context bool              `json:"context,omitempty"`
+}
+
+// jsonPrinter prints one JSON object per line.
+type jsonPrinter struct {
+	enc      *json.Encoder
+	patterns []*regexp.Regexp
+}
+
+func newJSONPrinter(w io.Writer, patterns []*regexp.Regexp) *jsonPrinter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonPrinter{enc: enc, patterns: patterns}
+}
+
+func (p *jsonPrinter) print(m Match) error {
+	rec := jsonRecord{
+		File:    m.Source,
+		Line:    m.Line,
+		Offset:  m.Offset,
+		Text:    m.Text,
+		Groups:  m.Groups,
+		Context: m.Context,
+	}
+	if m.Pattern >= 0 {
+		rec.Pattern = p.patterns[m.Pattern].String()
+	}
+	if !m.Time.IsZero() {
+		rec.Time = &m.Time
+	}
+	return p.enc.Encode(rec)
+}
+
+// summary counts the selected lines for the footer.
+type summary struct {
+	inputs     int
+	selected   int
+	perPattern []int
+}
+
+func newSummary(patterns []*regexp.Regexp) *summary {
+	return &summary{perPattern: make([]int, len(patterns))}
+}
+
+func (s *summary) add(m Match) {
+	if m.Context {
+		return
+	}
+	s.selected++
+	if m.Pattern >= 0 {
+		s.perPattern[m.Pattern]++
+	}
+}
+
+// write prints the footer. Each line is counted for the first pattern it
+// matched only.
+func (s *summary) write(w io.Writer, patterns []*regexp.Regexp, invert bool) error {
+	fmt.Fprintf(w, "-- %d selected lines in %d inputs\n", s.selected, s.inputs)
+	if invert {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	for i, re := range patterns {
+		fmt.Fprintf(tw, "%d\t  %s\n", s.perPattern[i], re)
+	}
+	return tw.Flush()
+}
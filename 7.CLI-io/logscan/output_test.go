@@ -0,0 +1,125 @@
+package logscan
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the output tests")
+
+// golden compares got with testdata/name.golden, which -update rewrites.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: got\n%s\nwant\n%s", name, got, want)
+	}
+}
+
+var outputPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`ERROR (?P<code>E\d+)`),
+	regexp.MustCompile(`timeout`),
+}
+
+// outputMatches are two groups of lines of app.log and a line of an
+// archive member, with context.
+func outputMatches() []Match {
+	at := time.Date(2024, 3, 1, 10, 0, 2, 0, time.UTC)
+	return []Match{
+		{Source: "app.log", Line: 1, Offset: 0, Text: "2024-03-01T10:00:01Z INFO start", Pattern: -1, Context: true},
+		{Source: "app.log", Line: 2, Offset: 32, Text: "2024-03-01T10:00:02Z ERROR E42 disk <full> & ERROR E43", Pattern: 0, Groups: map[string]string{"code": "E42"}, Time: at},
+		{Source: "app.log", Line: 3, Offset: 87, Text: "  at main.go:12", Pattern: -1, Context: true},
+		{Source: "app.log", Line: 9, Offset: 301, Text: "retry after timeout, timeout again", Pattern: 1},
+		{Source: "logs.tar:b.log", Line: 4, Offset: 60, Text: "timeout", Pattern: 1},
+	}
+}
+
+func TestTextPrinter(t *testing.T) {
+	var b bytes.Buffer
+	p := &textPrinter{w: &b, only: "app.log", context: true, patterns: outputPatterns}
+	for _, m := range outputMatches() {
+		if err := p.print(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden(t, "text", b.Bytes())
+
+	b.Reset()
+	p = &textPrinter{w: &b, only: "app.log", context: true, color: true, patterns: outputPatterns}
+	for _, m := range outputMatches() {
+		if err := p.print(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden(t, "text-color", b.Bytes())
+
+	// without context there are no separators
+	b.Reset()
+	p = &textPrinter{w: &b, patterns: outputPatterns}
+	for _, m := range outputMatches() {
+		if err := p.print(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden(t, "text-nocontext", b.Bytes())
+}
+
+func TestHighlight(t *testing.T) {
+	for _, tc := range []struct {
+		re, in, want string
+	}{
+		{`timeout`, "a timeout, timeout", "a " + colorMatch + "timeout" + colorReset + ", " + colorMatch + "timeout" + colorReset},
+		{`x*`, "abc", "abc"},
+		{`^`, "", ""},
+	} {
+		if got := highlight(tc.in, regexp.MustCompile(tc.re)); got != tc.want {
+			t.Errorf("%s in %q: got %q, want %q", tc.re, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestJSONPrinter(t *testing.T) {
+	var b bytes.Buffer
+	p := newJSONPrinter(&b, outputPatterns)
+	for _, m := range outputMatches() {
+		if err := p.print(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden(t, "json", b.Bytes())
+}
+
+func TestSummary(t *testing.T) {
+	s := newSummary(outputPatterns)
+	s.inputs = 2
+	for _, m := range outputMatches() {
+		s.add(m)
+	}
+	var b bytes.Buffer
+	if err := s.write(&b, outputPatterns, false); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "summary", b.Bytes())
+
+	b.Reset()
+	if err := s.write(&b, outputPatterns, true); err != nil {
+		t.Fatal(err)
+	}
+	if want := "-- 3 selected lines in 2 inputs\n"; b.String() != want {
+		t.Errorf("inverted: got %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,220 @@
+package logscan
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// batchSize is how many matches a scanning goroutine hands over at once.
+const batchSize = 256
+
+// batchBuffer is how many batches an input may have waiting to be printed.
+const batchBuffer = 4
+
+// source is the output of one input being scanned in the background.
+type source struct {
+	name    string
+	batches chan []Match
+	// err is the scan error, it is set before batches is closed.
+	err error
+}
+
+// scan scans the input, sending matches in batches. It releases sem while
+// blocked on a send so waiting for the printer never holds a worker slot;
+// sem may be nil.
+func (s *source) scan(ctx context.Context, m *Matcher, sem chan struct{}) {
+	defer close(s.batches)
+	var batch []Match
+	send := func() error {
+		if sem != nil {
+			<-sem
+			defer func() { sem <- struct{}{} }()
+		}
+		select {
+		case s.batches <- batch:
+			batch = nil
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.err = scanInput(ctx, m, s.name, func(match Match) error {
+		batch = append(batch, match)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return send()
+	})
+	if s.err == nil && len(batch) > 0 {
+		s.err = send()
+	}
+}
+
+// scanOrdered scans inputs with at most jobs of them being read at once,
+// and calls emit for every match as if the inputs had been scanned one
+// after the other. done is called after the last match of each input with
+// its scan error. emit and done are called from the calling goroutine.
+func scanOrdered(ctx context.Context, m *Matcher, inputs []string, jobs int, emit func(Match) error, done func(name string, err error) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sources := make([]*source, len(inputs))
+	for i, name := range inputs {
+		sources[i] = &source{name: name, batches: make(chan []Match, batchBuffer)}
+	}
+
+	// workers take the inputs in order, so the one being printed is always
+	// being scanned or done and the printer never waits on a stuck worker.
+	next := make(chan *source)
+	go func() {
+		defer close(next)
+		for _, s := range sources {
+			next <- s
+		}
+	}()
+	var wg sync.WaitGroup
+	for range max(jobs, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range next {
+				s.scan(ctx, m, nil)
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	var err error
+	for _, s := range sources {
+		for batch := range s.batches {
+			for _, match := range batch {
+				if err != nil {
+					break
+				}
+				err = emit(match)
+			}
+		}
+		if err == nil {
+			err = done(s.name, s.err)
+		}
+		if err != nil {
+			// stop the workers, the remaining sources still get drained
+			cancel()
+		}
+	}
+	return err
+}
+
+// scanMerged scans all inputs at once and calls emit with their matches
+// interleaved by timestamp. Each input is expected to be in time order;
+// lines without a timestamp, such as stack traces, keep the time of the
+// line before them. At most jobs inputs are being scanned at any time but
+// every input is held open until it is done.
+func scanMerged(ctx context.Context, m *Matcher, inputs []string, jobs int, emit func(Match) error, done func(name string, err error) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, max(jobs, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	h := &mergeHeap{}
+	for i, name := range inputs {
+		s := &source{name: name, batches: make(chan []Match, 1)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			s.scan(ctx, m, sem)
+		}()
+		h.cursors = append(h.cursors, &cursor{src: s, index: i})
+	}
+
+	// prime the heap with the first match of every input
+	var err error
+	live := h.cursors
+	h.cursors = nil
+	for _, c := range live {
+		if ok, e := c.advance(done); e != nil {
+			err = e
+		} else if ok {
+			h.cursors = append(h.cursors, c)
+		}
+	}
+	heap.Init(h)
+
+	for err == nil && h.Len() > 0 {
+		c := h.cursors[0]
+		if err = emit(c.head); err != nil {
+			break
+		}
+		ok, e := c.advance(done)
+		switch {
+		case e != nil:
+			err = e
+		case ok:
+			heap.Fix(h, 0)
+		default:
+			heap.Pop(h)
+		}
+	}
+	// on error the deferred cancel unblocks the scanners still sending
+	return err
+}
+
+// cursor walks the matches of one source for the merge.
+type cursor struct {
+	src   *source
+	index int
+	batch []Match
+	head  Match
+	// at is the time used to order head.
+	at time.Time
+}
+
+// advance moves to the next match. It returns false once the source is
+// exhausted, after calling done for it.
+func (c *cursor) advance(done func(string, error) error) (bool, error) {
+	for len(c.batch) == 0 {
+		batch, ok := <-c.src.batches
+		if !ok {
+			return false, done(c.src.name, c.src.err)
+		}
+		c.batch = batch
+	}
+	c.head, c.batch = c.batch[0], c.batch[1:]
+	if !c.head.Time.IsZero() {
+		c.at = c.head.Time
+	}
+	return true, nil
+}
+
+// mergeHeap orders cursors by the time of their head, then by input order
+// so equal times come out deterministically.
+type mergeHeap struct {
+	cursors []*cursor
+}
+
+func (h *mergeHeap) Len() int { return len(h.cursors) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.cursors[i], h.cursors[j]
+	if !a.at.Equal(b.at) {
+		return a.at.Before(b.at)
+	}
+	return a.index < b.index
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+
+func (h *mergeHeap) Push(x any) { h.cursors = append(h.cursors, x.(*cursor)) }
+
+func (h *mergeHeap) Pop() any {
+	old := h.cursors
+	c := old[len(old)-1]
+	h.cursors = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,118 @@
+package logscan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// interleaved writes n inputs whose lines have interleaved timestamps,
+// input i logging at the seconds i, i+n, i+2n... Every tenth line is
+// followed by an untimed trace line. It returns their paths.
+func interleaved(t *testing.T, n, lines int) []string {
+	t.Helper()
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 18, 10, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := range n {
+		var b strings.Builder
+		for j := range lines {
+			at := start.Add(time.Duration(i+j*n) * time.Second)
+			fmt.Fprintf(&b, "%s in%d line%d\n", at.Format(time.RFC3339), i, j)
+			if j%10 == 0 {
+				fmt.Fprintf(&b, "\tat in%d line%d\n", i, j)
+			}
+		}
+		path := filepath.Join(dir, fmt.Sprintf("in%d.log", i))
+		if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// run calls scan and returns the "inN lineM" of the matches and the
+// inputs done.
+func run(t *testing.T, scan func(context.Context, *Matcher, []string, int, func(Match) error, func(string, error) error) error, inputs []string, jobs int) ([]string, []string) {
+	t.Helper()
+	m := &Matcher{Patterns: []*regexp.Regexp{regexp.MustCompile(`in\d+ line\d+`)}}
+	var got, done []string
+	err := scan(context.Background(), m, inputs, jobs, func(mt Match) error {
+		got = append(got, m.Patterns[0].FindString(mt.Text))
+		return nil
+	}, func(name string, err error) error {
+		name = filepath.Base(name)
+		if err != nil {
+			name += ": failed"
+		}
+		done = append(done, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got, done
+}
+
+func TestScanOrdered(t *testing.T) {
+	// more lines than a batch so inputs are handed over in several
+	const n, lines = 4, 300
+	inputs := interleaved(t, n, lines)
+	var want []string
+	for i := range n {
+		for j := range lines {
+			want = append(want, fmt.Sprintf("in%d line%d", i, j))
+			if j%10 == 0 {
+				want = append(want, fmt.Sprintf("in%d line%d", i, j))
+			}
+		}
+	}
+	for _, jobs := range []int{1, 2, 8} {
+		got, done := run(t, scanOrdered, inputs, jobs)
+		if !slices.Equal(got, want) {
+			t.Errorf("jobs %d: %d matches not in input order", jobs, len(got))
+		}
+		if want := []string{"in0.log", "in1.log", "in2.log", "in3.log"}; !slices.Equal(done, want) {
+			t.Errorf("jobs %d: done %q, want %q", jobs, done, want)
+		}
+	}
+}
+
+func TestScanMerged(t *testing.T) {
+	const n, lines = 3, 300
+	inputs := interleaved(t, n, lines)
+	// an input that fails does not stop the others
+	inputs = append(inputs, filepath.Join(t.TempDir(), "missing.log"))
+	var want []string
+	for j := range lines {
+		for i := range n {
+			want = append(want, fmt.Sprintf("in%d line%d", i, j))
+			if j%10 == 0 {
+				// the trace line keeps the time of the line before it
+				want = append(want, fmt.Sprintf("in%d line%d", i, j))
+			}
+		}
+	}
+	for _, jobs := range []int{1, 2, 8} {
+		got, done := run(t, scanMerged, inputs, jobs)
+		if !slices.Equal(got, want) {
+			for i := range min(len(got), len(want)) {
+				if got[i] != want[i] {
+					t.Errorf("jobs %d: match %d is %s, want %s", jobs, i, got[i], want[i])
+					break
+				}
+			}
+			t.Fatalf("jobs %d: got %d matches, want %d", jobs, len(got), len(want))
+		}
+		if len(done) != n+1 || !slices.ContainsFunc(done, func(d string) bool { return d == "missing.log: failed" }) {
+			t.Errorf("jobs %d: done %q", jobs, done)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package logscan
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// stampFormats are the timestamp formats we find in our logs. Each regexp
+// picks the candidate out of the line and the layouts are tried in order.
+var stampFormats = []struct {
+	re      *regexp.Regexp
+	layouts []string
+}{
+	{
+		// RFC 3339 and its variants: 2024-05-18T10:01:02.123Z, 2024-05-18 10:01:02,123
+		re: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+		layouts: []string{
+			"2006-01-02T15:04:05.999999999Z07:00",
+			"2006-01-02T15:04:05.999999999Z0700",
+			"2006-01-02T15:04:05.999999999",
+		},
+	},
+	{
+		// the standard log package: 2024/05/18 10:01:02.123456
+		re:      regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		layouts: []string{"2006/01/02 15:04:05.999999999"},
+	},
+	{
+		// common log format: [18/May/2024:10:01:02 +0200]
+		re:      regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700"},
+	},
+	{
+		// syslog: May 18 10:01:02, the year is assumed to be the current one
+		re:      regexp.MustCompile(`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`),
+		layouts: []string{time.Stamp},
+	},
+}
+
+// stampWindow is how far into a line we look for a timestamp.
+const stampWindow = 64
+
+// ParseTimestamp returns the first timestamp found near the start of line.
+// Timestamps without a zone are read in local time.
+func ParseTimestamp(line string) (time.Time, bool) {
+	if len(line) > stampWindow {
+		line = line[:stampWindow]
+	}
+	for _, f := range stampFormats {
+		cand := f.re.FindString(line)
+		if cand == "" {
+			continue
+		}
+		cand = strings.Replace(cand, ",", ".", 1)
+		if len(cand) > 10 && cand[10] == ' ' && cand[4] == '-' {
+			cand = cand[:10] + "T" + cand[11:]
+		}
+		for _, layout := range f.layouts {
+			t, err := time.ParseInLocation(layout, cand, time.Local)
+			if err != nil {
+				continue
+			}
+			if t.Year() == 0 {
+				t = t.AddDate(time.Now().Year(), 0, 0)
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}